@@ -6,13 +6,15 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/norm/relay-daemon/internal/beads"
 	"github.com/norm/relay-daemon/internal/contextcapture"
+	"github.com/norm/relay-daemon/internal/execx"
 )
 
 const bdTimeout = 10 * time.Second
@@ -31,6 +33,8 @@ func main() {
 		runCheckpointTemplate()
 	case "restore-render":
 		runRestoreRender(os.Args[2:])
+	case "discover":
+		runDiscover(os.Args[2:])
 	default:
 		usage()
 		os.Exit(2)
@@ -38,13 +42,14 @@ func main() {
 }
 
 func usage() {
-	fmt.Fprintln(os.Stderr, "Usage: context-capture <tail|checkpoint-template|restore-render> [flags]")
+	fmt.Fprintln(os.Stderr, "Usage: context-capture <tail|checkpoint-template|restore-render|discover> [flags]")
 }
 
 func runTail(args []string) {
 	fs := flag.NewFlagSet("tail", flag.ExitOnError)
 	configPath := fs.String("config", "", "config file path")
 	tokens := fs.Int("tokens", 0, "override tail token count")
+	render := fs.String("render", "", "tail render mode: message or grouped (default from config)")
 	_ = fs.Parse(args)
 
 	cfg, err := loadConfig(*configPath)
@@ -61,13 +66,51 @@ func runTail(args []string) {
 		tailTokens = *tokens
 	}
 
-	out, err := contextcapture.TailExtract(path, tailTokens, cfg.Recovery.TailBytesPerToken)
+	opts := cfg.Recovery.TailOptions()
+	opts.Render = tailRender(cfg, *render)
+	out, err := contextcapture.TailExtract(path, tailTokens, cfg.Recovery.TailBytesPerToken, opts)
 	if err != nil {
 		exitErr(err)
 	}
 	fmt.Println(out)
 }
 
+// tailRender returns the -render flag value when set, else the configured mode.
+func tailRender(cfg *contextcapture.Config, flagValue string) string {
+	if flagValue == "" {
+		return cfg.Recovery.TailRender
+	}
+	if !contextcapture.ValidTailRender(flagValue) {
+		exitErr(fmt.Errorf("unknown render mode %q (want message or grouped)", flagValue))
+	}
+	return flagValue
+}
+
+// runDiscover prints the session log the other subcommands would read
+// and where it came from, exiting non-zero when none is found.
+func runDiscover(args []string) {
+	fs := flag.NewFlagSet("discover", flag.ExitOnError)
+	configPath := fs.String("config", "", "config file path")
+	_ = fs.Parse(args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		exitErr(err)
+	}
+	if err := printDiscovered(os.Stdout, cfg); err != nil {
+		exitErr(err)
+	}
+}
+
+func printDiscovered(w io.Writer, cfg *contextcapture.Config) error {
+	path, source, err := contextcapture.DiscoverSessionLogSource(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\nsource: %s\n", path, source)
+	return err
+}
+
 func runCheckpointTemplate() {
 	fmt.Println(`# Checkpoint
 
@@ -92,50 +135,105 @@ func runCheckpointTemplate() {
 *Wisp: {session_log_path} [bytes {start}-{end}] | Prev: {prev_chk_id}*`)
 }
 
+// Restore-render output formats.
+const (
+	formatMarkdown = "markdown"
+	formatJSON     = "json"
+)
+
 func runRestoreRender(args []string) {
 	fs := flag.NewFlagSet("restore-render", flag.ExitOnError)
 	configPath := fs.String("config", "", "config file path")
 	tokens := fs.Int("tokens", 0, "override tail token count")
 	includeSummaries := fs.Bool("summaries", true, "include chunk summaries and rollups")
+	render := fs.String("render", "", "tail render mode: message or grouped (default from config)")
+	headMode := fs.String("head", "", "session head inclusion: auto, always, or never (default from config)")
+	format := fs.String("format", formatMarkdown, "output format: markdown or json")
 	_ = fs.Parse(args)
 
+	if *format != formatMarkdown && *format != formatJSON {
+		exitErr(fmt.Errorf("unknown format %q (want markdown or json)", *format))
+	}
 	cfg, err := loadConfig(*configPath)
 	if err != nil {
 		exitErr(err)
 	}
+	if *headMode != "" {
+		if !contextcapture.ValidHeadMode(*headMode) {
+			exitErr(fmt.Errorf("unknown head mode %q (want auto, always, or never)", *headMode))
+		}
+		cfg.Recovery.HeadMode = *headMode
+	}
 
-	role := os.Getenv("AGENT_ROLE")
-	if role == "" {
-		role = "unknown"
+	rc := gatherRestoreContext(cfg, *tokens, *includeSummaries, tailRender(cfg, *render))
+	if *format == formatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rc); err != nil {
+			exitErr(err)
+		}
+		return
 	}
+	renderRestoreMarkdown(os.Stdout, rc)
+}
+
+// restoreContext is everything restore-render gathers. Both output formats
+// render the same value so they cannot drift.
+type restoreContext struct {
+	CheckpointID     string `json:"checkpoint_id"`
+	CheckpointSource string `json:"checkpoint_source"`
+	Role             string `json:"role"`
+	Repo             string `json:"repo"`
+	CheckpointBody   string `json:"checkpoint_body"`
+	// SessionBrief supplements a task-bead checkpoint.
+	SessionBrief   string    `json:"session_brief,omitempty"`
+	StateRollup    string    `json:"state_rollup"`
+	ChunkSummaries []string  `json:"chunk_summaries"`
+	LastExchange   *exchange `json:"last_exchange,omitempty"`
+	HeadText       string    `json:"head_text,omitempty"`
+	// TailStartOffset is the byte the tail starts from when it skips
+	// content already covered by chunk summaries.
+	TailStartOffset int64  `json:"tail_start_offset,omitempty"`
+	TailText        string `json:"tail_text"`
+}
 
-	repo := "unknown"
+type exchange struct {
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+
+	userMsg, assistantMsg contextcapture.Message
+}
+
+func gatherRestoreContext(cfg *contextcapture.Config, tokens int, includeSummaries bool, tailMode string) restoreContext {
+	rc := restoreContext{Role: os.Getenv("AGENT_ROLE"), Repo: "unknown", ChunkSummaries: []string{}}
+	if rc.Role == "" {
+		rc.Role = "unknown"
+	}
 	if cwd, err := os.Getwd(); err == nil {
-		repo = filepath.Base(cwd)
+		rc.Repo = filepath.Base(cwd)
 	}
 
 	bdPath := resolveBDPath()
 
-	checkpointID, checkpointBody, checkpointSource := fetchCheckpoint(bdPath, role)
-	if checkpointID == "" {
-		checkpointID = "none"
+	rc.CheckpointID, rc.CheckpointBody, rc.CheckpointSource = fetchCheckpoint(bdPath, rc.Role, cfg.Recovery.CheckpointSources)
+	if rc.CheckpointID == "" {
+		rc.CheckpointID = "none"
 	}
-	if checkpointSource == "" {
-		checkpointSource = "unknown"
+	if rc.CheckpointSource == "" {
+		rc.CheckpointSource = "unknown"
 	}
 
 	// If primary source was a task bead, supplement with most recent session brief
-	var sessionBriefSupplement string
-	if strings.HasPrefix(checkpointSource, "task") && bdPath != "" {
-		_, sessionBriefSupplement = queryBeadByLabel(bdPath, role, "kind:session_brief")
+	if strings.HasPrefix(rc.CheckpointSource, "task") && bdPath != "" {
+		_, rc.SessionBrief = queryBeadByLabel(bdPath, rc.Role, "kind:session_brief")
 	}
 
 	// Fetch summaries (Phase 2)
-	var stateRollup, chunkSummaries string
-	var lastSummaryOffset int64
-	if *includeSummaries && bdPath != "" {
-		stateRollup, _ = fetchLatestStateRollup(bdPath, role)
-		chunkSummaries, lastSummaryOffset = fetchRecentChunkSummaries(bdPath, role, 3)
+	if includeSummaries && bdPath != "" {
+		rc.StateRollup, _ = fetchLatestStateRollup(bdPath, rc.Role)
+		if summaries, offset := fetchRecentChunkSummaries(bdPath, rc.Role, 3); len(summaries) > 0 {
+			rc.ChunkSummaries, rc.TailStartOffset = summaries, offset
+		}
 	}
 
 	path, err := contextcapture.DiscoverSessionLog(cfg)
@@ -144,64 +242,98 @@ func runRestoreRender(args []string) {
 	}
 
 	tailTokens := cfg.Recovery.TailTokens
-	if *tokens > 0 {
-		tailTokens = *tokens
+	if tokens > 0 {
+		tailTokens = tokens
 	}
 
-	tailText := ""
 	if path != "" {
+		tailOpts := cfg.Recovery.TailOptions()
+		tailOpts.Render = tailMode
 		// If we have summaries, skip content already covered (overlap skip)
-		startOffset := lastSummaryOffset
-		if out, err := contextcapture.TailExtractFromOffset(path, tailTokens, cfg.Recovery.TailBytesPerToken, startOffset); err == nil {
-			tailText = out
+		startOffset := rc.TailStartOffset
+		if out, err := contextcapture.TailExtractFromOffset(path, tailTokens, cfg.Recovery.TailBytesPerToken, startOffset, tailOpts); err == nil {
+			rc.TailText = out
 		} else {
 			// Fallback to regular tail
-			if out, err := contextcapture.TailExtract(path, tailTokens, cfg.Recovery.TailBytesPerToken); err == nil {
-				tailText = out
+			if out, err := contextcapture.TailExtract(path, tailTokens, cfg.Recovery.TailBytesPerToken, tailOpts); err == nil {
+				rc.TailText = out
 			}
 		}
 	}
-	if tailText == "" {
-		tailText = "(tail unavailable)"
+	if rc.TailText == "" {
+		rc.TailText = "(tail unavailable)"
+	}
+
+	// Bookend: the session head carries the original task, unless the
+	// summaries already cover it or the tail reaches back that far anyway.
+	haveSummaries := rc.StateRollup != "" || len(rc.ChunkSummaries) > 0
+	if path != "" && contextcapture.IncludeHead(cfg.Recovery.HeadMode, haveSummaries) && cfg.Recovery.HeadTokens > 0 {
+		bookend := int64((cfg.Recovery.HeadTokens + tailTokens) * cfg.Recovery.TailBytesPerToken)
+		if size, err := contextcapture.SessionLogSize(path); err == nil && size > bookend {
+			if out, err := contextcapture.HeadExtract(path, cfg.Recovery.HeadTokens, cfg.Recovery.TailBytesPerToken, tailMode, cfg.Recovery.IncludeToolCalls); err == nil {
+				rc.HeadText = out
+			}
+		}
+	}
+
+	if path != "" {
+		if user, assistant, ok := contextcapture.LastExchange(path); ok {
+			rc.LastExchange = &exchange{User: user.Content, Assistant: assistant.Content, userMsg: user, assistantMsg: assistant}
+		}
 	}
+	return rc
+}
 
-	// Render output
-	fmt.Println("## Recovery Context")
-	fmt.Printf("**Checkpoint:** %s (%s, %s)\n", checkpointID, checkpointSource, "age unknown")
-	fmt.Printf("**Role:** %s\n", role)
-	fmt.Printf("**Repo:** %s\n\n", repo)
+func renderRestoreMarkdown(w io.Writer, rc restoreContext) {
+	fmt.Fprintln(w, "## Recovery Context")
+	fmt.Fprintf(w, "**Checkpoint:** %s (%s, %s)\n", rc.CheckpointID, rc.CheckpointSource, "age unknown")
+	fmt.Fprintf(w, "**Role:** %s\n", rc.Role)
+	fmt.Fprintf(w, "**Repo:** %s\n\n", rc.Repo)
+
+	// The latest complete exchange is the highest-signal pointer to the
+	// current task, so it leads the rendered context.
+	if rc.LastExchange != nil {
+		fmt.Fprintln(w, "### Last Exchange")
+		fmt.Fprintln(w, contextcapture.FormatExchange(rc.LastExchange.userMsg, rc.LastExchange.assistantMsg))
+		fmt.Fprintln(w)
+	}
 
-	fmt.Println("### State Summary (from checkpoint)")
-	if checkpointBody == "" {
-		fmt.Println("(no checkpoint found)")
+	fmt.Fprintln(w, "### State Summary (from checkpoint)")
+	if rc.CheckpointBody == "" {
+		fmt.Fprintln(w, "(no checkpoint found)")
 	} else {
-		fmt.Println(strings.TrimSpace(checkpointBody))
+		fmt.Fprintln(w, strings.TrimSpace(rc.CheckpointBody))
 	}
 
 	// Supplement: session brief context when primary source is a task bead
-	if sessionBriefSupplement != "" {
-		fmt.Println("\n### Session Brief (supplement)")
-		fmt.Println(strings.TrimSpace(sessionBriefSupplement))
+	if rc.SessionBrief != "" {
+		fmt.Fprintln(w, "\n### Session Brief (supplement)")
+		fmt.Fprintln(w, strings.TrimSpace(rc.SessionBrief))
 	}
 
 	// Phase 2: Include summaries section
-	if stateRollup != "" || chunkSummaries != "" {
-		fmt.Println("\n### Session Summaries")
-		if stateRollup != "" {
-			fmt.Println("#### State Rollup")
-			fmt.Println(strings.TrimSpace(stateRollup))
+	if rc.StateRollup != "" || len(rc.ChunkSummaries) > 0 {
+		fmt.Fprintln(w, "\n### Session Summaries")
+		if rc.StateRollup != "" {
+			fmt.Fprintln(w, "#### State Rollup")
+			fmt.Fprintln(w, strings.TrimSpace(rc.StateRollup))
 		}
-		if chunkSummaries != "" {
-			fmt.Println("\n#### Recent Chunks")
-			fmt.Println(strings.TrimSpace(chunkSummaries))
+		if len(rc.ChunkSummaries) > 0 {
+			fmt.Fprintln(w, "\n#### Recent Chunks")
+			fmt.Fprintln(w, strings.Join(rc.ChunkSummaries, "\n\n---\n\n"))
 		}
 	}
 
-	fmt.Println("\n### Recent Activity (from tail capture)")
-	if lastSummaryOffset > 0 {
-		fmt.Printf("*(starting from byte %d to avoid overlap with summaries)*\n\n", lastSummaryOffset)
+	if rc.HeadText != "" {
+		fmt.Fprintln(w, "\n### Session Start (from head capture)")
+		fmt.Fprintln(w, rc.HeadText)
 	}
-	fmt.Println(tailText)
+
+	fmt.Fprintln(w, "\n### Recent Activity (from tail capture)")
+	if rc.TailStartOffset > 0 {
+		fmt.Fprintf(w, "*(starting from byte %d to avoid overlap with summaries)*\n\n", rc.TailStartOffset)
+	}
+	fmt.Fprintln(w, rc.TailText)
 }
 
 func loadConfig(path string) (*contextcapture.Config, error) {
@@ -213,8 +345,8 @@ func loadConfig(path string) (*contextcapture.Config, error) {
 
 // resolveBDPath finds the bd binary, returning empty string if not found.
 func resolveBDPath() string {
-	bdPath := os.ExpandEnv("$HOME/go/bin/bd")
-	if _, err := exec.LookPath(bdPath); err != nil {
+	bdPath, err := beads.ResolvePath()
+	if err != nil {
 		return ""
 	}
 	return bdPath
@@ -222,33 +354,43 @@ func resolveBDPath() string {
 
 // bdRun executes a bd command with a timeout and returns its output.
 func bdRun(bdPath string, args ...string) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), bdTimeout)
-	defer cancel()
-	return exec.CommandContext(ctx, bdPath, args...).Output()
+	// Every call here is a read, so one retry is safe.
+	res, err := execx.Default.Run(context.Background(), bdPath, args, execx.Options{
+		Timeout:  bdTimeout,
+		Attempts: 2,
+	})
+	return res.Stdout, err
 }
 
-func fetchCheckpoint(bdPath, role string) (string, string, string) {
+func fetchCheckpoint(bdPath, role string, sources []string) (string, string, string) {
 	if bdPath == "" {
 		return "", "", ""
 	}
-
-	// Primary: active task bead — single query, filter active statuses in Go
-	if id, body := queryActiveTaskBead(bdPath, role); id != "" {
-		return id, body, "task"
-	}
-
-	// Fallback A: recently completed task (within 2h)
-	twoHoursAgo := time.Now().Add(-2 * time.Hour).Format(time.RFC3339)
-	if id, body := queryBead(bdPath, "task", role, "completed", "--created-after", twoHoursAgo); id != "" {
-		return id, body, "task_completed"
+	if len(sources) == 0 {
+		sources = contextcapture.DefaultCheckpointSources()
 	}
+	return contextcapture.ResolveCheckpoint(sources, func(kind string) (string, string) {
+		return queryCheckpointSource(bdPath, role, kind)
+	})
+}
 
-	// Fallback B: session brief
-	if id, body := queryBeadByLabel(bdPath, role, "kind:session_brief"); id != "" {
-		return id, body, "session_brief"
+// queryCheckpointSource fetches the newest bead for one checkpoint source kind.
+func queryCheckpointSource(bdPath, role, kind string) (string, string) {
+	switch kind {
+	case contextcapture.SourceTask:
+		// Active task bead — single query, filter active statuses in Go
+		return queryActiveTaskBead(bdPath, role)
+	case contextcapture.SourceTaskCompleted:
+		// Recently completed task (within 2h)
+		twoHoursAgo := time.Now().Add(-2 * time.Hour).Format(time.RFC3339)
+		return queryBead(bdPath, "task", role, "completed", "--created-after", twoHoursAgo)
+	case contextcapture.SourceRecovery, contextcapture.SourceCheckpoint:
+		return queryBead(bdPath, kind, role, "")
+	case contextcapture.SourceSessionBrief:
+		return queryBeadByLabel(bdPath, role, "kind:session_brief")
+	default:
+		return "", ""
 	}
-
-	return "", "", ""
 }
 
 // queryActiveTaskBead queries all task beads for a role in a single bd call,
@@ -351,19 +493,19 @@ func fetchLatestStateRollup(bdPath, role string) (string, error) {
 
 // fetchRecentChunkSummaries retrieves recent chunk_summary beads.
 // Returns the concatenated summaries and the end_offset of the most recent chunk.
-func fetchRecentChunkSummaries(bdPath, role string, limit int) (string, int64) {
+func fetchRecentChunkSummaries(bdPath, role string, limit int) ([]string, int64) {
 	listOut, err := bdRun(bdPath,"list", "--type", "chunk_summary", "--label", "role:"+role, "--limit", fmt.Sprintf("%d", limit), "--json")
 	if err != nil {
-		return "", 0
+		return nil, 0
 	}
 
 	var beads []map[string]any
 	if err := json.Unmarshal(listOut, &beads); err != nil {
-		return "", 0
+		return nil, 0
 	}
 
 	if len(beads) == 0 {
-		return "", 0
+		return nil, 0
 	}
 
 	var summaries []string
@@ -399,7 +541,7 @@ func fetchRecentChunkSummaries(bdPath, role string, limit int) (string, int64) {
 		}
 	}
 
-	return strings.Join(summaries, "\n\n---\n\n"), maxOffset
+	return summaries, maxOffset
 }
 
 func parseCheckpointID(raw []byte) string {
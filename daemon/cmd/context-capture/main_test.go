@@ -0,0 +1,20 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/norm/relay-daemon/internal/contextcapture"
+)
+
+func TestPrintDiscoveredUsesSessionLogPathEnv(t *testing.T) {
+	t.Setenv("SESSION_LOG_PATH", "/tmp/sessions/current.jsonl")
+	var out bytes.Buffer
+	if err := printDiscovered(&out, contextcapture.DefaultConfig()); err != nil {
+		t.Fatalf("printDiscovered: %v", err)
+	}
+	want := "/tmp/sessions/current.jsonl\nsource: " + contextcapture.SessionSourceEnv + "\n"
+	if out.String() != want {
+		t.Fatalf("output = %q, want %q", out.String(), want)
+	}
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime/debug"
@@ -17,13 +18,27 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/norm/relay-daemon/internal/beads"
+	"github.com/norm/relay-daemon/internal/capability"
+	"github.com/norm/relay-daemon/internal/clockskew"
 	cfgpkg "github.com/norm/relay-daemon/internal/config"
+	"github.com/norm/relay-daemon/internal/deadletter"
+	"github.com/norm/relay-daemon/internal/diskguard"
+	"github.com/norm/relay-daemon/internal/execx"
 	inbox "github.com/norm/relay-daemon/internal/inbox"
+	"github.com/norm/relay-daemon/internal/labels"
 	logpkg "github.com/norm/relay-daemon/internal/log"
 	"github.com/norm/relay-daemon/internal/pane"
+	"github.com/norm/relay-daemon/internal/panehistory"
+	"github.com/norm/relay-daemon/internal/redact"
+	"github.com/norm/relay-daemon/internal/replay"
+	"github.com/norm/relay-daemon/internal/routing"
 	"github.com/norm/relay-daemon/internal/state"
+	"github.com/norm/relay-daemon/internal/statebundle"
+	"github.com/norm/relay-daemon/internal/status"
 	"github.com/norm/relay-daemon/internal/supervisor"
 	tmuxpkg "github.com/norm/relay-daemon/internal/tmux"
+	"github.com/norm/relay-daemon/pkg/envelope"
 )
 
 const (
@@ -91,27 +106,39 @@ type taskBeadManager struct {
 	stateDir string
 	repo     string
 	bdPath   string
-	mu       sync.Mutex
-	stateMu  sync.Mutex
-	byRole   map[string]*classifierState
+	redactor *redact.Redactor
+	bdRetry  beads.RetryPolicy
+	exec     *execx.Executor
+	// labelLimits caps labels on created beads; violations go to events.
+	labelLimits labels.Limits
+	events      *logpkg.EventLog
+	clock       clockskew.Policy
+	mu          sync.Mutex
+	stateMu     sync.Mutex
+	byRole      map[string]*classifierState
 }
 
 func (e daemonError) Error() string {
 	return e.detail
 }
 
-func newTaskBeadManager(stateDir, repo string) *taskBeadManager {
+func newTaskBeadManager(stateDir, repo string, redactor *redact.Redactor) *taskBeadManager {
 	if strings.TrimSpace(repo) == "" {
 		repo = "unknown"
 	}
-	bdPath, err := resolveBDPath()
+	bdPath, err := beads.ResolvePath()
 	if err != nil {
 		log.Printf("warning: bd not found, task bead operations disabled: %v", err)
 	}
 	return &taskBeadManager{
-		stateDir: stateDir,
-		repo:     repo,
-		bdPath:   bdPath,
+		stateDir:    stateDir,
+		repo:        repo,
+		bdPath:      bdPath,
+		redactor:    redactor,
+		bdRetry:     beads.DefaultRetryPolicy(),
+		exec:        execx.Default,
+		labelLimits: labels.DefaultLimits(),
+		clock:       clockskew.DefaultPolicy(),
 		byRole: map[string]*classifierState{
 			"cc": {},
 			"cx": {},
@@ -119,6 +146,17 @@ func newTaskBeadManager(stateDir, repo string) *taskBeadManager {
 	}
 }
 
+// inboxAgents returns the outbox directories the inbox watcher accepts:
+// the known roles, every role in the pane map, and the extra agents
+// (cfg.ExtraAgents).
+func inboxAgents(targets map[string]string, extra []string) []string {
+	agents := routing.KnownRoles()
+	for role := range targets {
+		agents = append(agents, role)
+	}
+	return append(agents, extra...)
+}
+
 func isTaskAgent(role string) bool {
 	return role == "cc" || role == "cx"
 }
@@ -181,26 +219,7 @@ func normalizeBeadStatus(status string) string {
 	}
 }
 
-func resolveBDPath() (string, error) {
-	bdPath, err := exec.LookPath("bd")
-	if err == nil {
-		return bdPath, nil
-	}
-	for _, p := range []string{
-		filepath.Join(os.Getenv("HOME"), "go", "bin", "bd"),
-		filepath.Join(os.Getenv("HOME"), ".local", "bin", "bd"),
-	} {
-		if _, statErr := os.Stat(p); statErr == nil {
-			return p, nil
-		}
-	}
-	return "", err
-}
-
-func (m *taskBeadManager) buildBDCommand(ctx context.Context, args ...string) (*exec.Cmd, error) {
-	if strings.TrimSpace(m.bdPath) == "" {
-		return nil, fmt.Errorf("bd not found")
-	}
+func (m *taskBeadManager) bdArgs(args ...string) []string {
 	fullArgs := append([]string{}, args...)
 	// Always use --no-daemon to avoid bd's 5s daemon startup probe timeout
 	fullArgs = append([]string{"--no-daemon"}, fullArgs...)
@@ -210,25 +229,36 @@ func (m *taskBeadManager) buildBDCommand(ctx context.Context, args ...string) (*
 			fullArgs = append([]string{"--db", dbPath}, fullArgs...)
 		}
 	}
-	return exec.CommandContext(ctx, m.bdPath, fullArgs...), nil
+	return fullArgs
 }
 
 func (m *taskBeadManager) bdCombinedOutput(timeout time.Duration, args ...string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-	cmd, err := m.buildBDCommand(ctx, args...)
-	if err != nil {
-		return "", err
+	if strings.TrimSpace(m.bdPath) == "" {
+		return "", fmt.Errorf("bd not found")
 	}
-	out, err := cmd.CombinedOutput()
+	res, err := m.exec.Run(context.Background(), m.bdPath, m.bdArgs(args...), execx.Options{Timeout: timeout})
 	if err != nil {
-		return "", fmt.Errorf("%s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+		// bd reports some failures on stdout; keep them for IsTransient.
+		if out := res.String(); out != "" {
+			return "", fmt.Errorf("%w: %s", err, out)
+		}
+		return "", err
 	}
-	return string(out), nil
+	return res.Combined(), nil
+}
+
+// bdWrite runs a bd command that changes the store, retrying only when bd
+// reports it could not take the store lock, so a concurrent writer doesn't
+// drop the write. A timed-out write is not retried: it may have committed,
+// and appending the same note twice is worse than missing one.
+func (m *taskBeadManager) bdWrite(timeout time.Duration, args ...string) (string, error) {
+	return m.bdRetry.Do(func() (string, error) {
+		return m.bdCombinedOutput(timeout, args...)
+	})
 }
 
 func (m *taskBeadManager) appendToBead(beadID, message string) error {
-	_, err := m.bdCombinedOutput(15*time.Second, "update", beadID, "--append-notes", message)
+	_, err := m.bdWrite(15*time.Second, "update", beadID, "--append-notes", message)
 	return err
 }
 
@@ -257,7 +287,7 @@ func (m *taskBeadManager) beadBody(beadID string) (string, error) {
 }
 
 func (m *taskBeadManager) updateBeadStatus(beadID, status string) error {
-	_, err := m.bdCombinedOutput(15*time.Second, "update", beadID, "--status", status)
+	_, err := m.bdWrite(15*time.Second, "update", beadID, "--status", status)
 	return err
 }
 
@@ -268,38 +298,30 @@ func (m *taskBeadManager) createTaskBead(target, sender, message string, now tim
 		"--type", "task",
 		// bd create defaults new task beads to open.
 		"--title", title,
-		"--label", "role:" + target,
-		"--label", "from:" + sender,
-		"--label", "repo:" + m.repo,
-		"--label", "source:relay_task",
-		"--description", message,
-	}
-	out, err := m.bdCombinedOutput(20*time.Second, args...)
-	if err != nil {
-		return "", err
 	}
-	// Parse bead ID from output like "✓ Created issue: party-awcft"
-	for _, line := range strings.Split(out, "\n") {
-		line = strings.TrimSpace(line)
-		if strings.Contains(line, "Created issue:") || strings.Contains(line, "Created") {
-			parts := strings.Fields(line)
-			for i, p := range parts {
-				if p == "issue:" && i+1 < len(parts) {
-					return parts[i+1], nil
-				}
-			}
+	labelSet := labels.NewLabelSet().
+		Add(labels.KeyRole, target).
+		Add("from", sender).
+		Add(labels.KeyRepo, m.repo).
+		Add(labels.KeySource, "relay_task")
+	if report := labelSet.Enforce(m.labelLimits); report.Exceeded() {
+		log.Printf("%s role=%s from=%s: %s", logpkg.EventTypeLabelLimit, target, sender, report)
+		if m.events != nil {
+			_ = m.events.Log(logpkg.NewEvent(logpkg.EventTypeLabelLimit, sender, target).WithError(report.String()))
 		}
 	}
-	// Fallback: first non-empty token
-	fields := strings.Fields(out)
-	if len(fields) == 0 {
-		return "", fmt.Errorf("bd create returned empty bead id: %s", out)
+	args = append(args, labelSet.Args()...)
+	args = append(args, "--description", m.redactor.Redact(message))
+	args = append(args, "--json")
+	out, err := m.bdWrite(20*time.Second, args...)
+	if err != nil {
+		return "", err
 	}
-	return fields[0], nil
+	return beads.ParseCreatedID(out)
 }
 
 func (m *taskBeadManager) noteForMessage(envFrom, envTo, payload string, now time.Time) string {
-	return fmt.Sprintf("[%s] %s -> %s\n%s", now.UTC().Format(time.RFC3339), envFrom, envTo, payload)
+	return fmt.Sprintf("[%s] %s -> %s\n%s", now.UTC().Format(time.RFC3339), envFrom, envTo, m.redactor.Redact(payload))
 }
 
 func (m *taskBeadManager) classifierContext(taskBody, response string) string {
@@ -307,7 +329,7 @@ func (m *taskBeadManager) classifierContext(taskBody, response string) string {
 	if base == "" {
 		base = "(task body unavailable)"
 	}
-	return base + "\n---\nResponse: " + strings.TrimSpace(response)
+	return m.redactor.Redact(base + "\n---\nResponse: " + strings.TrimSpace(response))
 }
 
 func (m *taskBeadManager) touchStateIfMatchLocked(role, beadID string, msgSeq int, status string) bool {
@@ -327,6 +349,16 @@ func (m *taskBeadManager) touchStateIfMatchLocked(role, beadID string, msgSeq in
 	return true
 }
 
+// activityAge is the skew-bounded age of a persisted LastActivity, so a
+// clock jump neither stales every bead nor keeps one fresh forever.
+func (m *taskBeadManager) activityAge(now, last time.Time) time.Duration {
+	age, bucket := m.clock.Age(now, last)
+	if bucket != clockskew.Normal {
+		log.Printf("task bead activity timestamp %s is %s relative to now; using age %s", last.Format(time.RFC3339), bucket, age)
+	}
+	return age
+}
+
 func (m *taskBeadManager) sweepStaleActiveBeads() {
 	now := time.Now()
 	for _, role := range []string{"cc", "cx"} {
@@ -341,7 +373,7 @@ func (m *taskBeadManager) sweepStaleActiveBeads() {
 			continue
 		}
 		last, parseErr := time.Parse(time.RFC3339, state.LastActivity)
-		if parseErr != nil || now.Sub(last) < taskBeadFreshWindow {
+		if parseErr != nil || m.activityAge(now, last) < taskBeadFreshWindow {
 			m.stateMu.Unlock()
 			continue
 		}
@@ -356,16 +388,15 @@ func (m *taskBeadManager) sweepStaleActiveBeads() {
 }
 
 func (m *taskBeadManager) classifyAsync(req *classifierRequest) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), classifierPromptTimeout)
-	defer cancel()
 	prompt := "Classify this response to a task assignment. Default to in_progress if ambiguous. Reply with exactly one word: in_progress, blocked, or completed."
-	cmd := exec.CommandContext(ctx, "codex", "exec", "--skip-git-repo-check", "--full-auto", prompt)
-	cmd.Stdin = strings.NewReader(req.Context)
-	out, err := cmd.CombinedOutput()
+	res, err := m.exec.Run(context.Background(), "codex", []string{"exec", "--skip-git-repo-check", "--full-auto", prompt}, execx.Options{
+		Timeout: classifierPromptTimeout,
+		Stdin:   req.Context,
+	})
 	if err != nil {
-		return "", fmt.Errorf("codex classifier: %w: %s", err, strings.TrimSpace(string(out)))
+		return "", fmt.Errorf("codex classifier: %w", err)
 	}
-	return parseClassifierStatus(string(out)), nil
+	return parseClassifierStatus(res.Combined()), nil
 }
 
 func (m *taskBeadManager) scheduleClassifier(role string, req *classifierRequest) {
@@ -433,7 +464,7 @@ func (m *taskBeadManager) handleOCToAgent(envFrom, envTo, payload string) error
 
 	if state != nil {
 		last, parseErr := time.Parse(time.RFC3339, state.LastActivity)
-		if parseErr != nil || now.Sub(last) >= taskBeadFreshWindow {
+		if parseErr != nil || m.activityAge(now, last) >= taskBeadFreshWindow {
 			if staleErr := m.updateBeadStatus(state.BeadID, classifierStatusStale); staleErr != nil {
 				log.Printf("task bead stale update warning role=%s bead=%s: %v", envTo, state.BeadID, staleErr)
 			} else {
@@ -557,6 +588,20 @@ func main() {
 		}
 		return
 	}
+	if len(os.Args) > 1 && os.Args[1] == "--lint-message" {
+		if err := runLintMessage(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "--replay" {
+		if err := runReplay(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	cfg, err := cfgpkg.Load()
 	if err != nil {
@@ -575,7 +620,7 @@ func main() {
 		os.Getpid(),
 		os.Getppid(),
 		cfg.StateDir,
-		cfg.InboxDir,
+		strings.Join(append([]string{cfg.InboxDir}, cfg.InboxExtraDirs...), string(os.PathListSeparator)),
 		cfg.PaneMapPath,
 		buildInfo,
 	)
@@ -624,28 +669,109 @@ func main() {
 	}
 
 	logger := logpkg.NewEventLog(cfg.LogDir)
+	diskGuard := diskguard.New(uint64(cfg.MinFreeMB)*1024*1024, nil, cfg.StateDir, cfg.LogDir)
+	diskGuard.OnChange(func(st diskguard.Status) {
+		if st.Degraded {
+			detail := fmt.Sprintf("%s has %d MB free (minimum %d MB)", st.Path, st.Free/(1024*1024), cfg.MinFreeMB)
+			log.Printf("CRITICAL: low disk space, suspending event log: %s", detail)
+			_ = logger.Log(logpkg.NewEvent(logpkg.EventTypeDiskLow, "relay", "").WithError(detail))
+			logger.SetSuspended(true)
+			return
+		}
+		logger.SetSuspended(false)
+		log.Printf("disk space recovered on %s, event log resumed", st.Path)
+	})
+	diskGuard.Check()
 	mux := tmuxpkg.New()
 	repo := "unknown"
-	if gitRoot, err := exec.Command("git", "rev-parse", "--show-toplevel").Output(); err == nil {
-		repo = filepath.Base(strings.TrimSpace(string(gitRoot)))
+	if gitRoot, err := execx.RunWithTimeout(context.Background(), "git", []string{"rev-parse", "--show-toplevel"}, 5*time.Second); err == nil {
+		repo = filepath.Base(gitRoot.String())
 	} else if cwd, err := os.Getwd(); err == nil {
 		repo = filepath.Base(cwd)
 	}
-	taskBeads := newTaskBeadManager(cfg.StateDir, repo)
+	redactor, err := redact.FromEnv()
+	if err != nil {
+		log.Printf("warning: redaction patterns: %v (using defaults)", err)
+		redactor = redact.Default()
+	}
+	taskBeads := newTaskBeadManager(cfg.StateDir, repo, redactor)
+	taskBeads.labelLimits = labels.Limits{MaxCustom: cfg.LabelMaxCustom, MaxValueLen: cfg.LabelMaxValueLen}
+	taskBeads.bdRetry = beads.RetryPolicy{Attempts: cfg.BDRetryAttempts, BaseDelay: cfg.BDRetryBaseDelay, MaxDelay: cfg.BDRetryMaxDelay}
+	taskBeads.events = logger
+	clockPolicy := clockskew.Policy{Tolerance: cfg.ClockSkewTolerance, MaxAge: cfg.MaxTimestampAge}
+	taskBeads.clock = clockPolicy
+	paneHistory := panehistory.New(cfg.StateDir)
+	paneSource := panehistory.SourceBootstrap
 	if err := cfg.LoadPaneMap(); err != nil {
+		var dupErr *cfgpkg.DuplicatePaneError
+		if errors.As(err, &dupErr) {
+			log.Fatalf("%v (RELAY_PANE_MAP_STRICT is set)", err)
+		}
 		log.Printf("warning: could not load pane map: %v (using defaults)", err)
 		cfg.PaneTargets = map[string]string{"oc": "%0", "cc": "%1", "cx": "%2"}
+		paneSource = panehistory.SourceDefaults
+	}
+	if _, _, err := paneHistory.Record(paneSource, nil, cfg.PaneTargets); err != nil {
+		log.Printf("pane map history write failed: %v", err)
+	}
+	for _, warning := range cfg.PaneMapWarnings {
+		log.Printf("WARNING: %s", warning)
+		_ = logger.Log(logpkg.NewEvent(logpkg.EventTypePaneMapWarning, "relay", "").WithError(warning))
+	}
+	if missing := routing.UnmappedRoles(cfg.PaneTargets); len(missing) > 0 {
+		log.Printf("roles without a pane target: %s (they can send, but messages to them are rejected until mapped)", strings.Join(missing, ", "))
 	}
 	injector := tmuxpkg.NewInjector(mux, cfg.PaneTargets)
 	injector.SetLogger(logger)
 	injector.SetPromptGating(cfg.PromptGating)
+	injector.SetPromptPrefixes(cfg.PromptPrefixes)
+	if err := injector.SetPromptRegex(cfg.PromptRegex); err != nil {
+		log.Fatalf("RELAY_PROMPT_REGEX: %v", err)
+	}
 	injector.SetQueueMaxAge(cfg.QueueMaxAge)
+	injector.SetMinInjectInterval(cfg.InjectMinInterval)
+	injector.SetDedupWindow(cfg.InjectDedupWindow)
+	injector.SetMaxSendAttempts(cfg.InjectMaxAttempts)
+	injector.SetGateAdmin(cfg.Admin.Gate)
+	if reg, err := capability.Load(cfg.CapabilitiesPath); err != nil {
+		log.Printf("warning: could not load capabilities: %v (using defaults)", err)
+	} else {
+		injector.SetCapabilities(reg)
+	}
+	for target, n := range cfg.ReadinessLines {
+		injector.SetReadinessLines(target, n)
+	}
+	injector.SetDeliveryHook(func(env *envelope.Envelope, deliveredAt time.Time) {
+		receipt := envelope.NewReceipt(env, deliveredAt)
+		if receipt == nil {
+			return
+		}
+		_ = logger.Log(logpkg.NewEvent(logpkg.EventTypeReceipt, env.From, env.To).WithMsgID(env.MsgID))
+		if _, ok := injector.Targets()[receipt.To]; !ok {
+			return
+		}
+		if err := injector.Inject(receipt); err != nil {
+			_ = logger.Log(logpkg.NewEvent("error", receipt.From, receipt.To).WithMsgID(receipt.MsgID).WithError(err.Error()))
+		}
+	})
+
+	authMatrix, err := routing.LoadAuthMatrix(cfg.AuthMatrixPath)
+	if err != nil {
+		log.Fatalf("auth matrix: %v", err)
+	}
+	deadLetters := deadletter.New(filepath.Join(cfg.StateDir, "dead-letter"))
+	// Dropped messages are kept so relay-daemon --replay can resend them.
+	injector.SetDropHook(func(env *envelope.Envelope, reason string) {
+		writeDeadLetter(deadLetters, env, env.To, reason)
+	})
+	inboxRouter := newRouter(injector, cfg.Admin, authMatrix, deadLetters, logger)
 
 	agents := state.NewAgentTracker(cfg.StateDir)
 	if err := agents.Load(); err != nil {
 		log.Printf("warning: failed to load agent state: %v", err)
 	}
 	attacks := state.NewAttackWatcher(cfg.AttacksDir)
+	attacks.SetClockPolicy(clockPolicy)
 	nagger := supervisor.NewNagger(attacks, injector, logger, cfg.StuckThreshold, cfg.NagInterval, cfg.MaxNagDuration)
 	recovery := supervisor.NewRecoveryHandler(injector, logger)
 	super := supervisor.NewSupervisor(agents, attacks, nagger, recovery, 60*time.Second)
@@ -654,16 +780,43 @@ func main() {
 		paneTailer = supervisor.NewPaneTailer(mux, cfg.PaneTargets, cfg.PaneTailLines, cfg.PaneTailRotations, cfg.PaneTailDir, cfg.PaneTailInterval, logger)
 	}
 
-	watcher, err := inbox.NewWatcher(cfg.InboxDir)
+	watcher, err := inbox.NewMultiRootWatcher(append([]string{cfg.InboxDir}, cfg.InboxExtraDirs...))
 	if err != nil {
 		log.Fatalf("watcher: %v", err)
 	}
 	defer watcher.Close()
+	watcher.SetValidAgents(inboxAgents(cfg.PaneTargets, cfg.ExtraAgents))
+	watcher.SetEventLog(logger)
 	if offsets, err := inbox.LoadOffsets(filepath.Join(cfg.StateDir, "offsets.json")); err != nil {
 		log.Printf("warning: failed to load offsets: %v", err)
 	} else {
 		watcher.SetOffsets(offsets)
 	}
+	hashesPath := filepath.Join(cfg.StateDir, "offsets.hashes.json")
+	if cfg.InboxContentHash {
+		hashes, err := inbox.LoadHashes(hashesPath)
+		if err != nil {
+			log.Printf("warning: failed to load inbox hashes: %v", err)
+		}
+		watcher.EnableContentHashing(hashes)
+	}
+	queuesPath := filepath.Join(cfg.StateDir, tmuxpkg.QueueFileName)
+	if skipped, err := injector.LoadQueues(queuesPath); err != nil {
+		log.Printf("warning: failed to load saved queues: %v", err)
+	} else if len(skipped) > 0 {
+		log.Printf("WARNING: saved queues for unmapped targets dead-lettered: %s", strings.Join(skipped, ", "))
+	}
+	stateBundle := &statebundle.StateBundle{Injector: injector, Watcher: watcher}
+	if dir := cfg.StateBundleImportDir; dir != "" {
+		if err := stateBundle.Import(dir); err != nil {
+			log.Printf("warning: state bundle import from %s failed: %v", dir, err)
+		} else {
+			log.Printf("state bundle imported from %s", dir)
+			if len(stateBundle.Skipped) > 0 {
+				log.Printf("WARNING: state bundle queues for unmapped targets dead-lettered: %s", strings.Join(stateBundle.Skipped, ", "))
+			}
+		}
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -700,6 +853,7 @@ func main() {
 	// Hot-reload panes.json when it changes on disk.
 	runProtected("pane-map-reload", func() error {
 		var lastMod time.Time
+		lastRegistered := cfg.PaneMapRegisteredAt
 		if info, err := os.Stat(cfg.PaneMapPath); err == nil {
 			lastMod = info.ModTime()
 		}
@@ -721,24 +875,50 @@ func main() {
 				}
 
 				lastMod = info.ModTime()
-				targets, err := cfgpkg.ReadPaneMap(cfg.PaneMapPath)
+				paneMap, err := cfgpkg.ReadPaneMapFile(cfg.PaneMapPath)
 				if err != nil {
 					log.Printf("pane map reload failed: %v", err)
 					continue
 				}
+				targets := paneMap.Targets
+				for _, skipped := range paneMap.Skipped {
+					log.Printf("WARNING: %s", skipped)
+					_ = logger.Log(logpkg.NewEvent(logpkg.EventTypePaneMapWarning, "relay", "").WithError(skipped))
+				}
+				if err := cfgpkg.CheckDuplicatePanes(targets); err != nil {
+					_ = logger.Log(logpkg.NewEvent(logpkg.EventTypePaneMapWarning, "relay", "").WithError(err.Error()))
+					if cfg.StrictPaneMap {
+						log.Printf("pane map reload rejected: %v", err)
+						continue
+					}
+					log.Printf("WARNING: %v", err)
+				}
+				source := panehistory.SourceReload
+				if paneMap.RegisteredAt != "" && paneMap.RegisteredAt != lastRegistered {
+					source = panehistory.SourceRegister
+				}
+				lastRegistered = paneMap.RegisteredAt
+				if _, _, err := paneHistory.Record(source, injector.Targets(), targets); err != nil {
+					log.Printf("pane map history write failed: %v", err)
+				}
 				injector.UpdateTargets(targets)
+				// A newly mapped role needs its outbox read as well.
+				watcher.SetValidAgents(inboxAgents(targets, cfg.ExtraAgents))
 				log.Printf("pane map reloaded: %v", targets)
 			}
 		}
 	})
 
+	runProtected("disk-guard", func() error {
+		diskGuard.Run(ctx, cfg.DiskCheckInterval)
+		return nil
+	})
 	runProtected("watcher", func() error {
 		return watcher.Start(ctx)
 	})
-	runProtected("injector", func() error {
-		injector.Start(ctx)
-		return nil
-	})
+	// Started directly rather than under runProtected so the state save on
+	// exit can wait for the queues it starts.
+	injector.Start(ctx)
 	runProtected("supervisor", func() error {
 		return super.Start(ctx)
 	})
@@ -761,16 +941,70 @@ func main() {
 		})
 	}
 
-	go func() {
-		<-ctx.Done()
+	// The heartbeat channel stays nil, and never fires, without a status server.
+	var heartbeat <-chan time.Time
+	beat := func() {}
+	if cfg.StatusAddr != "" {
+		statusServer := status.New(cfg.StatusAddr, func() status.Snapshot {
+			targets := injector.Targets()
+			snap := status.Snapshot{
+				StartedAt:     startedAt.UTC(),
+				PaneTargets:   targets,
+				QueueDepths:   injector.QueueDepths(),
+				LastInject:    injector.LastInjected(),
+				UnmappedRoles: routing.UnmappedRoles(targets),
+			}
+			if err := cfgpkg.CheckDuplicatePanes(targets); err != nil {
+				snap.PaneWarnings = append(snap.PaneWarnings, err.Error())
+			}
+			if history, err := paneHistory.Recent(10); err == nil {
+				snap.PaneHistory = history
+			}
+			return snap
+		})
+		statusServer.Beat()
+		ticker := time.NewTicker(status.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+		beat = statusServer.Beat
+		runProtected("status-server", func() error {
+			// A status server failure is not worth stopping delivery for.
+			if err := statusServer.Run(ctx); err != nil {
+				log.Printf("status server stopped: %v", err)
+			}
+			return nil
+		})
+		log.Printf("status server listening on %s", cfg.StatusAddr)
+	}
+
+	// Every return from main saves state before the lock is released: the
+	// queues are stopped first so the snapshot is not racing deliveries.
+	defer func() {
+		cancel()
+		injector.Wait()
 		offsetPath := filepath.Join(cfg.StateDir, "offsets.json")
 		if err := watcher.SaveOffsets(offsetPath); err != nil {
 			log.Printf("warning: failed to save offsets: %v", err)
 		}
+		if err := watcher.SaveHashes(hashesPath); err != nil {
+			log.Printf("warning: failed to save inbox hashes: %v", err)
+		}
+		if err := injector.SaveQueues(queuesPath); err != nil {
+			log.Printf("warning: failed to save queues: %v", err)
+		}
+		if dir := cfg.StateBundleExportDir; dir != "" {
+			if err := stateBundle.Export(dir); err != nil {
+				log.Printf("warning: state bundle export to %s failed: %v", dir, err)
+			} else {
+				log.Printf("state bundle exported to %s", dir)
+			}
+		}
 	}()
 
 	for {
 		select {
+		case <-heartbeat:
+			beat()
 		case err := <-errCh:
 			if err != nil {
 				reason := "error"
@@ -824,37 +1058,87 @@ func main() {
 				}()
 			}
 
+			inboxRouter.route(env)
+		}
+	}
+}
 
-			// Handle broadcast to all agents (including admin if present)
-			if env.To == "all" {
-				broadcastTargets := []string{"oc", "cc", "cx"}
-				if _, ok := cfg.PaneTargets["admin"]; ok {
-					broadcastTargets = append(broadcastTargets, "admin")
-				}
-				for _, target := range broadcastTargets {
-					cloned := *env
-					cloned.To = target
-					if err := injector.Inject(&cloned); err != nil {
-						_ = logger.Log(logpkg.NewEvent("error", env.From, target).WithMsgID(env.MsgID).WithError(err.Error()))
-						continue
-					}
-				}
-				continue
-			}
+// router delivers envelopes read from the inbox to their pane targets.
+type router struct {
+	injector    *tmuxpkg.Injector
+	admin       cfgpkg.AdminPolicy
+	authMatrix  routing.AuthMatrix
+	deadLetters *deadletter.Writer
+	logger      *logpkg.EventLog
+	threads     *routing.ThreadMembers
+}
 
-			// Admin-destined messages: forward to pane
-			if env.To == "admin" {
-				if err := injector.Inject(env); err != nil {
-					_ = logger.Log(logpkg.NewEvent("error", env.From, "admin").WithMsgID(env.MsgID).WithError(err.Error()))
-				}
-				continue
-			}
+func newRouter(injector *tmuxpkg.Injector, admin cfgpkg.AdminPolicy, authMatrix routing.AuthMatrix, deadLetters *deadletter.Writer, logger *logpkg.EventLog) *router {
+	return &router{
+		injector:    injector,
+		admin:       admin,
+		authMatrix:  authMatrix,
+		deadLetters: deadLetters,
+		logger:      logger,
+		threads:     routing.NewThreadMembers(routing.DefaultMaxThreads, routing.DefaultThreadMemberTTL),
+	}
+}
 
-			// Standard message routing via injector
-			if err := injector.Inject(env); err != nil {
-				_ = logger.Log(logpkg.NewEvent("error", env.From, env.To).WithMsgID(env.MsgID).WithError(err.Error()))
+// route resolves env's delivery targets (thread, broadcast, admin policy,
+// direct) and queues a copy for each. Envelopes that can never be
+// delivered as addressed are dead-lettered rather than dropped.
+func (r *router) route(env *envelope.Envelope) {
+	r.threads.Observe(env)
+	targets, err := r.targets(env)
+	if err != nil {
+		_ = r.logger.Log(logpkg.NewEvent("error", env.From, env.To).WithMsgID(env.MsgID).WithError(err.Error()))
+		var unmapped *routing.UnmappedTargetError
+		switch {
+		case errors.As(err, &unmapped):
+			writeDeadLetter(r.deadLetters, env, env.To, "unknown_target")
+		case errors.Is(err, routing.ErrNoThreadMembers):
+			writeDeadLetter(r.deadLetters, env, env.To, "empty_thread")
+		}
+		return
+	}
+	threadID, toThread := routing.ThreadID(env.To)
+	for _, target := range targets {
+		if err := r.authMatrix.Authorize(env, target); err != nil {
+			_ = r.logger.Log(logpkg.NewEvent(logpkg.EventTypeUnauthorized, env.From, target).WithMsgID(env.MsgID).WithError(err.Error()))
+			writeDeadLetter(r.deadLetters, env, target, "unauthorized")
+			continue
+		}
+		routed := env
+		if target != env.To {
+			cloned := *env
+			cloned.To = target
+			if toThread && cloned.ThreadID == "" {
+				cloned.ThreadID = threadID
 			}
+			routed = &cloned
 		}
+		if err := r.injector.Inject(routed); err != nil {
+			_ = r.logger.Log(logpkg.NewEvent("error", env.From, target).WithMsgID(env.MsgID).WithError(err.Error()))
+			switch {
+			case errors.Is(err, tmuxpkg.ErrUnknownTarget):
+				writeDeadLetter(r.deadLetters, routed, target, "unknown_target")
+			case errors.Is(err, tmuxpkg.ErrInvalidEnvelope):
+				writeDeadLetter(r.deadLetters, routed, target, "invalid")
+			}
+		}
+	}
+}
+
+func (r *router) targets(env *envelope.Envelope) ([]string, error) {
+	if threadID, ok := routing.ThreadID(env.To); ok {
+		return routing.ThreadTargets(threadID, env.From, r.threads, r.injector.Targets())
+	}
+	return routing.Targets(env.To, r.injector.Targets(), r.admin)
+}
+
+func writeDeadLetter(deadLetters *deadletter.Writer, env *envelope.Envelope, target, reason string) {
+	if err := deadLetters.Write(env, target, reason); err != nil {
+		log.Printf("dead-letter write failed for %s: %v", env.MsgID, err)
 	}
 }
 
@@ -938,3 +1222,88 @@ func runPaneStatus(args []string) error {
 	enc.SetIndent("", "  ")
 	return enc.Encode(output)
 }
+
+// runLintMessage validates an RMF v2 message file (or stdin when the path is
+// "-" or omitted) and reports the defaults the parser would apply.
+func runLintMessage(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: relay-daemon --lint-message [path|-]")
+	}
+	var data []byte
+	var err error
+	if len(args) == 0 || args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("read message: %w", err)
+	}
+
+	env, warnings, err := inbox.ValidateMessageBytes(data)
+	for _, w := range warnings {
+		fmt.Printf("warning: %s\n", w)
+	}
+	if err != nil {
+		return fmt.Errorf("error: %w", err)
+	}
+	fmt.Printf("ok: to=%s kind=%s msg_id=%s priority=%d\n", env.To, env.Kind, env.MsgID, env.Priority)
+	return nil
+}
+
+// runReplay finds messages the event log shows were never delivered and
+// writes those whose envelopes were dead-lettered back into the sender's
+// inbox with a fresh msg_id.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	since := fs.Duration("since", 24*time.Hour, "only replay messages logged within this window")
+	dryRun := fs.Bool("dry-run", false, "list what would be replayed without writing")
+	includeExpired := fs.Bool("include-expired", false, "also replay messages dropped after their own expiry passed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: relay-daemon --replay [--since DURATION] [--dry-run] [--include-expired]")
+	}
+
+	cfg, err := cfgpkg.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	logger := logpkg.NewEventLog(cfg.LogDir)
+	candidates, err := replay.Find(
+		filepath.Join(cfg.LogDir, "events.jsonl"),
+		filepath.Join(cfg.StateDir, "dead-letter", deadletter.FileName),
+		time.Now().Add(-*since),
+	)
+	if err != nil {
+		return err
+	}
+	for _, c := range candidates {
+		if c.Envelope == nil {
+			fmt.Printf("skipped %s to=%s (%s): payload not retained\n", c.MsgID, c.Target, c.Event)
+			continue
+		}
+		if c.Expired && !*includeExpired {
+			fmt.Printf("skipped %s to=%s (%s): past its own expiry, pass --include-expired to resend\n", c.MsgID, c.Target, c.Event)
+			continue
+		}
+		if *dryRun {
+			fmt.Printf("would replay %s to=%s (%s)\n", c.MsgID, c.Target, c.Event)
+			continue
+		}
+		path, err := replay.Write(cfg.InboxDir, c.Envelope)
+		if err != nil {
+			return fmt.Errorf("replay %s: %w", c.MsgID, err)
+		}
+		if err := replay.Record(logger, c); err != nil {
+			return fmt.Errorf("record replay of %s: %w", c.MsgID, err)
+		}
+		fmt.Printf("replayed %s to=%s (%s) -> %s\n", c.MsgID, c.Target, c.Event, path)
+	}
+	return nil
+}
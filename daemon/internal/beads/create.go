@@ -0,0 +1,66 @@
+package beads
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// createdBead is the part of `bd create --json` output the daemon needs.
+type createdBead struct {
+	ID string `json:"id"`
+}
+
+// ParseCreatedID extracts the new bead ID from `bd create --json` output.
+// Log lines bd prints around the JSON object are ignored. Output that is
+// not JSON (older bd, or --json unsupported) falls back to the text form,
+// e.g. "✓ Created issue: party-awcft".
+func ParseCreatedID(out string) (string, error) {
+	if id := parseCreatedJSON(out); id != "" {
+		return id, nil
+	}
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.Fields(line)
+		for i, p := range parts {
+			if p == "issue:" && i+1 < len(parts) {
+				return parts[i+1], nil
+			}
+		}
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("bd create returned empty bead id: %s", out)
+	}
+	return fields[0], nil
+}
+
+func parseCreatedJSON(out string) string {
+	for start := strings.IndexAny(out, "{["); start >= 0; {
+		dec := json.NewDecoder(strings.NewReader(out[start:]))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == nil {
+			if id := createdIDFromJSON(raw); id != "" {
+				return id
+			}
+		}
+		next := strings.IndexAny(out[start+1:], "{[")
+		if next < 0 {
+			break
+		}
+		start += 1 + next
+	}
+	return ""
+}
+
+// createdIDFromJSON accepts a single object or an array of them.
+func createdIDFromJSON(raw json.RawMessage) string {
+	var bead createdBead
+	if err := json.Unmarshal(raw, &bead); err == nil {
+		return strings.TrimSpace(bead.ID)
+	}
+	var list []createdBead
+	if err := json.Unmarshal(raw, &list); err == nil && len(list) > 0 {
+		return strings.TrimSpace(list[0].ID)
+	}
+	return ""
+}
@@ -0,0 +1,26 @@
+package beads
+
+import "testing"
+
+func TestParseCreatedID(t *testing.T) {
+	cases := []struct {
+		name string
+		out  string
+		want string
+	}{
+		{"json", `{"id":"party-abc12","title":"cc task","status":"open"}`, "party-abc12"},
+		{"json after log line", "Warning: daemon not running\n{\"id\":\"party-abc12\",\"labels\":[\"role:cc\"]}\n", "party-abc12"},
+		{"json array", `[{"id":"party-abc12"}]`, "party-abc12"},
+		{"text", "✓ Created issue: party-awcft\n", "party-awcft"},
+		{"bare id", "party-xyz\n", "party-xyz"},
+	}
+	for _, tc := range cases {
+		got, err := ParseCreatedID(tc.out)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: ParseCreatedID = %q, %v; want %q", tc.name, got, err, tc.want)
+		}
+	}
+	if _, err := ParseCreatedID("  \n"); err == nil {
+		t.Error("expected error for empty output")
+	}
+}
@@ -0,0 +1,39 @@
+package beads
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// PathEnv overrides where the bd binary is found.
+const PathEnv = "RELAY_BD_PATH"
+
+// ResolvePath locates the bd binary: RELAY_BD_PATH if set, then PATH,
+// then the usual install locations under $HOME. An override that does not
+// name an executable is an error rather than a silent fallback.
+func ResolvePath() (string, error) {
+	if override := strings.TrimSpace(os.Getenv(PathEnv)); override != "" {
+		path, err := exec.LookPath(override)
+		if err != nil {
+			return "", fmt.Errorf("%s=%s: %w", PathEnv, override, err)
+		}
+		return path, nil
+	}
+	path, err := exec.LookPath("bd")
+	if err == nil {
+		return path, nil
+	}
+	home := os.Getenv("HOME")
+	for _, p := range []string{
+		filepath.Join(home, "go", "bin", "bd"),
+		filepath.Join(home, ".local", "bin", "bd"),
+	} {
+		if _, statErr := os.Stat(p); statErr == nil {
+			return p, nil
+		}
+	}
+	return "", err
+}
@@ -0,0 +1,48 @@
+package beads
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeExecutable(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolvePathPrecedence(t *testing.T) {
+	home := t.TempDir()
+	pathDir := t.TempDir()
+	override := filepath.Join(t.TempDir(), "bd-custom")
+	writeExecutable(t, filepath.Join(home, "go", "bin", "bd"))
+	writeExecutable(t, filepath.Join(pathDir, "bd"))
+	writeExecutable(t, override)
+	t.Setenv("HOME", home)
+	t.Setenv("PATH", pathDir)
+
+	t.Setenv(PathEnv, override)
+	if got, err := ResolvePath(); err != nil || got != override {
+		t.Fatalf("with override: %q, %v; want %q", got, err, override)
+	}
+
+	t.Setenv(PathEnv, "")
+	if got, err := ResolvePath(); err != nil || got != filepath.Join(pathDir, "bd") {
+		t.Fatalf("from PATH: %q, %v", got, err)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if got, err := ResolvePath(); err != nil || got != filepath.Join(home, "go", "bin", "bd") {
+		t.Fatalf("from go/bin: %q, %v", got, err)
+	}
+
+	t.Setenv(PathEnv, filepath.Join(home, "missing-bd"))
+	if _, err := ResolvePath(); err == nil {
+		t.Fatal("expected error for override naming a missing binary")
+	}
+}
@@ -0,0 +1,90 @@
+// Package beads holds helpers shared by the bd CLI call sites.
+package beads
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// transientMarkers are substrings of bd/sqlite output that indicate bd
+// could not take the store lock, so the write was never applied.
+var transientMarkers = []string{
+	"database is locked",
+	"database table is locked",
+	"sqlite_busy",
+	"sqlite_locked",
+	"resource temporarily unavailable",
+	"resource busy",
+	"lock held",
+}
+
+// IsTransient reports whether err shows bd failed to acquire a locked/busy
+// store, which is safe to retry. Timeouts are permanent: a bd write that ran
+// out of time has often already committed, and retrying it would duplicate
+// the bead or note. Everything else (bad flags, unknown issue IDs, missing
+// binary) is permanent too.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range transientMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryPolicy bounds retries of a bd invocation.
+type RetryPolicy struct {
+	Attempts  int           // total attempts, including the first
+	BaseDelay time.Duration // delay before the second attempt; doubles after
+	MaxDelay  time.Duration // cap on a single delay (0 = uncapped)
+}
+
+// DefaultRetryPolicy returns the policy used for bead creation.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:  4,
+		BaseDelay: 250 * time.Millisecond,
+		MaxDelay:  2 * time.Second,
+	}
+}
+
+// sleep is replaced in tests.
+var sleep = time.Sleep
+
+// Do runs fn until it succeeds, returns a permanent error, or the policy's
+// attempts are exhausted. The final error names the attempt count and
+// whether the failure was transient.
+func (p RetryPolicy) Do(fn func() (string, error)) (string, error) {
+	attempts := p.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := p.BaseDelay
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		out, err := fn()
+		if err == nil {
+			return out, nil
+		}
+		lastErr = err
+		if !IsTransient(err) {
+			return "", fmt.Errorf("bd permanent failure: %w", err)
+		}
+		if attempt == attempts {
+			break
+		}
+		if delay > 0 {
+			sleep(delay)
+			delay *= 2
+			if p.MaxDelay > 0 && delay > p.MaxDelay {
+				delay = p.MaxDelay
+			}
+		}
+	}
+	return "", fmt.Errorf("bd transient failure after %d attempts: %w", attempts, lastErr)
+}
@@ -0,0 +1,75 @@
+package beads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("create: exit status 1: Error: database is locked"), true},
+		{errors.New("SQLITE_BUSY: busy"), true},
+		{errors.New("update: signal: killed"), false},
+		{fmt.Errorf("create: %w", context.DeadlineExceeded), false},
+		{errors.New("create: exit status 1: try again later"), false},
+		{errors.New("create: exit status 1: unknown flag: --bogus"), false},
+		{errors.New("bd not found"), false},
+	}
+	for _, tc := range cases {
+		if got := IsTransient(tc.err); got != tc.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	var delays []time.Duration
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { sleep = time.Sleep }()
+
+	p := RetryPolicy{Attempts: 4, BaseDelay: 10 * time.Millisecond, MaxDelay: 15 * time.Millisecond}
+
+	calls := 0
+	out, err := p.Do(func() (string, error) {
+		calls++
+		if calls < 3 {
+			return "", errors.New("database is locked")
+		}
+		return "ok", nil
+	})
+	if err != nil || out != "ok" {
+		t.Fatalf("Do() = %q, %v; want ok", out, err)
+	}
+	if calls != 3 {
+		t.Fatalf("calls = %d, want 3", calls)
+	}
+	if len(delays) != 2 || delays[0] != 10*time.Millisecond || delays[1] != 15*time.Millisecond {
+		t.Fatalf("delays = %v", delays)
+	}
+
+	calls = 0
+	_, err = p.Do(func() (string, error) {
+		calls++
+		return "", errors.New("unknown flag: --bogus")
+	})
+	if calls != 1 || err == nil || !strings.Contains(err.Error(), "permanent") {
+		t.Fatalf("permanent failure: calls=%d err=%v", calls, err)
+	}
+
+	calls = 0
+	_, err = p.Do(func() (string, error) {
+		calls++
+		return "", errors.New("database is locked")
+	})
+	if calls != 4 || err == nil || !strings.Contains(err.Error(), "after 4 attempts") {
+		t.Fatalf("exhausted: calls=%d err=%v", calls, err)
+	}
+}
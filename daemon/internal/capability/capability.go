@@ -0,0 +1,117 @@
+// Package capability describes what each agent pane supports, so the
+// injector can decide wrapping, gating, and chunking per target instead of
+// special-casing roles.
+package capability
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Prompt styles select how pane readiness is detected.
+const (
+	PromptClaude = "claude" // Claude Code input prompt
+	PromptCodex  = "codex"  // Codex "›" prompt and footer
+	PromptShell  = "shell"  // last line starts with a configured prompt prefix
+	PromptNone   = "none"   // no prompt detection; always ready
+)
+
+// Capabilities describes one agent pane.
+type Capabilities struct {
+	// SupportsSlashCommands injects "/..." payloads bare so the agent parses
+	// them as commands. When false they are wrapped like any message.
+	SupportsSlashCommands bool `json:"supports_slash_commands"`
+	// PromptStyle is one of PromptClaude, PromptCodex, PromptShell,
+	// PromptNone.
+	PromptStyle string `json:"prompt_style"`
+	// MaxInputChars splits messages whose wrapped text is longer into
+	// numbered parts that each fit (0 = no limit).
+	MaxInputChars int `json:"max_input_chars"`
+	// WrapTemplate is a text/template over .From, .To, .Kind, .MsgID and
+	// .Payload (already escaped). Empty uses the <relay-message> wrapper.
+	WrapTemplate string `json:"wrap_template"`
+}
+
+// Validate checks field values.
+func (c Capabilities) Validate() error {
+	switch c.PromptStyle {
+	case PromptClaude, PromptCodex, PromptShell, PromptNone:
+	default:
+		return fmt.Errorf("capability: unknown prompt_style %q", c.PromptStyle)
+	}
+	if c.MaxInputChars < 0 {
+		return fmt.Errorf("capability: negative max_input_chars %d", c.MaxInputChars)
+	}
+	if c.WrapTemplate != "" {
+		if _, err := template.New("wrap").Parse(c.WrapTemplate); err != nil {
+			return fmt.Errorf("capability: wrap_template: %w", err)
+		}
+	}
+	return nil
+}
+
+// claudeDefaults apply to any role without an explicit entry.
+var claudeDefaults = Capabilities{SupportsSlashCommands: true, PromptStyle: PromptClaude}
+
+// Registry maps roles to capabilities.
+type Registry struct {
+	byRole map[string]Capabilities
+}
+
+// DefaultRegistry reproduces the historical behavior: every role is a
+// Claude pane except cx, which runs Codex.
+func DefaultRegistry() *Registry {
+	return &Registry{byRole: map[string]Capabilities{
+		"cx": {SupportsSlashCommands: true, PromptStyle: PromptCodex},
+	}}
+}
+
+// Get returns the capabilities for role. A nil Registry yields defaults.
+func (r *Registry) Get(role string) Capabilities {
+	if r != nil {
+		if c, ok := r.byRole[strings.ToLower(role)]; ok {
+			return c
+		}
+	}
+	return claudeDefaults
+}
+
+// Set overrides the capabilities for role.
+func (r *Registry) Set(role string, c Capabilities) {
+	r.byRole[strings.ToLower(role)] = c
+}
+
+// Load reads a JSON object of role → capabilities from path and layers it
+// over DefaultRegistry. Fields omitted for a role keep that role's default.
+// An empty path or missing file returns the defaults.
+func Load(path string) (*Registry, error) {
+	reg := DefaultRegistry()
+	if path == "" {
+		return reg, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return reg, nil
+		}
+		return reg, err
+	}
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return reg, fmt.Errorf("decode capabilities: %w", err)
+	}
+	for role, entry := range entries {
+		c := reg.Get(role)
+		if err := json.Unmarshal(entry, &c); err != nil {
+			return DefaultRegistry(), fmt.Errorf("decode capabilities for %s: %w", role, err)
+		}
+		if err := c.Validate(); err != nil {
+			return DefaultRegistry(), fmt.Errorf("%s: %w", role, err)
+		}
+		reg.Set(role, c)
+	}
+	return reg, nil
+}
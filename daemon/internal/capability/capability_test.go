@@ -0,0 +1,40 @@
+package capability
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadLayersOverDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capabilities.json")
+	data := `{"cx":{"max_input_chars":4000},"vog":{"supports_slash_commands":false,"prompt_style":"none"}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cx := reg.Get("cx")
+	if cx.PromptStyle != PromptCodex || !cx.SupportsSlashCommands || cx.MaxInputChars != 4000 {
+		t.Fatalf("cx = %+v", cx)
+	}
+	vog := reg.Get("vog")
+	if vog.SupportsSlashCommands || vog.PromptStyle != PromptNone {
+		t.Fatalf("vog = %+v", vog)
+	}
+	if cc := reg.Get("cc"); cc != claudeDefaults {
+		t.Fatalf("cc = %+v, want claude defaults", cc)
+	}
+}
+
+func TestLoadRejectsInvalidEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capabilities.json")
+	if err := os.WriteFile(path, []byte(`{"cc":{"prompt_style":"fish"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected error for unknown prompt_style")
+	}
+}
@@ -0,0 +1,59 @@
+// Package clockskew bounds ages computed from persisted timestamps so a
+// suspend/resume or NTP correction does not make everything look stale (a
+// nag or sweep storm) or nothing look stale (permanent suppression).
+package clockskew
+
+import "time"
+
+// Bucket classifies a timestamp relative to now.
+type Bucket int
+
+const (
+	// Normal timestamps are in the past, or in the future by no more than
+	// the tolerance.
+	Normal Bucket = iota
+	// Future timestamps are ahead of now by more than the tolerance; their
+	// age is treated as zero ("just now").
+	Future
+	// Implausible timestamps are older than MaxAge; their age is capped at
+	// MaxAge so they read as "very old" rather than as a huge number.
+	Implausible
+)
+
+func (b Bucket) String() string {
+	switch b {
+	case Future:
+		return "future"
+	case Implausible:
+		return "implausible"
+	default:
+		return "normal"
+	}
+}
+
+// Policy sets the skew allowances.
+type Policy struct {
+	Tolerance time.Duration // future skew accepted without flagging
+	MaxAge    time.Duration // older is implausible; 0 = no cap
+}
+
+// DefaultPolicy tolerates five minutes of skew and caps ages at 30 days.
+func DefaultPolicy() Policy {
+	return Policy{Tolerance: 5 * time.Minute, MaxAge: 30 * 24 * time.Hour}
+}
+
+// Age returns the bounded age of t at now and how it was classified. It is
+// never negative.
+func (p Policy) Age(now, t time.Time) (time.Duration, Bucket) {
+	age := now.Sub(t)
+	if age < 0 {
+		if -age > p.Tolerance {
+			return 0, Future
+		}
+		return 0, Normal
+	}
+	if p.MaxAge > 0 && age > p.MaxAge {
+		return p.MaxAge, Implausible
+	}
+	return age, Normal
+}
@@ -0,0 +1,34 @@
+package clockskew
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAgeBounds(t *testing.T) {
+	p := Policy{Tolerance: time.Minute, MaxAge: 24 * time.Hour}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name   string
+		at     time.Time
+		age    time.Duration
+		bucket Bucket
+	}{
+		{"past", now.Add(-10 * time.Minute), 10 * time.Minute, Normal},
+		{"small future skew", now.Add(30 * time.Second), 0, Normal},
+		{"clock jumped back", now.Add(6 * time.Hour), 0, Future},
+		{"far past", now.AddDate(-3, 0, 0), 24 * time.Hour, Implausible},
+	}
+	for _, tc := range cases {
+		age, bucket := p.Age(now, tc.at)
+		if age != tc.age || bucket != tc.bucket {
+			t.Errorf("%s: Age = %s, %s; want %s, %s", tc.name, age, bucket, tc.age, tc.bucket)
+		}
+	}
+
+	uncapped := Policy{Tolerance: time.Minute}
+	if age, bucket := uncapped.Age(now, now.AddDate(-3, 0, 0)); bucket != Normal || age < 365*24*time.Hour {
+		t.Fatalf("uncapped far past = %s, %s", age, bucket)
+	}
+}
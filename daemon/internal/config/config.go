@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/norm/relay-daemon/internal/beads"
+	"github.com/norm/relay-daemon/internal/tmux"
 )
 
 // Config holds relay daemon configuration.
@@ -32,31 +36,122 @@ type Config struct {
 	PaneTailDir         string
 	PaneMapVersion      int
 	PaneMapRegisteredAt string
+	Admin               AdminPolicy
+	ReadinessLines      map[string]int
+	MinFreeMB           int
+	DiskCheckInterval   time.Duration
+	// StrictPaneMap rejects pane maps that assign one pane to several roles;
+	// otherwise the duplicates are recorded in PaneMapWarnings, as are roles
+	// skipped for a malformed pane id.
+	StrictPaneMap   bool
+	PaneMapWarnings []string
+	// CapabilitiesPath is a JSON file of per-role agent capabilities.
+	CapabilitiesPath string
+	// InboxContentHash detects rewritten inbox files by content hash
+	// instead of size.
+	InboxContentHash bool
+	// AuthMatrixPath is a JSON from→to→kinds allowlist; unset is permissive.
+	AuthMatrixPath string
+	// LabelMaxCustom and LabelMaxValueLen cap non-canonical labels and
+	// label value bytes on beads the daemon creates (0 = unlimited).
+	LabelMaxCustom   int
+	LabelMaxValueLen int
+	// ClockSkewTolerance and MaxTimestampAge bound ages computed from
+	// persisted timestamps (see clockskew.Policy).
+	ClockSkewTolerance time.Duration
+	MaxTimestampAge    time.Duration
+	// StateBundleImportDir, when set, restores queues and offsets from a
+	// state bundle at startup; StateBundleExportDir writes one on shutdown.
+	StateBundleImportDir string
+	StateBundleExportDir string
+	// InjectMinInterval is the minimum gap between injections into one
+	// pane (0 = no pacing).
+	InjectMinInterval time.Duration
+	// StatusAddr, when set, serves /healthz and /status on this address.
+	StatusAddr string
+	// BDRetryAttempts, BDRetryBaseDelay and BDRetryMaxDelay bound retries
+	// of bd writes that fail on a locked or busy store (see
+	// beads.RetryPolicy).
+	BDRetryAttempts  int
+	BDRetryBaseDelay time.Duration
+	BDRetryMaxDelay  time.Duration
+	// InboxExtraDirs are further inbox roots watched alongside InboxDir,
+	// e.g. other pods' outbox trees (RELAY_INBOX_EXTRA_DIRS, a
+	// path-list-separated list).
+	InboxExtraDirs []string
+	// ExtraAgents are outbox directories accepted as agents in addition to
+	// the known roles and the pane map's roles (RELAY_EXTRA_AGENTS,
+	// comma-separated).
+	ExtraAgents []string
+	// InjectDedupWindow is how many recent messages the injector remembers
+	// to drop a repeated MsgID to the same target (0 = no dedup).
+	InjectDedupWindow int
+	// PromptPrefixes replace pane.DefaultPromptPrefixes for panes with the
+	// shell prompt style (RELAY_PROMPT_PREFIXES, comma-separated).
+	PromptPrefixes []string
+	// PromptRegex, when set, is matched against the captured pane tail for
+	// shell-style panes instead of PromptPrefixes (RELAY_PROMPT_REGEX).
+	PromptRegex string
+	// InjectMaxAttempts is how many failed pastes or pane queries a message
+	// gets before it is dead-lettered (0 = retry forever).
+	InjectMaxAttempts int
+}
+
+// AdminPolicy controls how the admin pane participates in routing.
+// The defaults match the historical special-casing of admin.
+type AdminPolicy struct {
+	// Gate applies prompt gating to admin. Admin runs Claude, not a shell,
+	// so it is not gated by default.
+	Gate bool
+	// Broadcast includes admin in "all" broadcasts when it has a pane.
+	Broadcast bool
+	// Direct delivers admin-destined messages to the admin pane. When false
+	// they are rejected instead of injected.
+	Direct bool
+}
+
+// DefaultAdminPolicy returns the historical admin routing behavior.
+func DefaultAdminPolicy() AdminPolicy {
+	return AdminPolicy{Gate: false, Broadcast: true, Direct: true}
 }
 
 // Default returns the default configuration.
 func Default() *Config {
 	home, _ := os.UserHomeDir()
 	shareDir := filepath.Join(home, "llm-share")
+	bdRetry := beads.DefaultRetryPolicy()
 	return &Config{
-		ShareDir:          "",
-		InboxDir:          filepath.Join(home, ".local", "share", "relay", "outbox"),
-		LogDir:            "",
-		StateDir:          "",
-		AttacksDir:        filepath.Join(shareDir, "attacks"),
-		StuckThreshold:    5 * time.Minute,
-		NagInterval:       5 * time.Minute,
-		MaxNagDuration:    30 * time.Minute,
-		TmuxSession:       "",
-		PaneMapPath:       "",
-		PaneTargets:       map[string]string{},
-		PromptGating:      "all",
-		QueueMaxAge:       5 * time.Minute,
-		PaneTailEnabled:   false,
-		PaneTailInterval:  30 * time.Second,
-		PaneTailLines:     150,
-		PaneTailRotations: 7,
-		PaneTailDir:       "",
+		ShareDir:           "",
+		InboxDir:           filepath.Join(home, ".local", "share", "relay", "outbox"),
+		LogDir:             "",
+		StateDir:           "",
+		AttacksDir:         filepath.Join(shareDir, "attacks"),
+		StuckThreshold:     5 * time.Minute,
+		NagInterval:        5 * time.Minute,
+		MaxNagDuration:     30 * time.Minute,
+		TmuxSession:        "",
+		PaneMapPath:        "",
+		PaneTargets:        map[string]string{},
+		PromptGating:       "all",
+		QueueMaxAge:        5 * time.Minute,
+		PaneTailEnabled:    false,
+		PaneTailInterval:   30 * time.Second,
+		PaneTailLines:      150,
+		PaneTailRotations:  7,
+		PaneTailDir:        "",
+		Admin:              DefaultAdminPolicy(),
+		ReadinessLines:     map[string]int{},
+		MinFreeMB:          100,
+		DiskCheckInterval:  time.Minute,
+		LabelMaxCustom:     16,
+		LabelMaxValueLen:   128,
+		ClockSkewTolerance: 5 * time.Minute,
+		MaxTimestampAge:    30 * 24 * time.Hour,
+		BDRetryAttempts:    bdRetry.Attempts,
+		BDRetryBaseDelay:   bdRetry.BaseDelay,
+		BDRetryMaxDelay:    bdRetry.MaxDelay,
+		InjectDedupWindow:  1024,
+		InjectMaxAttempts:  10,
 	}
 }
 
@@ -65,6 +160,10 @@ func Load() (*Config, error) {
 	cfg := Default()
 	overrideString(&cfg.ShareDir, "RELAY_SHARE_DIR")
 	cfg.InboxDir = envOr(cfg.InboxDir, "RELAY_INBOX_DIR")
+	if extra := os.Getenv("RELAY_INBOX_EXTRA_DIRS"); extra != "" {
+		cfg.InboxExtraDirs = filepath.SplitList(extra)
+	}
+	overrideList(&cfg.ExtraAgents, "RELAY_EXTRA_AGENTS")
 	cfg.LogDir = envOr(cfg.LogDir, "RELAY_LOG_DIR")
 	cfg.StateDir = envOr(cfg.StateDir, "RELAY_STATE_DIR")
 	cfg.AttacksDir = envOr(cfg.AttacksDir, "RELAY_ATTACKS_DIR")
@@ -81,7 +180,33 @@ func Load() (*Config, error) {
 	overrideDuration(&cfg.MaxNagDuration, "RELAY_MAX_NAG_DURATION")
 
 	cfg.PromptGating = envOr(cfg.PromptGating, "RELAY_PROMPT_GATING")
+	overrideList(&cfg.PromptPrefixes, "RELAY_PROMPT_PREFIXES")
+	overrideString(&cfg.PromptRegex, "RELAY_PROMPT_REGEX")
 	overrideDuration(&cfg.QueueMaxAge, "RELAY_QUEUE_MAX_AGE")
+	overrideDuration(&cfg.InjectMinInterval, "RELAY_INJECT_MIN_INTERVAL")
+	overrideInt(&cfg.InjectDedupWindow, "RELAY_INJECT_DEDUP_WINDOW")
+	overrideInt(&cfg.InjectMaxAttempts, "RELAY_INJECT_MAX_ATTEMPTS")
+
+	overrideBool(&cfg.Admin.Gate, "RELAY_ADMIN_GATE")
+	overrideBool(&cfg.Admin.Broadcast, "RELAY_ADMIN_BROADCAST")
+	overrideBool(&cfg.Admin.Direct, "RELAY_ADMIN_DIRECT")
+	overrideIntMap(cfg.ReadinessLines, "RELAY_READINESS_LINES")
+	overrideInt(&cfg.MinFreeMB, "RELAY_MIN_FREE_MB")
+	overrideDuration(&cfg.DiskCheckInterval, "RELAY_DISK_CHECK_INTERVAL")
+	overrideBool(&cfg.StrictPaneMap, "RELAY_PANE_MAP_STRICT")
+	cfg.CapabilitiesPath = envOr(cfg.CapabilitiesPath, "RELAY_CAPABILITIES")
+	overrideBool(&cfg.InboxContentHash, "RELAY_INBOX_CONTENT_HASH")
+	cfg.AuthMatrixPath = envOr(cfg.AuthMatrixPath, "RELAY_AUTH_MATRIX")
+	overrideInt(&cfg.LabelMaxCustom, "RELAY_LABEL_MAX_CUSTOM")
+	overrideInt(&cfg.LabelMaxValueLen, "RELAY_LABEL_MAX_VALUE_LEN")
+	overrideDuration(&cfg.ClockSkewTolerance, "RELAY_CLOCK_SKEW_TOLERANCE")
+	overrideDuration(&cfg.MaxTimestampAge, "RELAY_MAX_TIMESTAMP_AGE")
+	overrideString(&cfg.StateBundleImportDir, "RELAY_STATE_BUNDLE_IMPORT")
+	overrideString(&cfg.StateBundleExportDir, "RELAY_STATE_BUNDLE_EXPORT")
+	overrideString(&cfg.StatusAddr, "RELAY_STATUS_ADDR")
+	overrideInt(&cfg.BDRetryAttempts, "RELAY_BD_RETRY_ATTEMPTS")
+	overrideDuration(&cfg.BDRetryBaseDelay, "RELAY_BD_RETRY_BASE_DELAY")
+	overrideDuration(&cfg.BDRetryMaxDelay, "RELAY_BD_RETRY_MAX_DELAY")
 
 	return cfg, nil
 }
@@ -103,6 +228,9 @@ func (c *Config) Validate() error {
 	if c.PaneMapPath == "" {
 		c.PaneMapPath = filepath.Join(c.StateDir, "panes.json")
 	}
+	if c.CapabilitiesPath == "" {
+		c.CapabilitiesPath = filepath.Join(c.StateDir, "capabilities.json")
+	}
 	return nil
 }
 
@@ -113,69 +241,152 @@ type paneMapV2 struct {
 	RegisteredAt string            `json:"registered_at"`
 }
 
-// LoadPaneMap loads pane targets from PaneMapPath into PaneTargets.
-// Supports both the new nested format (with "panes", "version", "registered_at")
-// and the old flat format ({"oc":"%0","cc":"%1","cx":"%2"}) for backward compat.
+// LoadPaneMap loads pane targets from PaneMapPath into PaneTargets. See
+// ReadPaneMapFile for the accepted formats.
 func (c *Config) LoadPaneMap() error {
-	raw, err := os.ReadFile(c.PaneMapPath)
+	file, err := ReadPaneMapFile(c.PaneMapPath)
 	if err != nil {
 		return err
 	}
+	c.PaneTargets = file.Targets
+	c.PaneMapVersion = file.Version
+	c.PaneMapRegisteredAt = file.RegisteredAt
+	if err := c.checkPaneMap(); err != nil {
+		return err
+	}
+	c.PaneMapWarnings = append(file.Skipped, c.PaneMapWarnings...)
+	return nil
+}
 
-	// Try new nested format first
-	var v2 paneMapV2
-	if err := json.Unmarshal(raw, &v2); err == nil && v2.Panes != nil {
-		c.PaneTargets = make(map[string]string, len(v2.Panes))
-		for key, val := range v2.Panes {
-			c.PaneTargets[strings.ToLower(key)] = val
+// checkPaneMap applies the duplicate-pane policy to freshly loaded targets.
+func (c *Config) checkPaneMap() error {
+	c.PaneMapWarnings = nil
+	if err := CheckDuplicatePanes(c.PaneTargets); err != nil {
+		if c.StrictPaneMap {
+			return err
 		}
-		c.PaneMapVersion = v2.Version
-		c.PaneMapRegisteredAt = v2.RegisteredAt
-		return nil
+		c.PaneMapWarnings = append(c.PaneMapWarnings, err.Error())
 	}
+	return nil
+}
 
-	// Fall back to flat format
-	var flat map[string]string
-	if err := json.Unmarshal(raw, &flat); err != nil {
-		return fmt.Errorf("decode pane map: %w", err)
+// DuplicatePaneError reports pane IDs assigned to more than one role.
+type DuplicatePaneError struct {
+	// Duplicates maps each shared pane ID to its roles, sorted.
+	Duplicates map[string][]string
+}
+
+func (e *DuplicatePaneError) Error() string {
+	panes := make([]string, 0, len(e.Duplicates))
+	for paneID := range e.Duplicates {
+		panes = append(panes, paneID)
+	}
+	sort.Strings(panes)
+	parts := make([]string, 0, len(panes))
+	for _, paneID := range panes {
+		parts = append(parts, fmt.Sprintf("%s shared by %s", paneID, strings.Join(e.Duplicates[paneID], ", ")))
 	}
+	return "pane map: duplicate pane ids: " + strings.Join(parts, "; ")
+}
 
-	c.PaneTargets = make(map[string]string, len(flat))
-	for key, val := range flat {
-		c.PaneTargets[strings.ToLower(key)] = val
+// CheckDuplicatePanes returns a *DuplicatePaneError when two roles map to
+// the same pane, which would cross-deliver their messages.
+func CheckDuplicatePanes(targets map[string]string) error {
+	byPane := map[string][]string{}
+	for role, paneID := range targets {
+		paneID = strings.TrimSpace(paneID)
+		if paneID == "" {
+			continue
+		}
+		byPane[paneID] = append(byPane[paneID], role)
 	}
-	c.PaneMapVersion = 0
-	c.PaneMapRegisteredAt = ""
-	return nil
+	dups := map[string][]string{}
+	for paneID, roles := range byPane {
+		if len(roles) > 1 {
+			sort.Strings(roles)
+			dups[paneID] = roles
+		}
+	}
+	if len(dups) == 0 {
+		return nil
+	}
+	return &DuplicatePaneError{Duplicates: dups}
 }
 
 // ReadPaneMap reads pane targets from a file and returns them as a map.
 // This is a pure function that does not mutate any Config state, making it
 // safe to call from concurrent goroutines (e.g., the hot-reload watcher).
 func ReadPaneMap(path string) (map[string]string, error) {
-	raw, err := os.ReadFile(path)
+	file, err := ReadPaneMapFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return file.Targets, nil
+}
+
+// PaneMapFile is a decoded pane map. Version and RegisteredAt are zero for
+// the old flat format.
+type PaneMapFile struct {
+	Targets      map[string]string
+	Version      int
+	RegisteredAt string
+	// Skipped describes roles left out of Targets because their pane id
+	// was malformed.
+	Skipped []string
+}
 
+// ReadPaneMapFile reads a pane map with its registration metadata. It
+// accepts the nested format ({"panes":{...},"version":N,"registered_at":...})
+// and the old flat format ({"oc":"%0","cc":"%1","cx":"%2"}).
+func ReadPaneMapFile(path string) (PaneMapFile, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return PaneMapFile{}, err
+	}
+
+	// Try new nested format first
 	var v2 paneMapV2
 	if err := json.Unmarshal(raw, &v2); err == nil && v2.Panes != nil {
 		targets := make(map[string]string, len(v2.Panes))
 		for key, val := range v2.Panes {
 			targets[strings.ToLower(key)] = val
 		}
-		return targets, nil
+		skipped := dropInvalidPanes(targets)
+		return PaneMapFile{Targets: targets, Version: v2.Version, RegisteredAt: v2.RegisteredAt, Skipped: skipped}, nil
 	}
 
+	// Fall back to flat format
 	var flat map[string]string
 	if err := json.Unmarshal(raw, &flat); err != nil {
-		return nil, fmt.Errorf("decode pane map: %w", err)
+		return PaneMapFile{}, fmt.Errorf("decode pane map: %w", err)
 	}
 	targets := make(map[string]string, len(flat))
 	for key, val := range flat {
 		targets[strings.ToLower(key)] = val
 	}
-	return targets, nil
+	return PaneMapFile{Targets: targets, Skipped: dropInvalidPanes(targets)}, nil
+}
+
+// dropInvalidPanes removes roles whose non-empty pane value is not a valid
+// tmux pane id and returns one message per removed role, in sorted role
+// order. One bad entry costs only its own role rather than the whole map.
+func dropInvalidPanes(targets map[string]string) []string {
+	roles := make([]string, 0, len(targets))
+	for role := range targets {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	var skipped []string
+	for _, role := range roles {
+		if targets[role] == "" {
+			continue
+		}
+		if _, err := tmux.ParsePaneID(targets[role]); err != nil {
+			delete(targets, role)
+			skipped = append(skipped, fmt.Sprintf("pane map: role %s skipped: %v", role, err))
+		}
+	}
+	return skipped
 }
 
 // IsPaneMapStale returns true if the pane map's registered_at timestamp
@@ -230,3 +441,39 @@ func overrideInt(dest *int, key string) {
 		}
 	}
 }
+
+// overrideList replaces dest with the non-empty comma-separated entries
+// of key, trimmed.
+func overrideList(dest *[]string, key string) {
+	val := os.Getenv(key)
+	if val == "" {
+		return
+	}
+	var list []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*dest = list
+}
+
+// overrideIntMap merges "name=n,name=n" pairs from key into dest.
+// Malformed pairs are skipped.
+func overrideIntMap(dest map[string]int, key string) {
+	val := os.Getenv(key)
+	if val == "" {
+		return
+	}
+	for _, pair := range strings.Split(val, ",") {
+		name, num, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.Atoi(strings.TrimSpace(num))
+		if err != nil {
+			continue
+		}
+		dest[strings.TrimSpace(name)] = parsed
+	}
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -127,3 +129,100 @@ func TestIsPaneMapStale(t *testing.T) {
 		t.Error("invalid registered_at should be stale")
 	}
 }
+
+func TestAdminPolicyEnvOverrides(t *testing.T) {
+	cfg := Default()
+	if cfg.Admin != DefaultAdminPolicy() {
+		t.Fatalf("default admin policy = %+v", cfg.Admin)
+	}
+
+	t.Setenv("RELAY_ADMIN_GATE", "true")
+	t.Setenv("RELAY_ADMIN_BROADCAST", "false")
+	t.Setenv("RELAY_ADMIN_DIRECT", "no")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.Admin.Gate || cfg.Admin.Broadcast || cfg.Admin.Direct {
+		t.Fatalf("admin policy = %+v", cfg.Admin)
+	}
+}
+
+func TestReadinessLinesEnv(t *testing.T) {
+	t.Setenv("RELAY_READINESS_LINES", "cc=60, cx=10,bogus,oc=x")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.ReadinessLines) != 2 || cfg.ReadinessLines["cc"] != 60 || cfg.ReadinessLines["cx"] != 10 {
+		t.Fatalf("readiness lines = %v", cfg.ReadinessLines)
+	}
+}
+
+func TestPromptPrefixesEnv(t *testing.T) {
+	t.Setenv("RELAY_PROMPT_PREFIXES", "⋊>, $ ,,λ")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if strings.Join(cfg.PromptPrefixes, "|") != "⋊>|$|λ" {
+		t.Fatalf("prompt prefixes = %q", cfg.PromptPrefixes)
+	}
+}
+
+func TestLoadPaneMapDuplicatePanes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "panes.json")
+	data := `{"panes":{"oc":"%0","cc":"%1","cx":"%1"},"version":1}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Default()
+	cfg.PaneMapPath = path
+	if err := cfg.LoadPaneMap(); err != nil {
+		t.Fatalf("lenient LoadPaneMap: %v", err)
+	}
+	if len(cfg.PaneMapWarnings) != 1 || !strings.Contains(cfg.PaneMapWarnings[0], "%1 shared by cc, cx") {
+		t.Fatalf("PaneMapWarnings = %v", cfg.PaneMapWarnings)
+	}
+
+	strict := Default()
+	strict.PaneMapPath = path
+	strict.StrictPaneMap = true
+	err := strict.LoadPaneMap()
+	var dupErr *DuplicatePaneError
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("strict LoadPaneMap err = %v, want DuplicatePaneError", err)
+	}
+	if roles := dupErr.Duplicates["%1"]; len(roles) != 2 || roles[0] != "cc" || roles[1] != "cx" {
+		t.Fatalf("duplicates = %v", dupErr.Duplicates)
+	}
+}
+
+func TestLoadPaneMapSkipsInvalidPaneID(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "panes.json")
+	if err := os.WriteFile(path, []byte(`{"oc":"%0","cc":"1"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Default()
+	cfg.PaneMapPath = path
+	if err := cfg.LoadPaneMap(); err != nil {
+		t.Fatalf("LoadPaneMap: %v", err)
+	}
+	if len(cfg.PaneTargets) != 1 || cfg.PaneTargets["oc"] != "%0" {
+		t.Fatalf("PaneTargets = %v, want only oc", cfg.PaneTargets)
+	}
+	if len(cfg.PaneMapWarnings) != 1 || !strings.Contains(cfg.PaneMapWarnings[0], "role cc skipped") {
+		t.Fatalf("PaneMapWarnings = %v", cfg.PaneMapWarnings)
+	}
+	file, err := ReadPaneMapFile(path)
+	if err != nil {
+		t.Fatalf("ReadPaneMapFile: %v", err)
+	}
+	if _, ok := file.Targets["cc"]; ok || len(file.Skipped) != 1 {
+		t.Fatalf("ReadPaneMapFile = %+v", file)
+	}
+}
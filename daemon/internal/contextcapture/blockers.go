@@ -0,0 +1,125 @@
+package contextcapture
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Blocker sources
+const (
+	BlockerSourceTasklet    = "tasklet"    // unfinished BlockedBy dependency
+	BlockerSourceCheckpoint = "checkpoint" // "## Blockers" note in a checkpoint
+)
+
+// Blocker is one active impediment to progress.
+type Blocker struct {
+	Text   string `json:"text"`
+	Source string `json:"source"`
+
+	// TaskletID and BlockedBy are set for tasklet blockers.
+	TaskletID string `json:"tasklet_id,omitempty"`
+	BlockedBy string `json:"blocked_by,omitempty"`
+}
+
+// Blockers merges tasklet dependencies and the checkpoint's Blockers section
+// into one deduplicated list. Done tasklets and dependencies on done
+// tasklets are not blockers. Tasklet blockers come first, in input order.
+func Blockers(tasklets []*Tasklet, checkpointBody string) []Blocker {
+	done := map[string]bool{}
+	for _, t := range tasklets {
+		if t != nil && t.IsDone() {
+			done[t.TaskletID] = true
+		}
+	}
+
+	var out []Blocker
+	seen := map[string]bool{}
+	add := func(b Blocker) {
+		key := strings.ToLower(strings.TrimSpace(b.Text))
+		if key == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		out = append(out, b)
+	}
+
+	for _, t := range tasklets {
+		if t == nil || t.IsDone() {
+			continue
+		}
+		open := 0
+		for _, dep := range t.BlockedBy {
+			if done[dep] {
+				continue
+			}
+			open++
+			add(Blocker{
+				Text:      fmt.Sprintf("%s waiting on %s", t.TaskletID, dep),
+				Source:    BlockerSourceTasklet,
+				TaskletID: t.TaskletID,
+				BlockedBy: dep,
+			})
+		}
+		if open == 0 && t.Status == TaskletStatusBlocked {
+			add(Blocker{
+				Text:      fmt.Sprintf("%s is blocked", t.TaskletID),
+				Source:    BlockerSourceTasklet,
+				TaskletID: t.TaskletID,
+			})
+		}
+	}
+
+	for _, note := range CheckpointBlockers(checkpointBody) {
+		add(Blocker{Text: note, Source: BlockerSourceCheckpoint})
+	}
+	return out
+}
+
+// CheckpointBlockers returns the list items under a checkpoint's
+// "## Blockers" heading, skipping template placeholders and "none".
+func CheckpointBlockers(body string) []string {
+	var items []string
+	inSection := false
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			heading := strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			inSection = strings.EqualFold(heading, "blockers")
+			continue
+		}
+		if !inSection || trimmed == "" {
+			continue
+		}
+		item := stripListMarker(trimmed)
+		if item == "" || isPlaceholderBlocker(item) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func stripListMarker(line string) string {
+	for _, marker := range []string{"- ", "* ", "+ "} {
+		if strings.HasPrefix(line, marker) {
+			return strings.TrimSpace(line[len(marker):])
+		}
+	}
+	if i := strings.IndexAny(line, ".)"); i > 0 && i <= 3 {
+		if _, err := fmt.Sscanf(line[:i], "%d", new(int)); err == nil {
+			return strings.TrimSpace(line[i+1:])
+		}
+	}
+	return line
+}
+
+func isPlaceholderBlocker(item string) bool {
+	if strings.HasPrefix(item, "[") && strings.HasSuffix(item, "]") {
+		return true
+	}
+	switch strings.ToLower(strings.TrimSuffix(item, ".")) {
+	case "none", "n/a", "na", "no blockers", "nothing":
+		return true
+	}
+	return false
+}
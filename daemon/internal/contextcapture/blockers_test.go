@@ -0,0 +1,47 @@
+package contextcapture
+
+import "testing"
+
+func TestBlockersMergesTaskletsAndCheckpoint(t *testing.T) {
+	schema := NewTasklet("task-1", "plan-a", "ms-1", "schema")
+	schema.SetStatus(TaskletStatusDone)
+	parser := NewTasklet("task-2", "plan-a", "ms-1", "parser")
+	parser.BlockedBy = []string{"task-1", "task-3"}
+	render := NewTasklet("task-3", "plan-a", "ms-1", "render")
+	render.SetStatus(TaskletStatusBlocked)
+
+	body := `# Checkpoint
+
+## Current Goal
+Finish the parser.
+
+## Blockers
+- Waiting on API key from ops
+- task-2 waiting on task-3
+* none
+
+## Next Steps
+1. Ship it`
+
+	got := Blockers([]*Tasklet{schema, parser, render}, body)
+	want := []Blocker{
+		{Text: "task-2 waiting on task-3", Source: BlockerSourceTasklet, TaskletID: "task-2", BlockedBy: "task-3"},
+		{Text: "task-3 is blocked", Source: BlockerSourceTasklet, TaskletID: "task-3"},
+		{Text: "Waiting on API key from ops", Source: BlockerSourceCheckpoint},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Blockers() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Blockers()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCheckpointBlockersSkipsTemplate(t *testing.T) {
+	body := "## Blockers\n[Bullet list: What's preventing progress, open questions]\n\n## Next Steps\n1. go"
+	if got := CheckpointBlockers(body); len(got) != 0 {
+		t.Fatalf("CheckpointBlockers() = %v, want none", got)
+	}
+}
@@ -15,6 +15,7 @@ const (
 	defaultTailTokens        = 2000
 	defaultTailBytesPerToken = 4
 	defaultTailSkipSummaries = 1
+	defaultHeadTokens        = 500
 
 	defaultChunkTokens       = 4000
 	defaultOverlapPercent    = 12
@@ -24,6 +25,7 @@ const (
 // Config holds context capture configuration loaded from YAML.
 type Config struct {
 	SessionLogPath string
+	SessionSelect  string
 	Recovery       RecoveryConfig
 	Summary        SummaryConfig
 }
@@ -33,6 +35,12 @@ type RecoveryConfig struct {
 	TailTokens        int
 	TailBytesPerToken int
 	TailSkipSummaries int
+	CheckpointSources []string
+	TailRender        string
+	TailPartial       string
+	IncludeToolCalls  string
+	HeadTokens        int
+	HeadMode          string
 }
 
 // SummaryConfig controls summary chunking behavior.
@@ -46,10 +54,17 @@ type SummaryConfig struct {
 func DefaultConfig() *Config {
 	return &Config{
 		SessionLogPath: "",
+		SessionSelect:  SessionSelectNewest,
 		Recovery: RecoveryConfig{
 			TailTokens:        defaultTailTokens,
 			TailBytesPerToken: defaultTailBytesPerToken,
 			TailSkipSummaries: defaultTailSkipSummaries,
+			CheckpointSources: DefaultCheckpointSources(),
+			TailRender:        TailRenderMessage,
+			TailPartial:       TailPartialStrict,
+			IncludeToolCalls:  ToolCallsCollapse,
+			HeadTokens:        defaultHeadTokens,
+			HeadMode:          HeadModeAuto,
 		},
 		Summary: SummaryConfig{
 			ChunkTokens:        defaultChunkTokens,
@@ -110,10 +125,17 @@ func writeSampleConfig(path string, cfg *Config) error {
 	}
 
 	sample := fmt.Sprintf(
-		"session_log_path:\nrecovery:\n  tail_tokens: %d\n  tail_bytes_per_token: %d\n  tail_skip_summaries: %d\nsummary:\n  chunk_tokens: %d\n  overlap_percent: %d\n  rollup_every_n_chunks: %d\n",
+		"session_select: %s\nsession_log_path:\nrecovery:\n  tail_tokens: %d\n  tail_bytes_per_token: %d\n  tail_skip_summaries: %d\n  checkpoint_sources: %s\n  tail_render: %s\n  tail_partial: %s\n  include_tool_calls: %s\n  head_tokens: %d\n  head_mode: %s\nsummary:\n  chunk_tokens: %d\n  overlap_percent: %d\n  rollup_every_n_chunks: %d\n",
+		cfg.SessionSelect,
 		cfg.Recovery.TailTokens,
 		cfg.Recovery.TailBytesPerToken,
 		cfg.Recovery.TailSkipSummaries,
+		strings.Join(cfg.Recovery.CheckpointSources, ", "),
+		cfg.Recovery.TailRender,
+		cfg.Recovery.TailPartial,
+		cfg.Recovery.IncludeToolCalls,
+		cfg.Recovery.HeadTokens,
+		cfg.Recovery.HeadMode,
 		cfg.Summary.ChunkTokens,
 		cfg.Summary.OverlapPercent,
 		cfg.Summary.RollupEveryNChunks,
@@ -123,6 +145,9 @@ func writeSampleConfig(path string, cfg *Config) error {
 }
 
 func applyDefaults(cfg *Config) {
+	if cfg.SessionSelect == "" {
+		cfg.SessionSelect = SessionSelectNewest
+	}
 	if cfg.Recovery.TailTokens == 0 {
 		cfg.Recovery.TailTokens = defaultTailTokens
 	}
@@ -132,6 +157,24 @@ func applyDefaults(cfg *Config) {
 	if cfg.Recovery.TailSkipSummaries == 0 {
 		cfg.Recovery.TailSkipSummaries = defaultTailSkipSummaries
 	}
+	if len(cfg.Recovery.CheckpointSources) == 0 {
+		cfg.Recovery.CheckpointSources = DefaultCheckpointSources()
+	}
+	if cfg.Recovery.TailRender == "" {
+		cfg.Recovery.TailRender = TailRenderMessage
+	}
+	if cfg.Recovery.TailPartial == "" {
+		cfg.Recovery.TailPartial = TailPartialStrict
+	}
+	if cfg.Recovery.IncludeToolCalls == "" {
+		cfg.Recovery.IncludeToolCalls = ToolCallsCollapse
+	}
+	if cfg.Recovery.HeadTokens == 0 {
+		cfg.Recovery.HeadTokens = defaultHeadTokens
+	}
+	if cfg.Recovery.HeadMode == "" {
+		cfg.Recovery.HeadMode = HeadModeAuto
+	}
 	if cfg.Summary.ChunkTokens == 0 {
 		cfg.Summary.ChunkTokens = defaultChunkTokens
 	}
@@ -174,6 +217,50 @@ func parseConfigYAML(data []byte, cfg *Config) error {
 				cfg.SessionLogPath = value
 				continue
 			}
+			if key == "session_select" {
+				if !ValidSessionSelect(value) {
+					return fmt.Errorf("invalid config value on line %d: unknown session_select %q", lineNum, value)
+				}
+				cfg.SessionSelect = value
+				continue
+			}
+		case "recovery":
+			if key == "checkpoint_sources" {
+				sources := parseSourceList(value)
+				if err := ValidateCheckpointSources(sources); err != nil {
+					return fmt.Errorf("invalid config value on line %d: %w", lineNum, err)
+				}
+				cfg.Recovery.CheckpointSources = sources
+				continue
+			}
+			if key == "tail_render" {
+				if !ValidTailRender(value) {
+					return fmt.Errorf("invalid config value on line %d: unknown tail_render %q", lineNum, value)
+				}
+				cfg.Recovery.TailRender = value
+				continue
+			}
+			if key == "tail_partial" {
+				if !ValidTailPartial(value) {
+					return fmt.Errorf("invalid config value on line %d: unknown tail_partial %q", lineNum, value)
+				}
+				cfg.Recovery.TailPartial = value
+				continue
+			}
+			if key == "include_tool_calls" {
+				if !ValidToolCalls(value) {
+					return fmt.Errorf("invalid config value on line %d: unknown include_tool_calls %q", lineNum, value)
+				}
+				cfg.Recovery.IncludeToolCalls = value
+				continue
+			}
+			if key == "head_mode" {
+				if !ValidHeadMode(value) {
+					return fmt.Errorf("invalid config value on line %d: unknown head_mode %q", lineNum, value)
+				}
+				cfg.Recovery.HeadMode = value
+				continue
+			}
 		}
 
 		parsed, err := strconv.Atoi(value)
@@ -192,6 +279,8 @@ func parseConfigYAML(data []byte, cfg *Config) error {
 				cfg.Recovery.TailBytesPerToken = parsed
 			case "tail_skip_summaries":
 				cfg.Recovery.TailSkipSummaries = parsed
+			case "head_tokens":
+				cfg.Recovery.HeadTokens = parsed
 			}
 		case "summary":
 			switch key {
@@ -1,6 +1,9 @@
 package contextcapture
 
-import "testing"
+import (
+	"path/filepath"
+	"testing"
+)
 
 func TestParseConfigYAML(t *testing.T) {
 	cfg := DefaultConfig()
@@ -43,3 +46,97 @@ summary:
 		t.Fatalf("rollup_every_n_chunks = %d", cfg.Summary.RollupEveryNChunks)
 	}
 }
+
+func TestParseConfigYAMLCheckpointSources(t *testing.T) {
+	cfg := DefaultConfig()
+	data := []byte("recovery:\n  checkpoint_sources: recovery, task, session_brief\n")
+	if err := parseConfigYAML(data, cfg); err != nil {
+		t.Fatalf("parseConfigYAML: %v", err)
+	}
+	want := []string{SourceRecovery, SourceTask, SourceSessionBrief}
+	if len(cfg.Recovery.CheckpointSources) != len(want) {
+		t.Fatalf("checkpoint_sources = %v", cfg.Recovery.CheckpointSources)
+	}
+	for i := range want {
+		if cfg.Recovery.CheckpointSources[i] != want[i] {
+			t.Fatalf("checkpoint_sources = %v, want %v", cfg.Recovery.CheckpointSources, want)
+		}
+	}
+
+	if err := parseConfigYAML([]byte("recovery:\n  checkpoint_sources: task, bogus\n"), DefaultConfig()); err == nil {
+		t.Fatal("expected error for unknown source kind")
+	}
+	if err := parseConfigYAML([]byte("recovery:\n  checkpoint_sources: task, task\n"), DefaultConfig()); err == nil {
+		t.Fatal("expected error for duplicate source kind")
+	}
+}
+
+func TestResolveCheckpointReorderedChain(t *testing.T) {
+	beads := map[string][2]string{
+		SourceTask:     {"party-task1", "task body"},
+		SourceRecovery: {"party-rec1", "recovery body"},
+	}
+	lookup := func(kind string) (string, string) {
+		b := beads[kind]
+		return b[0], b[1]
+	}
+
+	id, _, source := ResolveCheckpoint(DefaultCheckpointSources(), lookup)
+	if id != "party-task1" || source != SourceTask {
+		t.Fatalf("default chain picked %s (%s), want task bead", id, source)
+	}
+
+	id, body, source := ResolveCheckpoint([]string{SourceRecovery, SourceTask}, lookup)
+	if id != "party-rec1" || source != SourceRecovery || body != "recovery body" {
+		t.Fatalf("reordered chain picked %s (%s), want recovery bead", id, source)
+	}
+
+	if id, _, _ := ResolveCheckpoint([]string{SourceCheckpoint}, lookup); id != "" {
+		t.Fatalf("expected no match, got %s", id)
+	}
+}
+
+func TestParseConfigYAMLSessionSelect(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := parseConfigYAML([]byte("session_select: active\n"), cfg); err != nil {
+		t.Fatalf("parseConfigYAML: %v", err)
+	}
+	if cfg.SessionSelect != SessionSelectActive {
+		t.Fatalf("session_select = %q", cfg.SessionSelect)
+	}
+	if err := parseConfigYAML([]byte("session_select: oldest\n"), DefaultConfig()); err == nil {
+		t.Fatal("expected error for unknown session_select")
+	}
+}
+
+func TestSampleConfigRoundTrips(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "context-capture.yaml")
+	want := DefaultConfig()
+	want.SessionSelect = SessionSelectLargest
+	if err := writeSampleConfig(path, want); err != nil {
+		t.Fatalf("writeSampleConfig: %v", err)
+	}
+	got, err := LoadFromPath(path)
+	if err != nil {
+		t.Fatalf("LoadFromPath: %v", err)
+	}
+	if got.SessionSelect != SessionSelectLargest || got.Recovery.TailTokens != want.Recovery.TailTokens {
+		t.Fatalf("sample config loaded as %+v", got)
+	}
+}
+
+func TestParseConfigYAMLTailRender(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Recovery.TailRender != TailRenderMessage {
+		t.Fatalf("default tail_render = %q", cfg.Recovery.TailRender)
+	}
+	if err := parseConfigYAML([]byte("recovery:\n  tail_render: grouped\n"), cfg); err != nil {
+		t.Fatalf("parseConfigYAML: %v", err)
+	}
+	if cfg.Recovery.TailRender != TailRenderGrouped {
+		t.Fatalf("tail_render = %q", cfg.Recovery.TailRender)
+	}
+	if err := parseConfigYAML([]byte("recovery:\n  tail_render: fancy\n"), cfg); err == nil {
+		t.Fatalf("expected error for unknown tail_render")
+	}
+}
@@ -0,0 +1,64 @@
+package contextcapture
+
+// exchangeWindow is the first tail window LastExchange parses; it doubles
+// until an exchange is found or the whole file has been read.
+const exchangeWindow = 64 * 1024
+
+// LastExchange returns the most recent user message that received an
+// assistant reply, together with the last assistant message before the
+// next user turn. Tool calls and results between them carry no text and
+// are skipped by the parsers, so a reply that follows a long tool run still
+// pairs with the user turn that started it. ok is false when the log holds
+// no complete exchange.
+func LastExchange(path string) (user, assistant Message, ok bool) {
+	log, err := openSessionLog(path)
+	if err != nil {
+		return Message{}, Message{}, false
+	}
+	defer log.Close()
+	size := log.size
+
+	for window := int64(exchangeWindow); ; window *= 2 {
+		start := size - window
+		if start < 0 {
+			start = 0
+		}
+		messages, err := parseMessagesAt(log, start, ToolCallsSkip)
+		if err != nil {
+			return Message{}, Message{}, false
+		}
+		if user, assistant, ok := lastExchange(messages); ok {
+			return user, assistant, true
+		}
+		if start == 0 {
+			return Message{}, Message{}, false
+		}
+	}
+}
+
+// lastExchange walks messages backward to the latest user turn followed by
+// an assistant reply.
+func lastExchange(messages []Message) (user, assistant Message, ok bool) {
+	reply := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		switch messages[i].Role {
+		case "assistant":
+			if reply < 0 {
+				reply = i
+			}
+		case "user":
+			if reply >= 0 {
+				return messages[i], messages[reply], true
+			}
+			// An unanswered user turn: keep looking for an earlier,
+			// complete exchange.
+			reply = -1
+		}
+	}
+	return Message{}, Message{}, false
+}
+
+// FormatExchange renders an exchange as two abbreviated lines.
+func FormatExchange(user, assistant Message) string {
+	return formatMessages([]Message{user, assistant}, TailRenderMessage)
+}
@@ -0,0 +1,51 @@
+package contextcapture
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLastExchangeSkipsToolInterleaving(t *testing.T) {
+	lines := []string{
+		`{"type":"user","sessionId":"s1","uuid":"u1","message":{"role":"user","content":"earlier task"}}`,
+		`{"type":"assistant","sessionId":"s1","uuid":"u2","message":{"role":"assistant","content":[{"type":"text","text":"earlier reply"}]}}`,
+		`{"type":"user","sessionId":"s1","uuid":"u3","message":{"role":"user","content":"fix the relay test"}}`,
+		`{"type":"assistant","sessionId":"s1","uuid":"u4","message":{"role":"assistant","content":[{"type":"text","text":"Running the tests."},{"type":"tool_use","id":"t1","name":"Bash","input":{}}]}}`,
+		`{"type":"user","sessionId":"s1","uuid":"u5","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"FAIL"}]}}`,
+		`{"type":"assistant","sessionId":"s1","uuid":"u6","message":{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"Edit","input":{}}]}}`,
+		`{"type":"user","sessionId":"s1","uuid":"u7","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","content":"ok"}]}}`,
+		`{"type":"assistant","sessionId":"s1","uuid":"u8","message":{"role":"assistant","content":[{"type":"text","text":"Fixed: the test now passes."}]}}`,
+	}
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	user, assistant, ok := LastExchange(path)
+	if !ok {
+		t.Fatal("no exchange found")
+	}
+	if user.Content != "fix the relay test" || assistant.Content != "Fixed: the test now passes." {
+		t.Fatalf("exchange = %q / %q", user.Content, assistant.Content)
+	}
+	if got := FormatExchange(user, assistant); got != "user: fix the relay test\nassistant: Fixed: the test now passes." {
+		t.Fatalf("FormatExchange = %q", got)
+	}
+}
+
+func TestLastExchangeIgnoresUnansweredTurn(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "q1"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "user", Content: "q2"},
+	}
+	user, assistant, ok := lastExchange(messages)
+	if !ok || user.Content != "q1" || assistant.Content != "a1" {
+		t.Fatalf("exchange = %+v / %+v, %v", user, assistant, ok)
+	}
+	if _, _, ok := lastExchange(messages[2:]); ok {
+		t.Fatal("unanswered turn reported as an exchange")
+	}
+}
@@ -0,0 +1,91 @@
+package contextcapture
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// gzipSuffix marks a rotated, gzip-compressed session log.
+const gzipSuffix = ".gz"
+
+func isGzipLog(path string) bool {
+	return strings.HasSuffix(path, gzipSuffix)
+}
+
+// isSessionLogName reports whether name is a plain or compressed JSONL log.
+func isSessionLogName(name string) bool {
+	return strings.HasSuffix(name, ".jsonl") || strings.HasSuffix(name, ".jsonl"+gzipSuffix)
+}
+
+// logReader is satisfied by both *os.File and *bytes.Reader.
+type logReader interface {
+	io.Reader
+	io.Seeker
+	io.ReaderAt
+}
+
+// sessionLog is an open session log. A gzip stream cannot seek, so a
+// compressed log is decompressed into memory in full; size is always the
+// uncompressed size, so byte offsets mean the same thing for both kinds.
+type sessionLog struct {
+	logReader
+	size  int64
+	close func() error
+}
+
+func (l *sessionLog) Close() error {
+	return l.close()
+}
+
+// openSessionLog opens path, decompressing it when the extension says gzip.
+func openSessionLog(path string) (*sessionLog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isGzipLog(path) {
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &sessionLog{logReader: file, size: info.Size(), close: file.Close}, nil
+	}
+
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("open compressed session log %s: %w", path, err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("decompress session log %s: %w", path, err)
+	}
+	return &sessionLog{
+		logReader: bytes.NewReader(data),
+		size:      int64(len(data)),
+		close:     func() error { return nil },
+	}, nil
+}
+
+// SessionLogSize returns the uncompressed size of a session log.
+func SessionLogSize(path string) (int64, error) {
+	if !isGzipLog(path) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	log, err := openSessionLog(path)
+	if err != nil {
+		return 0, err
+	}
+	defer log.Close()
+	return log.size, nil
+}
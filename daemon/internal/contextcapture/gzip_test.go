@@ -0,0 +1,71 @@
+package contextcapture
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeGzipLog(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zw := gzip.NewWriter(file)
+	if _, err := zw.Write([]byte(strings.Join(lines, "\n") + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGzipSessionLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl.gz")
+	writeGzipLog(t, path,
+		`{"type":"user","sessionId":"s1","message":{"role":"user","content":"`+strings.Repeat("old context ", 40)+`"}}`,
+		`{"type":"user","sessionId":"s1","message":{"role":"user","content":"rotate the logs"}}`,
+		`{"type":"assistant","sessionId":"s1","message":{"role":"assistant","content":"rotated and compressed"}}`,
+	)
+
+	tail, err := TailExtract(path, 60, 4, TailOptions{Render: TailRenderMessage, Partial: TailPartialStrict, ToolCalls: ToolCallsSkip})
+	if err != nil {
+		t.Fatalf("TailExtract: %v", err)
+	}
+	if tail != "user: rotate the logs\nassistant: rotated and compressed" {
+		t.Fatalf("tail = %q", tail)
+	}
+
+	// Offsets count uncompressed bytes, so the overlap skip still applies.
+	fromOffset, err := TailExtractFromOffset(path, 2000, 4, 1, TailOptions{Render: TailRenderMessage, Partial: TailPartialStrict, ToolCalls: ToolCallsSkip})
+	if err != nil {
+		t.Fatalf("TailExtractFromOffset: %v", err)
+	}
+	if tail != fromOffset {
+		t.Fatalf("tail from offset = %q, want %q", fromOffset, tail)
+	}
+
+	user, assistant, ok := LastExchange(path)
+	if !ok || user.Content != "rotate the logs" || assistant.Content != "rotated and compressed" {
+		t.Fatalf("LastExchange = %+v, %+v, %v", user, assistant, ok)
+	}
+}
+
+func TestFindCodexRolloutsIncludesGzip(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "rollout-rotated.jsonl.gz")
+	writeGzipLog(t, path, `{"type":"session_meta","payload":{"cwd":"/work/repo"}}`)
+
+	got := findCodexRollouts(root, 0, time.Now())
+	if len(got) != 1 || got[0] != path {
+		t.Fatalf("findCodexRollouts = %v, want [%s]", got, path)
+	}
+	if cwd, ok := codexSessionCwd(path); !ok || cwd != "/work/repo" {
+		t.Fatalf("codexSessionCwd = %q, %v", cwd, ok)
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"os"
+	"slices"
 	"strings"
 )
 
@@ -21,21 +21,43 @@ type Message struct {
 }
 
 // ParseMessages parses Claude session log JSONL entries from a reader.
+// Tool calls and results are skipped.
 func ParseMessages(r io.Reader) ([]Message, error) {
+	return ParseMessagesWithToolCalls(r, ToolCallsSkip)
+}
+
+// ParseMessagesWithToolCalls is ParseMessages with Claude tool blocks
+// handled according to toolCalls (ToolCallsSkip or ToolCallsCollapse).
+func ParseMessagesWithToolCalls(r io.Reader, toolCalls string) ([]Message, error) {
 	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 0, 64*1024), 2*1024*1024)
 
 	var messages []Message
+	schema := schemaUnknown
 	for scanner.Scan() {
 		line := bytes.TrimSpace(scanner.Bytes())
 		if len(line) == 0 {
 			continue
 		}
 
-		msg, skip, err := parseJSONLLine(line)
-		if err != nil {
+		var env map[string]any
+		if err := json.Unmarshal(line, &env); err != nil {
 			continue
 		}
+		if schema == schemaUnknown {
+			schema = sniffSchema(env)
+		}
+
+		var msg Message
+		var skip bool
+		switch schema {
+		case schemaClaude:
+			msg, skip = parseClaudeLine(env, toolCalls)
+		case schemaCodex:
+			msg, skip = parseCodexLine(env)
+		default:
+			msg, skip = parseGenericLine(env)
+		}
 		if skip {
 			continue
 		}
@@ -53,46 +75,172 @@ func ParseMessages(r io.Reader) ([]Message, error) {
 }
 
 // ParseMessagesFromOffset reads from a byte offset and parses messages.
+// Offsets into a gzip-compressed log count uncompressed bytes.
 func ParseMessagesFromOffset(path string, offset int64) ([]Message, error) {
-	file, err := os.Open(path)
+	log, err := openSessionLog(path)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	defer log.Close()
+	return parseMessagesAt(log, offset, ToolCallsSkip)
+}
 
-	if offset > 0 {
-		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+// parseMessagesAt parses log from the first line starting at or after offset.
+func parseMessagesAt(log *sessionLog, offset int64, toolCalls string) ([]Message, error) {
+	if offset <= 0 {
+		if _, err := log.Seek(0, io.SeekStart); err != nil {
 			return nil, err
 		}
-		skipPartialLine(file)
+		return ParseMessagesWithToolCalls(log, toolCalls)
 	}
 
-	return ParseMessages(file)
+	// Start one byte early: when that byte ends the previous line the
+	// offset is already a clean line start and nothing is skipped.
+	if _, err := log.Seek(offset-1, io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(log)
+	skipPartialLine(reader)
+	return ParseMessagesWithToolCalls(reader, toolCalls)
+}
+
+// skipPartialLine discards input through the next newline so parsing
+// resumes at the start of a line. A newline byte never occurs inside a
+// multi-byte UTF-8 sequence, so the resume point is also a rune boundary
+// however the offset fell. Parsing must continue from r itself, since r
+// may have buffered input past the newline.
+func skipPartialLine(r *bufio.Reader) {
+	for {
+		if _, err := r.ReadSlice('\n'); err != bufio.ErrBufferFull {
+			return
+		}
+	}
 }
 
-func skipPartialLine(r io.Reader) {
-	reader := bufio.NewReader(r)
-	_, _ = reader.ReadString('\n')
+// logSchema identifies which agent wrote a session log.
+type logSchema int
+
+const (
+	schemaUnknown logSchema = iota
+	schemaClaude
+	schemaCodex
+)
+
+// sniffSchema guesses the log schema from a decoded entry. Codex rollouts
+// wrap every entry in a typed payload; Claude entries carry a session id
+// alongside a message object.
+func sniffSchema(env map[string]any) logSchema {
+	typ, _ := env["type"].(string)
+	if _, ok := env["payload"].(map[string]any); ok {
+		switch typ {
+		case "session_meta", "response_item", "event_msg", "turn_context":
+			return schemaCodex
+		}
+	}
+	if _, ok := env["message"].(map[string]any); ok {
+		if firstString(env, "sessionId", "uuid") != "" {
+			return schemaClaude
+		}
+	}
+	return schemaUnknown
 }
 
-func parseJSONLLine(line []byte) (Message, bool, error) {
-	var env map[string]any
-	if err := json.Unmarshal(line, &env); err != nil {
-		return Message{}, false, err
+// parseClaudeLine extracts a message from a Claude Code session entry.
+// Only user and assistant turns with visible text are kept and meta
+// entries are skipped. Tool calls and results are skipped too, unless
+// toolCalls collapses them into markers.
+func parseClaudeLine(env map[string]any, toolCalls string) (Message, bool) {
+	typ, _ := env["type"].(string)
+	if typ != "user" && typ != "assistant" {
+		return Message{}, true
+	}
+	if meta, _ := env["isMeta"].(bool); meta {
+		return Message{}, true
+	}
+	msg, ok := env["message"].(map[string]any)
+	if !ok {
+		return Message{}, true
 	}
 
+	role := firstString(msg, "role")
+	var content string
+	switch val := msg["content"].(type) {
+	case string:
+		content = val
+	case []any:
+		content = concatTextParts(val, "text")
+		if toolCalls == ToolCallsCollapse {
+			markers, resultsOnly := collapseToolBlocks(val)
+			if len(markers) > 0 {
+				content = strings.TrimSpace(content + "\n" + strings.Join(markers, "\n"))
+				// Tool results are logged as user turns but are not the user.
+				if resultsOnly {
+					role = "tool"
+				}
+			}
+		}
+	}
+	return newMessage(role, content, firstString(env, "timestamp"), typ, typ)
+}
+
+// parseCodexLine extracts a message from a Codex rollout entry. Messages are
+// taken from response_item entries only; event_msg entries repeat the same
+// text and would duplicate every turn.
+func parseCodexLine(env map[string]any) (Message, bool) {
+	typ, _ := env["type"].(string)
+	payload, ok := env["payload"].(map[string]any)
+	if typ != "response_item" || !ok {
+		return Message{}, true
+	}
+	if ptype, _ := payload["type"].(string); ptype != "message" {
+		return Message{}, true
+	}
+	role := firstString(payload, "role")
+	if role != "user" && role != "assistant" {
+		return Message{}, true
+	}
+
+	parts, _ := payload["content"].([]any)
+	content := concatTextParts(parts, "input_text", "output_text", "text")
+	return newMessage(role, content, firstString(env, "timestamp"), typ, "")
+}
+
+// newMessage applies the size and emptiness filters shared by all schemas.
+// fallbackType supplies the role when the entry does not name one.
+func newMessage(role, content, ts, typ, fallbackType string) (Message, bool) {
+	if len(content) > maxPayloadBytes {
+		return Message{}, true
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return Message{}, true
+	}
+	if role == "" {
+		role = roleFromType(fallbackType)
+	}
+	return Message{
+		Role:      role,
+		Content:   content,
+		Timestamp: ts,
+		RawType:   typ,
+	}, false
+}
+
+// parseGenericLine walks the shapes seen across log formats when the schema
+// could not be identified.
+func parseGenericLine(env map[string]any) (Message, bool) {
 	typ, _ := env["type"].(string)
 	role := firstString(env, "role", "sender", "from")
 	ts := firstString(env, "timestamp", "created_at", "time")
 
 	content := extractContent(env)
 	if len(content) > maxPayloadBytes {
-		return Message{}, true, nil
+		return Message{}, true
 	}
 
 	content = strings.TrimSpace(content)
 	if content == "" {
-		return Message{}, true, nil
+		return Message{}, true
 	}
 
 	if role == "" {
@@ -119,7 +267,7 @@ func parseJSONLLine(line []byte) (Message, bool, error) {
 		Content:   content,
 		Timestamp: ts,
 		RawType:   typ,
-	}, false, nil
+	}, false
 }
 
 func extractContent(env map[string]any) string {
@@ -157,12 +305,14 @@ func extractContentFromPayload(payload map[string]any) string {
 		}
 	}
 	if parts, ok := payload["content"].([]any); ok {
-		return concatTextParts(parts)
+		return concatTextParts(parts, "text")
 	}
 	return ""
 }
 
-func concatTextParts(parts []any) string {
+// concatTextParts joins string parts and object parts whose type is one of
+// textTypes.
+func concatTextParts(parts []any, textTypes ...string) string {
 	var b strings.Builder
 	for _, part := range parts {
 		switch val := part.(type) {
@@ -172,7 +322,8 @@ func concatTextParts(parts []any) string {
 				b.WriteString("\n")
 			}
 		case map[string]any:
-			if val["type"] == "text" {
+			typ, _ := val["type"].(string)
+			if slices.Contains(textTypes, typ) {
 				if text := extractString(val["text"]); text != "" {
 					b.WriteString(text)
 					b.WriteString("\n")
@@ -0,0 +1,102 @@
+package contextcapture
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMessagesClaudeSchema(t *testing.T) {
+	log := strings.Join([]string{
+		`{"type":"summary","summary":"Relay work","leafUuid":"a1"}`,
+		`{"type":"user","isMeta":true,"sessionId":"s1","uuid":"u0","timestamp":"2025-06-01T10:00:00Z","message":{"role":"user","content":"<local-command-caveat>ignore</local-command-caveat>"}}`,
+		`{"type":"user","sessionId":"s1","uuid":"u1","timestamp":"2025-06-01T10:00:01Z","message":{"role":"user","content":"fix the flaky test"}}`,
+		`{"type":"assistant","sessionId":"s1","uuid":"u2","timestamp":"2025-06-01T10:00:02Z","message":{"id":"msg_1","role":"assistant","content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"Looking at it now."},{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test"}}]}}`,
+		`{"type":"user","sessionId":"s1","uuid":"u3","timestamp":"2025-06-01T10:00:03Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}}`,
+	}, "\n")
+
+	messages, err := ParseMessages(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("ParseMessages: %v", err)
+	}
+	want := []Message{
+		{Role: "user", Content: "fix the flaky test", Timestamp: "2025-06-01T10:00:01Z", RawType: "user"},
+		{Role: "assistant", Content: "Looking at it now.", Timestamp: "2025-06-01T10:00:02Z", RawType: "assistant"},
+	}
+	assertMessages(t, messages, want)
+}
+
+func TestParseMessagesCodexSchema(t *testing.T) {
+	log := strings.Join([]string{
+		`{"timestamp":"2025-06-01T10:00:00Z","type":"session_meta","payload":{"id":"r1","cwd":"/repo","instructions":"be helpful"}}`,
+		`{"timestamp":"2025-06-01T10:00:01Z","type":"response_item","payload":{"type":"message","role":"developer","content":[{"type":"input_text","text":"<permissions>"}]}}`,
+		`{"timestamp":"2025-06-01T10:00:02Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"review the diff"}]}}`,
+		`{"timestamp":"2025-06-01T10:00:02Z","type":"event_msg","payload":{"type":"user_message","message":"review the diff"}}`,
+		`{"timestamp":"2025-06-01T10:00:03Z","type":"response_item","payload":{"type":"reasoning","summary":[{"type":"summary_text","text":"thinking"}]}}`,
+		`{"timestamp":"2025-06-01T10:00:04Z","type":"response_item","payload":{"type":"function_call","name":"shell","arguments":"{}"}}`,
+		`{"timestamp":"2025-06-01T10:00:05Z","type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Two issues found."}]}}`,
+		`{"timestamp":"2025-06-01T10:00:05Z","type":"event_msg","payload":{"type":"agent_message","message":"Two issues found."}}`,
+	}, "\n")
+
+	messages, err := ParseMessages(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("ParseMessages: %v", err)
+	}
+	want := []Message{
+		{Role: "user", Content: "review the diff", Timestamp: "2025-06-01T10:00:02Z", RawType: "response_item"},
+		{Role: "assistant", Content: "Two issues found.", Timestamp: "2025-06-01T10:00:05Z", RawType: "response_item"},
+	}
+	assertMessages(t, messages, want)
+}
+
+func TestParseMessagesUnknownSchemaFallsBack(t *testing.T) {
+	log := `{"role":"assistant","content":"plain entry","timestamp":"t1"}`
+	messages, err := ParseMessages(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("ParseMessages: %v", err)
+	}
+	assertMessages(t, messages, []Message{{Role: "assistant", Content: "plain entry", Timestamp: "t1"}})
+}
+
+func assertMessages(t *testing.T, got, want []Message) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseMessagesCollapsesToolCalls(t *testing.T) {
+	log := strings.Join([]string{
+		`{"type":"user","sessionId":"s1","message":{"role":"user","content":"fix the flaky test"}}`,
+		`{"type":"assistant","sessionId":"s1","message":{"role":"assistant","content":[{"type":"text","text":"Reading it."},{"type":"tool_use","id":"t1","name":"Read","input":{"file_path":"/repo/internal/relay/file.go"}}]}}`,
+		`{"type":"user","sessionId":"s1","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"package relay\n\nfunc big() {}"}]}}`,
+		`{"type":"assistant","sessionId":"s1","message":{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"Bash","input":{"command":"go test ./...\necho done"}}]}}`,
+		`{"type":"user","sessionId":"s1","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","is_error":true,"content":[{"type":"text","text":"exit status 1\nFAIL relay"}]}]}}`,
+		`{"type":"assistant","sessionId":"s1","message":{"role":"assistant","content":[{"type":"tool_use","id":"t3","name":"TodoWrite","input":{"todos":[]}}]}}`,
+	}, "\n")
+
+	collapsed, err := ParseMessagesWithToolCalls(strings.NewReader(log), ToolCallsCollapse)
+	if err != nil {
+		t.Fatalf("ParseMessagesWithToolCalls: %v", err)
+	}
+	assertMessages(t, collapsed, []Message{
+		{Role: "user", Content: "fix the flaky test", RawType: "user"},
+		{Role: "assistant", Content: "Reading it.\n[tool: Read file.go]", RawType: "assistant"},
+		{Role: "assistant", Content: "[tool: Bash go test ./...]", RawType: "assistant"},
+		{Role: "tool", Content: "[tool error: exit status 1]", RawType: "user"},
+		{Role: "assistant", Content: "[tool: TodoWrite]", RawType: "assistant"},
+	})
+
+	skipped, err := ParseMessagesWithToolCalls(strings.NewReader(log), ToolCallsSkip)
+	if err != nil {
+		t.Fatalf("ParseMessagesWithToolCalls: %v", err)
+	}
+	assertMessages(t, skipped, []Message{
+		{Role: "user", Content: "fix the flaky test", RawType: "user"},
+		{Role: "assistant", Content: "Reading it.", RawType: "assistant"},
+	})
+}
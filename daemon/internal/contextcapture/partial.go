@@ -0,0 +1,116 @@
+package contextcapture
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Handling of a session log's unterminated final line, which is usually an
+// entry the agent is still writing.
+const (
+	TailPartialStrict  = "strict"  // omit it
+	TailPartialInclude = "include" // render a best-effort "[partial]" message
+)
+
+// ValidTailPartial reports whether mode is a known partial-line mode.
+func ValidTailPartial(mode string) bool {
+	return mode == TailPartialStrict || mode == TailPartialInclude
+}
+
+// PartialMarker prefixes the content of a best-effort partial message.
+const PartialMarker = "[partial]"
+
+var (
+	partialRoleRe = regexp.MustCompile(`"role"\s*:\s*"(\w+)"`)
+	partialTypeRe = regexp.MustCompile(`"type"\s*:\s*"(\w+)"`)
+	partialTextRe = regexp.MustCompile(`"(?:text|content)"\s*:\s*"`)
+)
+
+// PartialTail returns a best-effort message for a trailing line that has no
+// newline and is not yet valid JSON. ok is false when the file ends cleanly,
+// the fragment does not look like a JSON object, or no text can be
+// recovered from it.
+func PartialTail(path string) (Message, bool) {
+	log, err := openSessionLog(path)
+	if err != nil {
+		return Message{}, false
+	}
+	defer log.Close()
+
+	if log.size == 0 {
+		return Message{}, false
+	}
+	window := int64(2 * maxPayloadBytes)
+	start := log.size - window
+	if start < 0 {
+		start = 0
+	}
+	buf := make([]byte, log.size-start)
+	if _, err := log.ReadAt(buf, start); err != nil && err != io.EOF {
+		return Message{}, false
+	}
+	if buf[len(buf)-1] == '\n' {
+		return Message{}, false
+	}
+	idx := bytes.LastIndexByte(buf, '\n')
+	if idx < 0 && start > 0 {
+		// The fragment is longer than the window; too big to render anyway.
+		return Message{}, false
+	}
+	fragment := bytes.TrimSpace(buf[idx+1:])
+	if len(fragment) == 0 || fragment[0] != '{' || json.Valid(fragment) {
+		return Message{}, false
+	}
+	return parsePartialLine(string(fragment))
+}
+
+// parsePartialLine recovers role and text from a truncated JSON entry.
+func parsePartialLine(fragment string) (Message, bool) {
+	var parts []string
+	for _, loc := range partialTextRe.FindAllStringIndex(fragment, -1) {
+		if text := strings.TrimSpace(readPartialString(fragment[loc[1]:])); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	if len(parts) == 0 {
+		return Message{}, false
+	}
+
+	role := ""
+	if m := partialRoleRe.FindStringSubmatch(fragment); m != nil {
+		role = m[1]
+	} else if m := partialTypeRe.FindStringSubmatch(fragment); m != nil {
+		role = roleFromType(m[1])
+	}
+	return Message{
+		Role:    role,
+		Content: PartialMarker + " " + strings.Join(parts, "\n"),
+		RawType: "partial",
+	}, true
+}
+
+// readPartialString decodes a JSON string body up to its closing quote or
+// the end of s, whichever comes first.
+func readPartialString(s string) string {
+	end := len(s)
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == '"' {
+			end = i
+			break
+		}
+	}
+	body := strings.TrimSuffix(s[:end], "\\")
+	var out string
+	if err := json.Unmarshal([]byte(`"`+body+`"`), &out); err != nil {
+		// A cut-off escape such as \u00: keep the raw text.
+		return body
+	}
+	return out
+}
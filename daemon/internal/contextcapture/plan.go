@@ -288,3 +288,210 @@ func (t *Tasklet) IsBlocked() bool {
 func (t *Tasklet) IsDone() bool {
 	return t.Status == TaskletStatusDone
 }
+
+// RecomputeStatus derives the milestone status from its tasklets: done
+// when every referenced tasklet is done, in_progress once any is in
+// progress or done, pending otherwise. Tasklets not listed in m.Tasklets
+// are ignored, and a referenced tasklet missing from tasklets counts as
+// not done. Skipped milestones and milestones without tasklets are left
+// alone. Returns true if the status changed.
+func (m *Milestone) RecomputeStatus(tasklets []*Tasklet) bool {
+	if m.Status == MilestoneStatusSkipped || len(m.Tasklets) == 0 {
+		return false
+	}
+	byID := make(map[string]*Tasklet, len(tasklets))
+	for _, t := range tasklets {
+		if t != nil {
+			byID[t.TaskletID] = t
+		}
+	}
+	done, started := 0, 0
+	for _, id := range m.Tasklets {
+		t := byID[id]
+		switch {
+		case t == nil:
+		case t.IsDone():
+			done++
+		case t.Status == TaskletStatusInProgress:
+			started++
+		}
+	}
+
+	status := MilestoneStatusPending
+	switch {
+	case done == len(m.Tasklets):
+		status = MilestoneStatusDone
+	case done > 0 || started > 0:
+		status = MilestoneStatusInProgress
+	}
+	if status == m.Status {
+		return false
+	}
+	return m.SetStatus(status)
+}
+
+// RecomputeStatus derives the plan status from its milestones: completed
+// when every referenced milestone is done or skipped, active once any has
+// started. A plan with no progress keeps its status, and abandoned plans
+// are left alone. Returns true if the status changed.
+func (p *Plan) RecomputeStatus(milestones []*Milestone) bool {
+	if p.Status == PlanStatusAbandoned || len(p.Milestones) == 0 {
+		return false
+	}
+	byID := make(map[string]*Milestone, len(milestones))
+	for _, m := range milestones {
+		if m != nil {
+			byID[m.MilestoneID] = m
+		}
+	}
+	finished, started := 0, 0
+	for _, id := range p.Milestones {
+		m := byID[id]
+		switch {
+		case m == nil:
+		case m.Status == MilestoneStatusDone || m.Status == MilestoneStatusSkipped:
+			finished++
+		case m.Status == MilestoneStatusInProgress:
+			started++
+		}
+	}
+
+	status := p.Status
+	switch {
+	case finished == len(p.Milestones):
+		status = PlanStatusCompleted
+	case finished > 0 || started > 0:
+		status = PlanStatusActive
+	}
+	if status == p.Status {
+		return false
+	}
+	return p.SetStatus(status)
+}
+
+// MissingBlocker is a BlockedBy reference to a tasklet that does not exist.
+type MissingBlocker struct {
+	TaskletID string `json:"tasklet_id"`
+	BlockedBy string `json:"blocked_by"`
+}
+
+// DetectTaskletCycles checks the BlockedBy graph of tasklets. cyclic lists
+// every tasklet that lies on a dependency cycle (including one blocked by
+// itself), in input order; missing lists references to tasklet IDs not in
+// tasklets. Both are empty for a well-formed DAG.
+func DetectTaskletCycles(tasklets []*Tasklet) (cyclic []string, missing []MissingBlocker) {
+	byID := make(map[string]*Tasklet, len(tasklets))
+	for _, t := range tasklets {
+		if t != nil {
+			byID[t.TaskletID] = t
+		}
+	}
+	for _, t := range tasklets {
+		if t == nil {
+			continue
+		}
+		for _, dep := range t.BlockedBy {
+			if byID[dep] == nil {
+				missing = append(missing, MissingBlocker{TaskletID: t.TaskletID, BlockedBy: dep})
+			}
+		}
+	}
+
+	// Tarjan's strongly connected components: a DFS that tracks which
+	// tasklets are on the stack. Every member of a component with more
+	// than one tasklet, or with a self-edge, is on a cycle.
+	index := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	inCycle := map[string]bool{}
+	var visit func(id string)
+	visit = func(id string) {
+		index[id] = len(index)
+		low[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+		for _, dep := range byID[id].BlockedBy {
+			if byID[dep] == nil {
+				continue
+			}
+			if _, seen := index[dep]; !seen {
+				visit(dep)
+				low[id] = min(low[id], low[dep])
+			} else if onStack[dep] {
+				low[id] = min(low[id], index[dep])
+			}
+			if dep == id {
+				inCycle[id] = true
+			}
+		}
+		if low[id] != index[id] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == id {
+				break
+			}
+		}
+		if len(component) > 1 {
+			for _, member := range component {
+				inCycle[member] = true
+			}
+		}
+	}
+	for _, t := range tasklets {
+		if t == nil {
+			continue
+		}
+		if _, seen := index[t.TaskletID]; !seen {
+			visit(t.TaskletID)
+		}
+	}
+
+	for _, t := range tasklets {
+		if t != nil && inCycle[t.TaskletID] {
+			cyclic = append(cyclic, t.TaskletID)
+			delete(inCycle, t.TaskletID)
+		}
+	}
+	return cyclic, missing
+}
+
+// ReadyTasklets splits the unfinished tasklets into those ready to work
+// and those still waiting. A tasklet is ready when it is pending or in
+// progress and every BlockedBy dependency is a done tasklet; a blocked
+// status or a dependency that is unknown or unfinished keeps it waiting.
+// Done tasklets appear in neither list. Since no ready tasklet can depend
+// on another, both lists keep input order, so repeated calls over the same
+// set hand out work in the same order.
+func ReadyTasklets(tasklets []*Tasklet) (ready, blocked []*Tasklet) {
+	done := map[string]bool{}
+	for _, t := range tasklets {
+		if t != nil && t.IsDone() {
+			done[t.TaskletID] = true
+		}
+	}
+	for _, t := range tasklets {
+		if t == nil || t.IsDone() {
+			continue
+		}
+		waiting := t.Status == TaskletStatusBlocked
+		for _, dep := range t.BlockedBy {
+			if !done[dep] {
+				waiting = true
+				break
+			}
+		}
+		if waiting {
+			blocked = append(blocked, t)
+		} else {
+			ready = append(ready, t)
+		}
+	}
+	return ready, blocked
+}
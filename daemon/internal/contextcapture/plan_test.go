@@ -1,6 +1,7 @@
 package contextcapture
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -268,3 +269,168 @@ func TestIsValidStatusFunctions(t *testing.T) {
 		t.Error("finished should not be valid tasklet status")
 	}
 }
+
+func TestMilestoneRecomputeStatus(t *testing.T) {
+	m := NewMilestone("ms-1", "plan", 1, "Test")
+	m.AddTasklet("task-1")
+	m.AddTasklet("task-2")
+	t1 := NewTasklet("task-1", "plan", "ms-1", "First")
+	t2 := NewTasklet("task-2", "plan", "ms-1", "Second")
+	other := NewTasklet("task-9", "plan", "ms-2", "Elsewhere")
+	tasklets := []*Tasklet{t1, t2, other}
+
+	if m.RecomputeStatus(tasklets) {
+		t.Error("RecomputeStatus changed a milestone with no progress")
+	}
+
+	// Blocked tasklets are not progress.
+	t2.SetStatus(TaskletStatusBlocked)
+	m.RecomputeStatus(tasklets)
+	if m.Status != MilestoneStatusPending {
+		t.Errorf("blocked: Status = %q, want %q", m.Status, MilestoneStatusPending)
+	}
+
+	// Partial: one done, one blocked.
+	t1.SetStatus(TaskletStatusDone)
+	if !m.RecomputeStatus(tasklets) || m.Status != MilestoneStatusInProgress {
+		t.Errorf("partial: Status = %q, want %q", m.Status, MilestoneStatusInProgress)
+	}
+
+	// Unrelated tasklets do not count toward completion.
+	other.SetStatus(TaskletStatusDone)
+	t2.SetStatus(TaskletStatusDone)
+	if !m.RecomputeStatus(tasklets) || m.Status != MilestoneStatusDone {
+		t.Errorf("all done: Status = %q, want %q", m.Status, MilestoneStatusDone)
+	}
+
+	// A referenced tasklet that is missing keeps the milestone open.
+	if !m.RecomputeStatus([]*Tasklet{t1}) || m.Status != MilestoneStatusInProgress {
+		t.Errorf("missing tasklet: Status = %q, want %q", m.Status, MilestoneStatusInProgress)
+	}
+
+	skipped := NewMilestone("ms-3", "plan", 3, "Skipped")
+	skipped.AddTasklet("task-1")
+	skipped.SetStatus(MilestoneStatusSkipped)
+	if skipped.RecomputeStatus(tasklets) || skipped.Status != MilestoneStatusSkipped {
+		t.Errorf("skipped milestone recomputed to %q", skipped.Status)
+	}
+}
+
+func TestPlanRecomputeStatus(t *testing.T) {
+	p := NewPlan("plan", "Test", "repo")
+	p.AddMilestone("ms-1")
+	p.AddMilestone("ms-2")
+	m1 := NewMilestone("ms-1", "plan", 1, "First")
+	m2 := NewMilestone("ms-2", "plan", 2, "Second")
+	milestones := []*Milestone{m1, m2}
+
+	if p.RecomputeStatus(milestones) || p.Status != PlanStatusDraft {
+		t.Errorf("no progress: Status = %q, want %q", p.Status, PlanStatusDraft)
+	}
+
+	m1.SetStatus(MilestoneStatusInProgress)
+	if !p.RecomputeStatus(milestones) || p.Status != PlanStatusActive {
+		t.Errorf("started: Status = %q, want %q", p.Status, PlanStatusActive)
+	}
+
+	m1.SetStatus(MilestoneStatusDone)
+	m2.SetStatus(MilestoneStatusSkipped)
+	if !p.RecomputeStatus(milestones) || p.Status != PlanStatusCompleted {
+		t.Errorf("finished: Status = %q, want %q", p.Status, PlanStatusCompleted)
+	}
+
+	p.SetStatus(PlanStatusAbandoned)
+	if p.RecomputeStatus(milestones) || p.Status != PlanStatusAbandoned {
+		t.Errorf("abandoned plan recomputed to %q", p.Status)
+	}
+}
+
+func taskletGraph(edges map[string][]string, order ...string) []*Tasklet {
+	var out []*Tasklet
+	for _, id := range order {
+		task := NewTasklet(id, "plan", "ms-1", id)
+		task.BlockedBy = edges[id]
+		out = append(out, task)
+	}
+	return out
+}
+
+func TestDetectTaskletCycles(t *testing.T) {
+	dag := taskletGraph(map[string][]string{
+		"task-b": {"task-a"},
+		"task-c": {"task-a", "task-b"},
+	}, "task-a", "task-b", "task-c")
+	if cyclic, missing := DetectTaskletCycles(dag); len(cyclic) != 0 || len(missing) != 0 {
+		t.Errorf("clean DAG: cyclic=%v missing=%v", cyclic, missing)
+	}
+
+	// task-d hangs off the cycle without being part of it; task-e is on a
+	// second route back into it.
+	cycle := taskletGraph(map[string][]string{
+		"task-a": {"task-c"},
+		"task-b": {"task-a"},
+		"task-c": {"task-b", "task-e"},
+		"task-d": {"task-a"},
+		"task-e": {"task-b"},
+	}, "task-a", "task-b", "task-c", "task-d", "task-e")
+	cyclic, missing := DetectTaskletCycles(cycle)
+	if strings.Join(cyclic, ",") != "task-a,task-b,task-c,task-e" || len(missing) != 0 {
+		t.Errorf("cycle: cyclic=%v missing=%v", cyclic, missing)
+	}
+
+	self := taskletGraph(map[string][]string{"task-a": {"task-a"}}, "task-a")
+	if cyclic, _ := DetectTaskletCycles(self); len(cyclic) != 1 || cyclic[0] != "task-a" {
+		t.Errorf("self-blocked: cyclic=%v", cyclic)
+	}
+
+	dangling := taskletGraph(map[string][]string{"task-b": {"task-a", "task-x"}}, "task-a", "task-b")
+	cyclic, missing = DetectTaskletCycles(dangling)
+	if len(cyclic) != 0 || len(missing) != 1 || missing[0] != (MissingBlocker{TaskletID: "task-b", BlockedBy: "task-x"}) {
+		t.Errorf("dangling: cyclic=%v missing=%v", cyclic, missing)
+	}
+}
+
+func TestReadyTaskletsDiamond(t *testing.T) {
+	// task-a fans out to task-b and task-c, which both feed task-d.
+	tasklets := taskletGraph(map[string][]string{
+		"task-b": {"task-a"},
+		"task-c": {"task-a"},
+		"task-d": {"task-b", "task-c"},
+	}, "task-d", "task-c", "task-b", "task-a")
+	byID := map[string]*Tasklet{}
+	for _, task := range tasklets {
+		byID[task.TaskletID] = task
+	}
+	ids := func(list []*Tasklet) string {
+		var out []string
+		for _, task := range list {
+			out = append(out, task.TaskletID)
+		}
+		return strings.Join(out, ",")
+	}
+	check := func(step, wantReady, wantBlocked string) {
+		t.Helper()
+		ready, blocked := ReadyTasklets(tasklets)
+		if ids(ready) != wantReady || ids(blocked) != wantBlocked {
+			t.Errorf("%s: ready=[%s] blocked=[%s], want ready=[%s] blocked=[%s]",
+				step, ids(ready), ids(blocked), wantReady, wantBlocked)
+		}
+	}
+
+	check("start", "task-a", "task-d,task-c,task-b")
+
+	byID["task-a"].SetStatus(TaskletStatusDone)
+	check("root done", "task-c,task-b", "task-d")
+
+	// One side of the diamond finishing is not enough for task-d, and a
+	// tasklet marked blocked stays blocked even with its deps done.
+	byID["task-b"].SetStatus(TaskletStatusDone)
+	byID["task-c"].SetStatus(TaskletStatusBlocked)
+	check("one side done", "", "task-d,task-c")
+
+	byID["task-c"].SetStatus(TaskletStatusDone)
+	check("both sides done", "task-d", "")
+
+	byID["task-d"].SetStatus(TaskletStatusDone)
+	check("all done", "", "")
+}
@@ -0,0 +1,280 @@
+package contextcapture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/norm/relay-daemon/internal/beads"
+	"github.com/norm/relay-daemon/internal/execx"
+	"github.com/norm/relay-daemon/internal/labels"
+)
+
+// Bead types used for plan persistence.
+const (
+	BeadTypePlan      = "plan"
+	BeadTypeMilestone = "milestone"
+	BeadTypeTasklet   = "tasklet"
+)
+
+// planBeadTimeout bounds a single bd create for a plan bead.
+const planBeadTimeout = 20 * time.Second
+
+// WritePlanBead creates a plan bead whose body is the JSON-encoded plan,
+// labeled with plan_id, repo and status. It returns the new bead ID.
+func WritePlanBead(bdPath string, p *Plan) (string, error) {
+	ls := labels.NewLabelSet().
+		Add(labels.KeyPlanID, p.PlanID).
+		AddIf(labels.KeyRepo, p.Repo).
+		Add(labels.KeyStatus, p.Status)
+	addCustomLabels(ls, p.Labels, labels.KeyPlanID, labels.KeyRepo, labels.KeyStatus)
+	return writePlanBead(bdPath, BeadTypePlan, p.Title, p, ls)
+}
+
+// WriteMilestoneBead creates a milestone bead labeled with its plan,
+// milestone ID, sequence number and status.
+func WriteMilestoneBead(bdPath string, m *Milestone) (string, error) {
+	ls := labels.NewLabelSet().
+		Add(labels.KeyPlanID, m.PlanID).
+		Add(labels.KeyMilestoneID, m.MilestoneID).
+		Add(labels.KeyMilestoneNum, strconv.Itoa(m.MilestoneNum)).
+		Add(labels.KeyStatus, m.Status)
+	addCustomLabels(ls, m.Labels, labels.KeyPlanID, labels.KeyMilestoneID, labels.KeyMilestoneNum, labels.KeyStatus)
+	return writePlanBead(bdPath, BeadTypeMilestone, m.Name, m, ls)
+}
+
+// WriteTaskletBead creates a tasklet bead labeled with its plan, milestone,
+// tasklet ID and status, plus assignee and thread when set and one
+// blocked_by label per dependency.
+func WriteTaskletBead(bdPath string, t *Tasklet) (string, error) {
+	ls := labels.NewLabelSet().
+		Add(labels.KeyPlanID, t.PlanID).
+		Add(labels.KeyMilestoneID, t.MilestoneID).
+		Add(labels.KeyTaskletID, t.TaskletID).
+		Add(labels.KeyStatus, t.Status).
+		AddIf(labels.KeyAssignee, t.Assignee).
+		AddIf(labels.KeyThread, t.Thread)
+	for _, dep := range t.BlockedBy {
+		ls.AddIf(labels.KeyBlockedBy, dep)
+	}
+	addCustomLabels(ls, t.Labels, labels.KeyPlanID, labels.KeyMilestoneID, labels.KeyTaskletID,
+		labels.KeyStatus, labels.KeyAssignee, labels.KeyThread, labels.KeyBlockedBy)
+	return writePlanBead(bdPath, BeadTypeTasklet, t.Name, t, ls)
+}
+
+// addCustomLabels appends a plan object's own labels in key order. Keys are
+// normalized; invalid labels and ones that would shadow the canonical
+// labels the writer already set are dropped.
+func addCustomLabels(ls *labels.LabelSet, custom map[string]string, reserved ...string) {
+	skip := map[string]bool{}
+	for _, key := range reserved {
+		skip[key] = true
+	}
+	normalized := map[string]string{}
+	for key, value := range custom {
+		key = labels.NormalizeKey(key)
+		if skip[key] || labels.Validate(key, value) != nil {
+			continue
+		}
+		normalized[key] = value
+	}
+	keys := make([]string, 0, len(normalized))
+	for key := range normalized {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ls.Add(key, normalized[key])
+	}
+}
+
+func writePlanBead(bdPath, beadType, title string, body any, ls *labels.LabelSet) (string, error) {
+	if bdPath == "" {
+		return "", fmt.Errorf("write %s bead: bd not found", beadType)
+	}
+	payload, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode %s bead: %w", beadType, err)
+	}
+	args := []string{"create", "--type", beadType, "--title", title}
+	args = append(args, ls.Args()...)
+	args = append(args, "--description", string(payload), "--json")
+
+	// Only a create that bd reports never took the store lock is retried;
+	// one that timed out may have committed, and a retry would leave two
+	// beads with the same ID label.
+	out, err := beads.DefaultRetryPolicy().Do(func() (string, error) {
+		res, err := execx.Default.Run(context.Background(), bdPath, args, execx.Options{Timeout: planBeadTimeout})
+		if err != nil {
+			// bd reports some failures on stdout; keep them for IsTransient.
+			if out := res.String(); out != "" {
+				return "", fmt.Errorf("%w: %s", err, out)
+			}
+			return "", err
+		}
+		return res.Combined(), nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("write %s bead: %w", beadType, err)
+	}
+	return beads.ParseCreatedID(out)
+}
+
+// planListLimit caps each bd list when loading a plan; bd's own default
+// is far below a plan's tasklet count.
+const planListLimit = 500
+
+// PlanTree is a plan loaded from beads with its children resolved.
+type PlanTree struct {
+	Plan *Plan
+	// Milestones follow Plan.Milestones order.
+	Milestones []*Milestone
+	// Tasklets maps milestone ID to its tasklets, listed ones first in
+	// Milestone.Tasklets order, then any others carrying its ID.
+	Tasklets map[string][]*Tasklet
+	// Missing names milestones and tasklets that were referenced but had
+	// no bead; they are left out of the tree.
+	Missing []string
+}
+
+// AllTasklets returns every loaded tasklet in milestone order.
+func (pt *PlanTree) AllTasklets() []*Tasklet {
+	var out []*Tasklet
+	for _, m := range pt.Milestones {
+		out = append(out, pt.Tasklets[m.MilestoneID]...)
+	}
+	return out
+}
+
+// LoadPlan reads the plan bead for planID and the milestone and tasklet
+// beads labeled with it. Where an ID has several beads, the most recently
+// updated one is used. A missing plan bead is an error; missing or
+// unreadable milestones and tasklets are logged and skipped so one bad
+// bead does not hide the rest of the plan.
+func LoadPlan(bdPath, planID string) (*PlanTree, error) {
+	if bdPath == "" {
+		return nil, fmt.Errorf("load plan %s: bd not found", planID)
+	}
+	var plans []*Plan
+	if err := listPlanBeads(bdPath, BeadTypePlan, planID, &plans); err != nil {
+		return nil, err
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("load plan %s: no plan bead", planID)
+	}
+	var milestones []*Milestone
+	if err := listPlanBeads(bdPath, BeadTypeMilestone, planID, &milestones); err != nil {
+		return nil, err
+	}
+	var tasklets []*Tasklet
+	if err := listPlanBeads(bdPath, BeadTypeTasklet, planID, &tasklets); err != nil {
+		return nil, err
+	}
+
+	// A plan written again leaves older beads with the same plan_id;
+	// the newest one is current.
+	if len(plans) > 1 {
+		log.Printf("load plan %s: %d plan beads, using the newest", planID, len(plans))
+	}
+	tree := &PlanTree{Plan: plans[len(plans)-1], Tasklets: map[string][]*Tasklet{}}
+	msByID := map[string]*Milestone{}
+	for _, m := range milestones {
+		msByID[m.MilestoneID] = m
+	}
+	inTree := map[string]bool{}
+	for _, id := range tree.Plan.Milestones {
+		m := msByID[id]
+		if m == nil {
+			log.Printf("load plan %s: milestone %s has no bead, skipping", planID, id)
+			tree.Missing = append(tree.Missing, id)
+			continue
+		}
+		tree.Milestones = append(tree.Milestones, m)
+		inTree[id] = true
+	}
+
+	taskByID := map[string]*Tasklet{}
+	for _, t := range tasklets {
+		taskByID[t.TaskletID] = t
+	}
+	placed := map[string]bool{}
+	for _, m := range tree.Milestones {
+		for _, id := range m.Tasklets {
+			t := taskByID[id]
+			if t == nil || t.MilestoneID != m.MilestoneID {
+				log.Printf("load plan %s: tasklet %s has no bead, skipping", planID, id)
+				tree.Missing = append(tree.Missing, id)
+				continue
+			}
+			if !placed[id] {
+				tree.Tasklets[m.MilestoneID] = append(tree.Tasklets[m.MilestoneID], t)
+				placed[id] = true
+			}
+		}
+	}
+	// Tasklets written after their milestone's list was last saved.
+	for _, t := range tasklets {
+		if placed[t.TaskletID] || !inTree[t.MilestoneID] || taskByID[t.TaskletID] != t {
+			continue
+		}
+		tree.Tasklets[t.MilestoneID] = append(tree.Tasklets[t.MilestoneID], t)
+		placed[t.TaskletID] = true
+	}
+	return tree, nil
+}
+
+// listPlanBeads lists beads of beadType labeled plan_id:planID and decodes
+// each description into a new element of *out, oldest bead first. Beads
+// whose body does not decode are logged and skipped.
+func listPlanBeads[T any](bdPath, beadType, planID string, out *[]*T) error {
+	args := []string{"list", "--type", beadType, "--label", labels.Format(labels.KeyPlanID, planID),
+		"--limit", strconv.Itoa(planListLimit), "--json"}
+	// Reads are idempotent, so one retry is safe.
+	res, err := execx.Default.Run(context.Background(), bdPath, args, execx.Options{Timeout: planBeadTimeout, Attempts: 2})
+	if err != nil {
+		return fmt.Errorf("list %s beads for %s: %w", beadType, planID, err)
+	}
+	var listed []struct {
+		ID          string `json:"id"`
+		Description string `json:"description"`
+		CreatedAt   string `json:"created_at"`
+		UpdatedAt   string `json:"updated_at"`
+	}
+	if err := json.Unmarshal(res.Stdout, &listed); err != nil {
+		return fmt.Errorf("parse %s beads for %s: %w", beadType, planID, err)
+	}
+	// Oldest first, so when a bead was written more than once the newest
+	// copy comes last and wins wherever callers index by ID.
+	sort.SliceStable(listed, func(i, j int) bool {
+		a, b := listed[i], listed[j]
+		if ua, ub := beadTime(a.UpdatedAt), beadTime(b.UpdatedAt); !ua.Equal(ub) {
+			return ua.Before(ub)
+		}
+		if ca, cb := beadTime(a.CreatedAt), beadTime(b.CreatedAt); !ca.Equal(cb) {
+			return ca.Before(cb)
+		}
+		return a.ID < b.ID
+	})
+	for _, bead := range listed {
+		item := new(T)
+		if err := json.Unmarshal([]byte(bead.Description), item); err != nil {
+			log.Printf("load plan %s: %s bead %s has unreadable body, skipping: %v", planID, beadType, bead.ID, err)
+			continue
+		}
+		*out = append(*out, item)
+	}
+	return nil
+}
+
+// beadTime parses a bd timestamp; unparseable or missing ones sort first.
+func beadTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
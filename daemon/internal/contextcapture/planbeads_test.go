@@ -0,0 +1,298 @@
+package contextcapture
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRecordingBD installs a bd stand-in that appends its arguments, one
+// per line, to args.log and answers bd create with a JSON bead.
+func writeRecordingBD(t *testing.T) (bdPath, argsLog string) {
+	t.Helper()
+	dir := t.TempDir()
+	bdPath = filepath.Join(dir, "bd")
+	argsLog = filepath.Join(dir, "args.log")
+	script := `#!/bin/sh
+for arg in "$@"; do printf '%s\n' "$arg" >> "` + argsLog + `"; done
+echo '{"id":"party-plan1"}'
+`
+	if err := os.WriteFile(bdPath, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return bdPath, argsLog
+}
+
+func readArgs(t *testing.T, argsLog string) []string {
+	t.Helper()
+	data, err := os.ReadFile(argsLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+func labelArgs(args []string) []string {
+	var out []string
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "--label" {
+			out = append(out, args[i+1])
+		}
+	}
+	return out
+}
+
+func TestWriteTaskletBeadLabels(t *testing.T) {
+	bdPath, argsLog := writeRecordingBD(t)
+	task := NewTasklet("task-4.1.2", "plan-p4", "ms-4.1", "Write schema tests")
+	task.Assign("cc")
+	task.Thread = "tests"
+	task.BlockedBy = []string{"task-4.1.1", "task-3.2.1"}
+	task.Labels["Tasklet-ID"] = "shadowed"
+	task.Labels["area"] = "daemon"
+	task.Labels["Bad Key"] = "x"
+
+	id, err := WriteTaskletBead(bdPath, task)
+	if err != nil {
+		t.Fatalf("WriteTaskletBead: %v", err)
+	}
+	if id != "party-plan1" {
+		t.Fatalf("bead id = %q", id)
+	}
+
+	args := readArgs(t, argsLog)
+	got := strings.Join(labelArgs(args), " ")
+	want := "plan_id:plan-p4 milestone_id:ms-4.1 tasklet_id:task-4.1.2 status:pending assignee:cc thread:tests " +
+		"blocked_by:task-4.1.1 blocked_by:task-3.2.1 area:daemon"
+	if got != want {
+		t.Fatalf("labels = %s\nwant     %s", got, want)
+	}
+	if args[0] != "create" || args[2] != BeadTypeTasklet || args[len(args)-1] != "--json" {
+		t.Fatalf("args = %v", args)
+	}
+}
+
+func TestWritePlanAndMilestoneBeadLabels(t *testing.T) {
+	bdPath, argsLog := writeRecordingBD(t)
+	plan := NewPlan("plan-p4", "Phase 4", "party/daemon")
+	if _, err := WritePlanBead(bdPath, plan); err != nil {
+		t.Fatalf("WritePlanBead: %v", err)
+	}
+	args := readArgs(t, argsLog)
+	if got := strings.Join(labelArgs(args), " "); got != "plan_id:plan-p4 repo:party/daemon status:draft" {
+		t.Fatalf("plan labels = %s", got)
+	}
+	// The body round-trips to the struct.
+	var body Plan
+	for i, arg := range args {
+		if arg == "--description" {
+			// The fake records one argument per line, so rejoin the body.
+			end := len(args) - 1
+			if err := json.Unmarshal([]byte(strings.Join(args[i+1:end], "\n")), &body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+		}
+	}
+	if body.PlanID != "plan-p4" || body.Title != "Phase 4" {
+		t.Fatalf("body = %+v", body)
+	}
+
+	os.Remove(argsLog)
+	ms := NewMilestone("ms-4.1", "plan-p4", 1, "Plan Bead Format")
+	if _, err := WriteMilestoneBead(bdPath, ms); err != nil {
+		t.Fatalf("WriteMilestoneBead: %v", err)
+	}
+	if got := strings.Join(labelArgs(readArgs(t, argsLog)), " "); got != "plan_id:plan-p4 milestone_id:ms-4.1 milestone_num:1 status:pending" {
+		t.Fatalf("milestone labels = %s", got)
+	}
+}
+
+// writeFailingBD installs a bd stand-in that counts its runs in runs.log and
+// fails with script on the first run, then creates a bead.
+func writeFailingBD(t *testing.T, script string) (bdPath, runsLog string) {
+	t.Helper()
+	dir := t.TempDir()
+	bdPath = filepath.Join(dir, "bd")
+	runsLog = filepath.Join(dir, "runs.log")
+	body := `#!/bin/sh
+echo run >> "` + runsLog + `"
+if [ "$(wc -l < "` + runsLog + `")" -eq 1 ]; then
+` + script + `
+fi
+echo '{"id":"party-plan1"}'
+`
+	if err := os.WriteFile(bdPath, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return bdPath, runsLog
+}
+
+func TestWritePlanBeadRetriesOnlyLockFailures(t *testing.T) {
+	plan := NewPlan("plan-p4", "Phase 4", "party/daemon")
+
+	bdPath, runsLog := writeFailingBD(t, `echo "Error: database is locked"; exit 1`)
+	if id, err := WritePlanBead(bdPath, plan); err != nil || id != "party-plan1" {
+		t.Fatalf("locked store: id=%q err=%v", id, err)
+	}
+	if runs := len(readArgs(t, runsLog)); runs != 2 {
+		t.Fatalf("locked store: bd ran %d times, want 2", runs)
+	}
+
+	// A killed create (as on timeout) may have committed; it must not be
+	// repeated.
+	bdPath, runsLog = writeFailingBD(t, `kill -9 $$`)
+	if _, err := WritePlanBead(bdPath, plan); err == nil {
+		t.Fatal("expected error from killed bd")
+	}
+	if runs := len(readArgs(t, runsLog)); runs != 1 {
+		t.Fatalf("killed bd ran %d times, want 1", runs)
+	}
+}
+
+// writePlanStoreBD installs a bd stand-in whose list command answers with
+// the JSON file named after the requested --type.
+func writePlanStoreBD(t *testing.T, beadsByType map[string][]any) string {
+	t.Helper()
+	dir := t.TempDir()
+	for beadType, items := range beadsByType {
+		var listed []map[string]string
+		for i, item := range items {
+			body, err := json.Marshal(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			listed = append(listed, map[string]string{"id": beadType + "-" + string(rune('a'+i)), "description": string(body)})
+		}
+		if beadType == BeadTypeTasklet {
+			listed = append(listed, map[string]string{"id": "tasklet-bad", "description": "not json"})
+		}
+		data, _ := json.Marshal(listed)
+		if err := os.WriteFile(filepath.Join(dir, beadType+".json"), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do
+  if [ "$1" = "--type" ]; then cat "` + dir + `/$2.json" 2>/dev/null || echo '[]'; exit 0; fi
+  shift
+done
+exit 1
+`
+	bdPath := filepath.Join(dir, "bd")
+	if err := os.WriteFile(bdPath, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return bdPath
+}
+
+func TestLoadPlanPrefersNewestBead(t *testing.T) {
+	old := NewPlan("plan-p4", "Phase 4 draft", "party/daemon")
+	old.AddMilestone("ms-1")
+	current := NewPlan("plan-p4", "Phase 4", "party/daemon")
+	current.AddMilestone("ms-1")
+	msOld := NewMilestone("ms-1", "plan-p4", 1, "Schemas (draft)")
+	msNew := NewMilestone("ms-1", "plan-p4", 1, "Schemas")
+	taskOld := NewTasklet("task-1.1", "plan-p4", "ms-1", "Plan schema (draft)")
+	taskNew := NewTasklet("task-1.1", "plan-p4", "ms-1", "Plan schema")
+
+	// bd lists the newer bead last for plans and first for milestones and
+	// tasklets; the result must not depend on list order.
+	dir := t.TempDir()
+	write := func(beadType string, beads ...any) {
+		var listed []map[string]string
+		for i := 0; i < len(beads); i += 2 {
+			body, _ := json.Marshal(beads[i+1])
+			listed = append(listed, map[string]string{
+				"id":          fmt.Sprintf("%s-%d", beadType, i),
+				"description": string(body),
+				"updated_at":  beads[i].(string),
+			})
+		}
+		data, _ := json.Marshal(listed)
+		if err := os.WriteFile(filepath.Join(dir, beadType+".json"), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(BeadTypePlan, "2026-03-01T10:00:00Z", old, "2026-03-02T10:00:00Z", current)
+	write(BeadTypeMilestone, "2026-03-02T10:00:00Z", msNew, "2026-03-01T10:00:00Z", msOld)
+	write(BeadTypeTasklet, "2026-03-02T10:00:00Z", taskNew, "2026-03-01T10:00:00Z", taskOld)
+	bdPath := filepath.Join(dir, "bd")
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do
+  if [ "$1" = "--type" ]; then cat "` + dir + `/$2.json"; exit 0; fi
+  shift
+done
+exit 1
+`
+	if err := os.WriteFile(bdPath, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := LoadPlan(bdPath, "plan-p4")
+	if err != nil {
+		t.Fatalf("LoadPlan: %v", err)
+	}
+	if tree.Plan.Title != "Phase 4" || tree.Milestones[0].Name != "Schemas" {
+		t.Fatalf("plan %q milestone %q, want the newest beads", tree.Plan.Title, tree.Milestones[0].Name)
+	}
+	if got := tree.AllTasklets(); len(got) != 1 || got[0].Name != "Plan schema" {
+		t.Fatalf("tasklets = %+v", got)
+	}
+}
+
+func TestLoadPlan(t *testing.T) {
+	plan := NewPlan("plan-p4", "Phase 4", "party/daemon")
+	plan.AddMilestone("ms-1")
+	plan.AddMilestone("ms-gone")
+	plan.AddMilestone("ms-2")
+	ms1 := NewMilestone("ms-1", "plan-p4", 1, "Schemas")
+	ms1.AddTasklet("task-1.2")
+	ms1.AddTasklet("task-1.1")
+	ms1.AddTasklet("task-1.9")
+	ms2 := NewMilestone("ms-2", "plan-p4", 2, "Writers")
+	t11 := NewTasklet("task-1.1", "plan-p4", "ms-1", "Plan schema")
+	t12 := NewTasklet("task-1.2", "plan-p4", "ms-1", "Tasklet schema")
+	t21 := NewTasklet("task-2.1", "plan-p4", "ms-2", "Plan writer")
+	t21.BlockedBy = []string{"task-1.1"}
+
+	bdPath := writePlanStoreBD(t, map[string][]any{
+		BeadTypePlan:      {plan},
+		BeadTypeMilestone: {ms2, ms1},
+		BeadTypeTasklet:   {t21, t11, t12},
+	})
+	tree, err := LoadPlan(bdPath, "plan-p4")
+	if err != nil {
+		t.Fatalf("LoadPlan: %v", err)
+	}
+	if tree.Plan.Title != "Phase 4" {
+		t.Fatalf("plan = %+v", tree.Plan)
+	}
+	var ms []string
+	for _, m := range tree.Milestones {
+		ms = append(ms, m.MilestoneID)
+	}
+	if strings.Join(ms, ",") != "ms-1,ms-2" {
+		t.Fatalf("milestones = %v", ms)
+	}
+	var tasks []string
+	for _, task := range tree.AllTasklets() {
+		tasks = append(tasks, task.TaskletID)
+	}
+	if strings.Join(tasks, ",") != "task-1.2,task-1.1,task-2.1" {
+		t.Fatalf("tasklets = %v", tasks)
+	}
+	if got := tree.Tasklets["ms-2"][0].BlockedBy; len(got) != 1 || got[0] != "task-1.1" {
+		t.Fatalf("task-2.1 blocked_by = %v", got)
+	}
+	if strings.Join(tree.Missing, ",") != "ms-gone,task-1.9" {
+		t.Fatalf("missing = %v", tree.Missing)
+	}
+
+	empty := writePlanStoreBD(t, map[string][]any{})
+	if _, err := LoadPlan(empty, "plan-p4"); err == nil {
+		t.Fatal("expected error when the plan bead is missing")
+	}
+}
@@ -8,30 +8,53 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Where DiscoverSessionLogSource found the session log.
+const (
+	SessionSourceConfig = "config"           // session_log_path in the config file
+	SessionSourceEnv    = "SESSION_LOG_PATH" // environment override
+	SessionSourceClaude = "claude"           // ~/.claude/projects discovery
+	SessionSourceCodex  = "codex"            // ~/.codex/sessions discovery
 )
 
 // DiscoverSessionLog resolves the session JSONL path using config, env, or auto-discovery.
 func DiscoverSessionLog(cfg *Config) (string, error) {
+	path, _, err := DiscoverSessionLogSource(cfg)
+	return path, err
+}
+
+// DiscoverSessionLogSource is DiscoverSessionLog that also reports which
+// step found the path (one of the SessionSource constants).
+func DiscoverSessionLogSource(cfg *Config) (path, source string, err error) {
 	if cfg != nil && cfg.SessionLogPath != "" {
-		return cfg.SessionLogPath, nil
+		return cfg.SessionLogPath, SessionSourceConfig, nil
 	}
 	if env := os.Getenv("SESSION_LOG_PATH"); env != "" {
-		return env, nil
+		return env, SessionSourceEnv, nil
 	}
 
-	if path, err := discoverClaudeLog(); err == nil {
-		return path, nil
+	strategy := SessionSelectNewest
+	if cfg != nil && cfg.SessionSelect != "" {
+		strategy = cfg.SessionSelect
+	}
+	if path, err := discoverClaudeLog(strategy); err == nil {
+		return path, SessionSourceClaude, nil
 	}
 
 	if path, err := discoverCodexLog(); err == nil {
-		return path, nil
+		return path, SessionSourceCodex, nil
 	}
 
-	return "", errors.New("no session log found")
+	return "", "", errors.New("no session log found")
 }
 
-func discoverClaudeLog() (string, error) {
+func discoverClaudeLog(strategy string) (string, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return "", err
@@ -46,19 +69,32 @@ func discoverClaudeLog() (string, error) {
 		return "", err
 	}
 
+	projects := filepath.Join(home, ".claude", "projects")
 	for _, encoded := range encodeClaudeProjectPathCandidates(abs) {
-		pattern := filepath.Join(home, ".claude", "projects", encoded, "*.jsonl")
-		matches, err := filepath.Glob(pattern)
-		if err != nil {
-			continue
+		if path, err := selectSessionLog(claudeProjectLogs(filepath.Join(projects, encoded)), strategy, time.Now()); err == nil {
+			return path, nil
 		}
-		if path, err := latestByMtime(matches); err == nil {
+	}
+	if dir, ok := fuzzyClaudeProjectDir(projects, abs, home); ok {
+		if path, err := selectSessionLog(claudeProjectLogs(dir), strategy, time.Now()); err == nil {
 			return path, nil
 		}
 	}
 	return "", errors.New("no Claude session logs found")
 }
 
+// claudeProjectLogs lists the plain and compressed session logs in dir.
+func claudeProjectLogs(dir string) []string {
+	var matches []string
+	for _, pattern := range []string{"*.jsonl", "*.jsonl" + gzipSuffix} {
+		found, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err == nil {
+			matches = append(matches, found...)
+		}
+	}
+	return matches
+}
+
 func discoverCodexLog() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -66,21 +102,11 @@ func discoverCodexLog() (string, error) {
 	}
 
 	root := filepath.Join(home, ".codex", "sessions")
-	var matches []string
-
-	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			return nil
-		}
-		if d.IsDir() {
-			return nil
-		}
-		name := d.Name()
-		if strings.HasPrefix(name, "rollout-") && strings.HasSuffix(name, ".jsonl") {
-			matches = append(matches, path)
-		}
-		return nil
-	})
+	matches := findCodexRollouts(root, codexScanWindow(), time.Now())
+	if len(matches) == 0 {
+		// Nothing recent: fall back to the full tree rather than failing.
+		matches = findCodexRollouts(root, 0, time.Now())
+	}
 
 	scoped := codexScopePaths()
 	if len(scoped) > 0 {
@@ -93,6 +119,58 @@ func discoverCodexLog() (string, error) {
 	return latestByMtime(matches)
 }
 
+const defaultCodexScanWindow = 7 * 24 * time.Hour
+
+// codexScanWindow returns how far back Codex discovery looks, from
+// RELAY_CODEX_SCAN_WINDOW (a Go duration or a day count like "14d").
+// Zero disables the window and scans the whole sessions tree.
+func codexScanWindow() time.Duration {
+	raw := strings.TrimSpace(os.Getenv("RELAY_CODEX_SCAN_WINDOW"))
+	if raw == "" {
+		return defaultCodexScanWindow
+	}
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Duration(n) * 24 * time.Hour
+		}
+		return defaultCodexScanWindow
+	}
+	if d, err := time.ParseDuration(raw); err == nil && d >= 0 {
+		return d
+	}
+	return defaultCodexScanWindow
+}
+
+// findCodexRollouts lists rollout-*.jsonl(.gz) files under root modified within
+// window of now (window <= 0 lists all). Codex stores sessions under
+// YYYY/MM/DD directories by start date, but a resumed session keeps
+// appending to its original file, so only the file's own mtime counts.
+func findCodexRollouts(root string, window time.Duration, now time.Time) []string {
+	var cutoff time.Time
+	if window > 0 {
+		cutoff = now.Add(-window)
+	}
+	var matches []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil || d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if !strings.HasPrefix(name, "rollout-") || !isSessionLogName(name) {
+			return nil
+		}
+		if !cutoff.IsZero() {
+			info, err := d.Info()
+			if err != nil || info.ModTime().Before(cutoff) {
+				return nil
+			}
+		}
+		matches = append(matches, path)
+		return nil
+	})
+	return matches
+}
+
 func codexScopePaths() []string {
 	if os.Getenv("RELAY_STATE_DIR") == "" {
 		return nil
@@ -147,13 +225,13 @@ func latestMatchingCodexCwd(paths, scopedRoots []string) (string, error) {
 }
 
 func codexSessionCwd(path string) (string, bool) {
-	f, err := os.Open(path)
+	log, err := openSessionLog(path)
 	if err != nil {
 		return "", false
 	}
-	defer f.Close()
+	defer log.Close()
 
-	scanner := bufio.NewScanner(f)
+	scanner := bufio.NewScanner(log)
 	if !scanner.Scan() {
 		return "", false
 	}
@@ -174,6 +252,71 @@ func codexSessionCwd(path string) (string, bool) {
 	return event.Payload.Cwd, true
 }
 
+// Session selection strategies for worktrees with several Claude logs.
+const (
+	SessionSelectNewest  = "newest-mtime"
+	SessionSelectLargest = "largest"
+	SessionSelectActive  = "active"
+)
+
+// activeSessionWindow bounds how recently a log must have been written to
+// count as active.
+const activeSessionWindow = 5 * time.Minute
+
+// ValidSessionSelect reports whether strategy is a known selection strategy.
+func ValidSessionSelect(strategy string) bool {
+	switch strategy {
+	case SessionSelectNewest, SessionSelectLargest, SessionSelectActive:
+		return true
+	default:
+		return false
+	}
+}
+
+// selectSessionLog picks one of paths according to strategy. "largest"
+// prefers the log with the most content; "active" prefers the largest log
+// written within activeSessionWindow of now, so a small background session
+// touched last does not shadow the working one, and falls back to the
+// newest log when none is active.
+func selectSessionLog(paths []string, strategy string, now time.Time) (string, error) {
+	switch strategy {
+	case SessionSelectLargest:
+		return largestBySize(paths, time.Time{})
+	case SessionSelectActive:
+		if path, err := largestBySize(paths, now.Add(-activeSessionWindow)); err == nil {
+			return path, nil
+		}
+	}
+	return latestByMtime(paths)
+}
+
+// largestBySize returns the biggest readable file among paths modified at or
+// after since (zero since means no cutoff). Ties go to the newer file.
+func largestBySize(paths []string, since time.Time) (string, error) {
+	var bestPath string
+	var bestSize int64
+	var bestMod time.Time
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !since.IsZero() && info.ModTime().Before(since) {
+			continue
+		}
+		size, mod := info.Size(), info.ModTime()
+		if bestPath == "" || size > bestSize || (size == bestSize && mod.After(bestMod)) {
+			bestPath, bestSize, bestMod = path, size, mod
+		}
+	}
+
+	if bestPath == "" {
+		return "", fmt.Errorf("no matching session logs in %d candidates", len(paths))
+	}
+	return bestPath, nil
+}
+
 func latestByMtime(paths []string) (string, error) {
 	if len(paths) == 0 {
 		return "", errors.New("no session logs found")
@@ -209,5 +352,58 @@ func encodeClaudeProjectPathCandidates(abs string) []string {
 	if alt != base {
 		candidates = append(candidates, alt)
 	}
+	// Claude replaces every character outside [A-Za-z0-9] (dots included)
+	// with a dash.
+	if full := encodeClaudeProjectPath(slashed); full != alt {
+		candidates = append(candidates, full)
+	}
 	return candidates
 }
+
+func encodeClaudeProjectPath(path string) string {
+	return strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '-'
+	}, path)
+}
+
+// containsPath reports whether path is dir or lies beneath it.
+func containsPath(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// fuzzyClaudeProjectDir picks the project directory under projects that
+// best matches abs when no encoded candidate exists: names are compared
+// case-insensitively after encoding, against abs itself and then each of
+// its real ancestors in turn, so the nearest parent project wins. home
+// and the directories above it only match abs exactly: a session started
+// in $HOME is not the project of everything beneath it.
+func fuzzyClaudeProjectDir(projects, abs, home string) (string, bool) {
+	entries, err := os.ReadDir(projects)
+	if err != nil {
+		return "", false
+	}
+	byName := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			byName[strings.ToLower(encodeClaudeProjectPath(entry.Name()))] = entry.Name()
+		}
+	}
+	abs = filepath.Clean(abs)
+	home = filepath.Clean(home)
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if dir != abs && containsPath(dir, home) {
+			break
+		}
+		if name, ok := byName[strings.ToLower(encodeClaudeProjectPath(filepath.ToSlash(dir)))]; ok {
+			return filepath.Join(projects, name), true
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return "", false
+}
@@ -1,6 +1,13 @@
 package contextcapture
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestEncodeClaudeProjectPathCandidates(t *testing.T) {
 	candidates := encodeClaudeProjectPathCandidates("/home/phileas/Sandbox/personal/covered_calls")
@@ -11,3 +18,164 @@ func TestEncodeClaudeProjectPathCandidates(t *testing.T) {
 		t.Fatalf("unexpected candidate: %q", candidates[0])
 	}
 }
+
+func TestEncodeClaudeProjectPathCandidatesDots(t *testing.T) {
+	candidates := encodeClaudeProjectPathCandidates("/home/u/sites/example.com_v2")
+	want := "-home-u-sites-example-com-v2"
+	if candidates[len(candidates)-1] != want {
+		t.Fatalf("candidates = %v, want last %q", candidates, want)
+	}
+}
+
+func TestFuzzyClaudeProjectDir(t *testing.T) {
+	projects := t.TempDir()
+	for _, name := range []string{"-Users-U-Code-MyRepo", "-Users-U-Code", "-Users-U-Code-MyRepo-docs.old", "-Users-U", "-Users-U-Code-party"} {
+		if err := os.Mkdir(filepath.Join(projects, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const home = "/Users/u"
+
+	// Case differs from the recorded project path.
+	got, ok := fuzzyClaudeProjectDir(projects, "/Users/u/code/myrepo", home)
+	if !ok || filepath.Base(got) != "-Users-U-Code-MyRepo" {
+		t.Fatalf("mixed case = %q, %v", got, ok)
+	}
+
+	// A subdirectory of a project picks the nearest parent.
+	got, ok = fuzzyClaudeProjectDir(projects, "/Users/u/code/MyRepo/internal/relay", home)
+	if !ok || filepath.Base(got) != "-Users-U-Code-MyRepo" {
+		t.Fatalf("subdirectory = %q, %v", got, ok)
+	}
+
+	// Prefixes only match whole path segments.
+	if got, ok := fuzzyClaudeProjectDir(projects, "/Users/u/codex", home); ok {
+		t.Fatalf("partial segment matched %q", got)
+	}
+
+	// A sibling worktree whose name extends a project's is not that
+	// project; it falls back to the real parent.
+	got, ok = fuzzyClaudeProjectDir(projects, "/Users/u/code/party-cc", home)
+	if !ok || filepath.Base(got) != "-Users-U-Code" {
+		t.Fatalf("sibling = %q, %v", got, ok)
+	}
+
+	// The home project matches only home itself.
+	if got, ok := fuzzyClaudeProjectDir(projects, "/Users/u/notes", home); ok {
+		t.Fatalf("home project matched %q", got)
+	}
+	got, ok = fuzzyClaudeProjectDir(projects, "/Users/u", home)
+	if !ok || filepath.Base(got) != "-Users-U" {
+		t.Fatalf("home = %q, %v", got, ok)
+	}
+}
+
+func TestFindCodexRolloutsRecentWindow(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	write := func(day time.Time, name string, mtime time.Time) string {
+		dir := filepath.Join(root, day.Format("2006"), day.Format("01"), day.Format("02"))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	old := now.AddDate(0, 0, -30)
+	oldPath := write(old, "rollout-old.jsonl", old)
+	recentPath := write(now, "rollout-recent.jsonl", now)
+	// A session started a month ago and resumed today is still written
+	// under its original day directory, which itself looks old.
+	resumedPath := write(old, "rollout-resumed.jsonl", now)
+	oldDir := filepath.Dir(resumedPath)
+	if err := os.Chtimes(oldDir, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	got := findCodexRollouts(root, 7*24*time.Hour, now)
+	sort.Strings(got)
+	want := []string{resumedPath, recentPath}
+	sort.Strings(want)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("windowed scan = %v, want %v", got, want)
+	}
+
+	all := findCodexRollouts(root, 0, now)
+	if len(all) != 3 {
+		t.Fatalf("unbounded scan = %v, want 3 files", all)
+	}
+	found := false
+	for _, p := range all {
+		if p == oldPath {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("unbounded scan missing %s", oldPath)
+	}
+}
+
+func TestCodexScanWindowEnv(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":      defaultCodexScanWindow,
+		"14d":   14 * 24 * time.Hour,
+		"36h":   36 * time.Hour,
+		"0":     0,
+		"bogus": defaultCodexScanWindow,
+	}
+	for raw, want := range cases {
+		t.Setenv("RELAY_CODEX_SCAN_WINDOW", raw)
+		if got := codexScanWindow(); got != want {
+			t.Errorf("RELAY_CODEX_SCAN_WINDOW=%q: got %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestSelectSessionLogStrategies(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	write := func(name string, size int, mtime time.Time) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	// A big session idle for an hour, the working session written a minute
+	// ago, and a tiny background session touched just now.
+	archive := write("archive.jsonl", 9000, now.Add(-time.Hour))
+	working := write("working.jsonl", 4000, now.Add(-time.Minute))
+	background := write("background.jsonl", 200, now)
+	paths := []string{archive, working, background}
+
+	cases := map[string]string{
+		SessionSelectNewest:  background,
+		SessionSelectLargest: archive,
+		SessionSelectActive:  working,
+		"":                   background,
+	}
+	for strategy, want := range cases {
+		got, err := selectSessionLog(paths, strategy, now)
+		if err != nil {
+			t.Fatalf("%q: %v", strategy, err)
+		}
+		if got != want {
+			t.Errorf("%q picked %s, want %s", strategy, filepath.Base(got), filepath.Base(want))
+		}
+	}
+
+	// No log is active: fall back to the newest.
+	got, err := selectSessionLog(paths, SessionSelectActive, now.Add(time.Hour))
+	if err != nil || got != background {
+		t.Fatalf("inactive fallback = %s, %v; want %s", got, err, background)
+	}
+}
@@ -0,0 +1,77 @@
+package contextcapture
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Checkpoint source kinds consulted by restore-render, in the order given by
+// RecoveryConfig.CheckpointSources.
+const (
+	SourceTask          = "task"           // active task bead (open, in_progress, blocked)
+	SourceTaskCompleted = "task_completed" // task bead completed within the last 2h
+	SourceRecovery      = "recovery"       // most recent recovery bead
+	SourceCheckpoint    = "checkpoint"     // most recent checkpoint bead
+	SourceSessionBrief  = "session_brief"  // bead labeled kind:session_brief
+)
+
+// DefaultCheckpointSources is the fallback chain used when none is configured.
+func DefaultCheckpointSources() []string {
+	return []string{SourceTask, SourceTaskCompleted, SourceSessionBrief}
+}
+
+// ValidCheckpointSources returns every supported source kind.
+func ValidCheckpointSources() []string {
+	return []string{SourceTask, SourceTaskCompleted, SourceRecovery, SourceCheckpoint, SourceSessionBrief}
+}
+
+// ValidateCheckpointSources rejects empty chains, unknown kinds, and duplicates.
+func ValidateCheckpointSources(sources []string) error {
+	if len(sources) == 0 {
+		return fmt.Errorf("checkpoint_sources: empty list")
+	}
+	seen := make(map[string]bool, len(sources))
+	for _, src := range sources {
+		valid := false
+		for _, known := range ValidCheckpointSources() {
+			if src == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("checkpoint_sources: unknown kind %q, must be one of: %v", src, ValidCheckpointSources())
+		}
+		if seen[src] {
+			return fmt.Errorf("checkpoint_sources: duplicate kind %q", src)
+		}
+		seen[src] = true
+	}
+	return nil
+}
+
+// CheckpointLookup fetches the newest bead for a source kind, returning an
+// empty id when none exists.
+type CheckpointLookup func(kind string) (id, body string)
+
+// ResolveCheckpoint walks the fallback chain and returns the first bead found
+// along with the source kind that produced it.
+func ResolveCheckpoint(sources []string, lookup CheckpointLookup) (id, body, source string) {
+	for _, kind := range sources {
+		if id, body := lookup(kind); id != "" {
+			return id, body, kind
+		}
+	}
+	return "", "", ""
+}
+
+func parseSourceList(value string) []string {
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
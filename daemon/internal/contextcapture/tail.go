@@ -2,36 +2,121 @@ package contextcapture
 
 import (
 	"fmt"
-	"os"
+	"io"
 	"strings"
+	"unicode/utf8"
 )
 
 const defaultMaxLineLen = 400
 
-// TailExtract extracts a readable tail from a session log path.
-func TailExtract(path string, tailTokens int, bytesPerToken int) (string, error) {
+// Tail render modes.
+const (
+	TailRenderMessage = "message" // one role header per message (default)
+	TailRenderGrouped = "grouped" // consecutive same-role messages share a header
+)
+
+// TailOptions selects how TailExtract and TailExtractFromOffset render
+// the tail. Empty fields select the defaults.
+type TailOptions struct {
+	Render    string // TailRenderMessage or TailRenderGrouped
+	Partial   string // TailPartialStrict or TailPartialInclude
+	ToolCalls string // how Claude tool blocks are shown (see ToolCallsCollapse)
+}
+
+// TailOptions returns the tail options set in r.
+func (r RecoveryConfig) TailOptions() TailOptions {
+	return TailOptions{Render: r.TailRender, Partial: r.TailPartial, ToolCalls: r.IncludeToolCalls}
+}
+
+// ValidTailRender reports whether mode is a known tail render mode.
+// Empty selects the default.
+func ValidTailRender(mode string) bool {
+	switch mode {
+	case "", TailRenderMessage, TailRenderGrouped:
+		return true
+	}
+	return false
+}
+
+// Head modes control whether restore-render includes the session head.
+const (
+	HeadModeAuto   = "auto"   // include the head only when no summaries cover it
+	HeadModeAlways = "always" // always include the head
+	HeadModeNever  = "never"  // tail only
+)
+
+// ValidHeadMode reports whether mode is a known head mode. Empty selects auto.
+func ValidHeadMode(mode string) bool {
+	switch mode {
+	case "", HeadModeAuto, HeadModeAlways, HeadModeNever:
+		return true
+	}
+	return false
+}
+
+// IncludeHead decides whether the head of the session belongs in the
+// recovery bookend. In auto mode the head is dropped when a state rollup or
+// chunk summary exists, since the earliest summary already covers it.
+func IncludeHead(mode string, haveSummaries bool) bool {
+	switch mode {
+	case HeadModeAlways:
+		return true
+	case HeadModeNever:
+		return false
+	default:
+		return !haveSummaries
+	}
+}
+
+// HeadExtract extracts the opening messages of a session log, reading at
+// most headTokens*bytesPerToken bytes from the start of the file.
+func HeadExtract(path string, headTokens int, bytesPerToken int, render, toolCalls string) (string, error) {
+	if headTokens <= 0 || bytesPerToken <= 0 {
+		return "", fmt.Errorf("invalid head parameters")
+	}
+
+	log, err := openSessionLog(path)
+	if err != nil {
+		return "", err
+	}
+	defer log.Close()
+
+	// A line cut off at the limit fails to parse and is dropped.
+	messages, err := ParseMessagesWithToolCalls(io.LimitReader(log, int64(headTokens*bytesPerToken)), toolCalls)
+	if err != nil {
+		return "", err
+	}
+	return formatMessages(messages, render), nil
+}
+
+// TailExtract extracts a readable tail from a session log path. With
+// opts.Partial set to TailPartialInclude, an unterminated final entry is
+// appended as a "[partial]" message.
+func TailExtract(path string, tailTokens int, bytesPerToken int, opts TailOptions) (string, error) {
 	if tailTokens <= 0 || bytesPerToken <= 0 {
 		return "", fmt.Errorf("invalid tail parameters")
 	}
 
-	info, err := os.Stat(path)
+	log, err := openSessionLog(path)
 	if err != nil {
 		return "", err
 	}
+	defer log.Close()
 
 	bytesToRead := int64(tailTokens * bytesPerToken)
-	size := info.Size()
+	size := log.size
 	start := int64(0)
 	if size > bytesToRead {
 		start = size - bytesToRead
 	}
 
-	messages, err := ParseMessagesFromOffset(path, start)
+	messages, err := parseMessagesAt(log, start, opts.ToolCalls)
 	if err != nil {
 		return "", err
 	}
+	messages = withPartialTail(path, messages, opts.Partial)
 
-	return formatMessages(messages), nil
+	return formatMessages(messages, opts.Render), nil
 }
 
 // TailExtractFromConfig discovers the session log and extracts tail using config defaults.
@@ -43,24 +128,39 @@ func TailExtractFromConfig(cfg *Config) (string, error) {
 	if cfg == nil {
 		cfg = DefaultConfig()
 	}
-	return TailExtract(path, cfg.Recovery.TailTokens, cfg.Recovery.TailBytesPerToken)
+	return TailExtract(path, cfg.Recovery.TailTokens, cfg.Recovery.TailBytesPerToken, cfg.Recovery.TailOptions())
+}
+
+// withPartialTail appends the in-progress final entry when partial asks
+// for it.
+func withPartialTail(path string, messages []Message, partial string) []Message {
+	if partial != TailPartialInclude {
+		return messages
+	}
+	if msg, ok := PartialTail(path); ok {
+		messages = append(messages, msg)
+	}
+	return messages
 }
 
 // TailExtractFromOffset extracts tail starting from a specific offset.
 // This is used to skip content already covered by chunk summaries (overlap skip).
 // If minStartOffset is provided, extraction starts from max(calculated_start, minStartOffset).
-func TailExtractFromOffset(path string, tailTokens int, bytesPerToken int, minStartOffset int64) (string, error) {
+func TailExtractFromOffset(path string, tailTokens int, bytesPerToken int, minStartOffset int64, opts TailOptions) (string, error) {
 	if tailTokens <= 0 || bytesPerToken <= 0 {
 		return "", fmt.Errorf("invalid tail parameters")
 	}
 
-	info, err := os.Stat(path)
+	// A compressed log cannot seek, so it is decompressed in full and
+	// tailed in memory.
+	log, err := openSessionLog(path)
 	if err != nil {
 		return "", err
 	}
+	defer log.Close()
 
 	bytesToRead := int64(tailTokens * bytesPerToken)
-	size := info.Size()
+	size := log.size
 
 	// Calculate start position
 	start := int64(0)
@@ -73,15 +173,19 @@ func TailExtractFromOffset(path string, tailTokens int, bytesPerToken int, minSt
 		start = minStartOffset
 	}
 
-	messages, err := ParseMessagesFromOffset(path, start)
+	messages, err := parseMessagesAt(log, start, opts.ToolCalls)
 	if err != nil {
 		return "", err
 	}
+	messages = withPartialTail(path, messages, opts.Partial)
 
-	return formatMessages(messages), nil
+	return formatMessages(messages, opts.Render), nil
 }
 
-func formatMessages(messages []Message) string {
+func formatMessages(messages []Message, render string) string {
+	if render == TailRenderGrouped {
+		return formatMessagesGrouped(messages)
+	}
 	var b strings.Builder
 	for _, msg := range messages {
 		content := abbreviate(msg.Content, defaultMaxLineLen)
@@ -105,6 +209,43 @@ func formatMessages(messages []Message) string {
 	return strings.TrimSpace(b.String())
 }
 
+// formatMessagesGrouped writes one header per run of same-role messages,
+// stamped with the run's first timestamp, with bodies separated by blank lines.
+func formatMessagesGrouped(messages []Message) string {
+	var b strings.Builder
+	prevRole := ""
+	for _, msg := range messages {
+		content := abbreviate(msg.Content, defaultMaxLineLen)
+		if content == "" {
+			continue
+		}
+		role := msg.Role
+		if role == "" {
+			role = "unknown"
+		}
+		if role == prevRole {
+			b.WriteString("\n")
+			b.WriteString(content)
+			b.WriteString("\n")
+			continue
+		}
+		if prevRole != "" {
+			b.WriteString("\n")
+		}
+		prevRole = role
+		if msg.Timestamp != "" {
+			b.WriteString("[")
+			b.WriteString(msg.Timestamp)
+			b.WriteString("] ")
+		}
+		b.WriteString(role)
+		b.WriteString(":\n")
+		b.WriteString(content)
+		b.WriteString("\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
 func abbreviate(content string, maxLen int) string {
 	trimmed := strings.TrimSpace(content)
 	if trimmed == "" {
@@ -113,5 +254,10 @@ func abbreviate(content string, maxLen int) string {
 	if maxLen <= 0 || len(trimmed) <= maxLen {
 		return trimmed
 	}
-	return strings.TrimSpace(trimmed[:maxLen]) + "…"
+	// Back up to a rune start so a multi-byte character is never split.
+	cut := maxLen
+	for cut > 0 && !utf8.RuneStart(trimmed[cut]) {
+		cut--
+	}
+	return strings.TrimSpace(trimmed[:cut]) + "…"
 }
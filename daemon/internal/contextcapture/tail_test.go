@@ -0,0 +1,160 @@
+package contextcapture
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFormatMessagesRenderModes(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "run the tests"},
+		{Role: "assistant", Content: "running go test"},
+		{Role: "assistant", Content: "all green"},
+		{Role: "user", Content: "thanks"},
+	}
+
+	grouped := formatMessages(messages, TailRenderGrouped)
+	want := "user:\nrun the tests\n\nassistant:\nrunning go test\n\nall green\n\nuser:\nthanks"
+	if grouped != want {
+		t.Fatalf("grouped render:\n%s\nwant:\n%s", grouped, want)
+	}
+	if n := strings.Count(grouped, "assistant:"); n != 1 {
+		t.Fatalf("grouped render has %d assistant headers, want 1", n)
+	}
+
+	perMessage := formatMessages(messages, TailRenderMessage)
+	if n := strings.Count(perMessage, "assistant:"); n != 2 {
+		t.Fatalf("per-message render has %d assistant headers, want 2:\n%s", n, perMessage)
+	}
+}
+
+func TestIncludeHead(t *testing.T) {
+	cases := []struct {
+		mode          string
+		haveSummaries bool
+		want          bool
+	}{
+		{HeadModeAuto, false, true},
+		{HeadModeAuto, true, false},
+		{"", true, false},
+		{HeadModeAlways, true, true},
+		{HeadModeNever, false, false},
+	}
+	for _, tc := range cases {
+		if got := IncludeHead(tc.mode, tc.haveSummaries); got != tc.want {
+			t.Errorf("IncludeHead(%q, %v) = %v, want %v", tc.mode, tc.haveSummaries, got, tc.want)
+		}
+	}
+}
+
+func TestHeadExtractReadsOpeningMessages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	lines := []string{
+		`{"type":"user","message":{"role":"user","content":"build the importer"}}`,
+		`{"type":"assistant","message":{"role":"assistant","content":"on it"}}`,
+		`{"type":"user","message":{"role":"user","content":"` + strings.Repeat("x", 200) + `"}}`,
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	head, err := HeadExtract(path, 40, 4, TailRenderMessage, ToolCallsSkip)
+	if err != nil {
+		t.Fatalf("HeadExtract: %v", err)
+	}
+	if !strings.Contains(head, "build the importer") || !strings.Contains(head, "on it") {
+		t.Fatalf("head missing opening messages: %q", head)
+	}
+	if strings.Contains(head, "xxxx") {
+		t.Fatalf("head read past its byte budget: %q", head)
+	}
+}
+
+func TestTailExtractPartialLastLine(t *testing.T) {
+	complete := `{"type":"user","sessionId":"s1","uuid":"u1","message":{"role":"user","content":"summarize the logs"}}` + "\n"
+	partial := `{"type":"assistant","sessionId":"s1","uuid":"u2","message":{"role":"assistant","content":[{"type":"text","text":"The logs show two restarts and a \"disk_low\" ev`
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(path, []byte(complete+partial), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	strict, err := TailExtract(path, 2000, 4, TailOptions{Render: TailRenderMessage, Partial: TailPartialStrict, ToolCalls: ToolCallsSkip})
+	if err != nil {
+		t.Fatalf("strict TailExtract: %v", err)
+	}
+	if strict != "user: summarize the logs" {
+		t.Fatalf("strict tail = %q", strict)
+	}
+
+	inclusive, err := TailExtract(path, 2000, 4, TailOptions{Render: TailRenderMessage, Partial: TailPartialInclude, ToolCalls: ToolCallsSkip})
+	if err != nil {
+		t.Fatalf("inclusive TailExtract: %v", err)
+	}
+	want := "user: summarize the logs\nassistant: [partial] The logs show two restarts and a \"disk_low\" ev"
+	if inclusive != want {
+		t.Fatalf("inclusive tail = %q, want %q", inclusive, want)
+	}
+
+	// A terminated file has nothing partial to add.
+	if err := os.WriteFile(path, []byte(complete), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := PartialTail(path); ok {
+		t.Fatal("PartialTail reported a partial line for a terminated file")
+	}
+}
+
+func TestTailExtractCutInsideMultiByteRune(t *testing.T) {
+	first := `{"type":"user","sessionId":"s1","message":{"role":"user","content":"` + strings.Repeat("€", 50) + `"}}` + "\n"
+	second := `{"type":"assistant","sessionId":"s1","message":{"role":"assistant","content":"` + strings.Repeat("€", 200) + `"}}` + "\n"
+	third := `{"type":"user","sessionId":"s1","message":{"role":"user","content":"café ☕ done"}}` + "\n"
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(path, []byte(first+second+third), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Land the cut on the second byte of a "€" (3 bytes) in the first line.
+	cut := strings.Index(first, "€") + 3*10 + 1
+	tailBytes := len(first) + len(second) + len(third) - cut
+	tail, err := TailExtract(path, tailBytes, 1, TailOptions{Render: TailRenderMessage, Partial: TailPartialStrict, ToolCalls: ToolCallsSkip})
+	if err != nil {
+		t.Fatalf("TailExtract: %v", err)
+	}
+	if strings.ContainsRune(tail, utf8.RuneError) || !utf8.ValidString(tail) {
+		t.Fatalf("tail contains invalid UTF-8: %q", tail)
+	}
+	if strings.Contains(tail, "user: €") {
+		t.Fatalf("tail kept the line the cut fell in: %q", tail)
+	}
+	if !strings.Contains(tail, "assistant: €€€") || !strings.HasSuffix(tail, "user: café ☕ done") {
+		t.Fatalf("tail missing complete messages: %q", tail)
+	}
+
+	// An offset exactly at a line start keeps that line.
+	fromLine, err := ParseMessagesFromOffset(path, int64(len(first)))
+	if err != nil {
+		t.Fatalf("ParseMessagesFromOffset: %v", err)
+	}
+	if len(fromLine) != 2 || fromLine[0].Role != "assistant" {
+		t.Fatalf("messages from line start = %+v", fromLine)
+	}
+}
+
+func TestParseConfigYAMLIncludeToolCalls(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Recovery.IncludeToolCalls != ToolCallsCollapse {
+		t.Fatalf("default include_tool_calls = %q", cfg.Recovery.IncludeToolCalls)
+	}
+	if err := parseConfigYAML([]byte("recovery:\n  include_tool_calls: skip\n"), cfg); err != nil {
+		t.Fatalf("parseConfigYAML: %v", err)
+	}
+	if cfg.Recovery.IncludeToolCalls != ToolCallsSkip {
+		t.Fatalf("include_tool_calls = %q, want skip", cfg.Recovery.IncludeToolCalls)
+	}
+	if err := parseConfigYAML([]byte("recovery:\n  include_tool_calls: verbose\n"), DefaultConfig()); err == nil {
+		t.Fatal("expected error for unknown include_tool_calls")
+	}
+}
@@ -0,0 +1,93 @@
+package contextcapture
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Handling of Claude tool_use and tool_result blocks, whose raw inputs and
+// outputs (file dumps, command output) would otherwise crowd out the
+// conversation.
+const (
+	ToolCallsSkip     = "skip"     // drop tool blocks entirely
+	ToolCallsCollapse = "collapse" // render one-line "[tool: Read file.go]" markers
+)
+
+// ValidToolCalls reports whether mode is a known tool-call mode.
+func ValidToolCalls(mode string) bool {
+	return mode == ToolCallsSkip || mode == ToolCallsCollapse
+}
+
+// maxToolArgLen bounds the argument shown in a collapsed marker.
+const maxToolArgLen = 60
+
+// toolArgKeys are tool input fields worth naming in a marker, in order of
+// preference.
+var toolArgKeys = []string{"file_path", "notebook_path", "path", "command", "pattern", "url", "description"}
+
+// collapseToolBlocks renders markers for the tool_use blocks in parts and
+// for failed tool_result blocks. Successful results carry only output and
+// are left out; the call marker already says what ran.
+func collapseToolBlocks(parts []any) (markers []string, resultsOnly bool) {
+	resultsOnly = true
+	for _, part := range parts {
+		block, ok := part.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch block["type"] {
+		case "tool_use":
+			resultsOnly = false
+			markers = append(markers, toolUseMarker(block))
+		case "tool_result":
+			if isErr, _ := block["is_error"].(bool); isErr {
+				markers = append(markers, toolErrorMarker(block))
+			}
+		case "text":
+			resultsOnly = false
+		}
+	}
+	return markers, resultsOnly
+}
+
+func toolUseMarker(block map[string]any) string {
+	name := firstString(block, "name")
+	if name == "" {
+		name = "unknown"
+	}
+	input, _ := block["input"].(map[string]any)
+	for _, key := range toolArgKeys {
+		arg := strings.TrimSpace(firstString(input, key))
+		if arg == "" {
+			continue
+		}
+		if strings.HasSuffix(key, "_path") {
+			arg = filepath.Base(arg)
+		}
+		return "[tool: " + name + " " + shortToolArg(arg) + "]"
+	}
+	return "[tool: " + name + "]"
+}
+
+func toolErrorMarker(block map[string]any) string {
+	var text string
+	switch val := block["content"].(type) {
+	case string:
+		text = val
+	case []any:
+		text = concatTextParts(val, "text")
+	}
+	if line, _, _ := strings.Cut(strings.TrimSpace(text), "\n"); line != "" {
+		return "[tool error: " + shortToolArg(line) + "]"
+	}
+	return "[tool error]"
+}
+
+// shortToolArg keeps the first line of arg, cut to maxToolArgLen runes.
+func shortToolArg(arg string) string {
+	arg, _, _ = strings.Cut(arg, "\n")
+	if runes := []rune(arg); len(runes) > maxToolArgLen {
+		return string(runes[:maxToolArgLen]) + "…"
+	}
+	return arg
+}
@@ -0,0 +1,87 @@
+// Package deadletter records envelopes the relay refused or could not
+// route, so an operator can inspect and re-inject them.
+package deadletter
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/norm/relay-daemon/pkg/envelope"
+)
+
+// FileName is the JSONL file written under the dead-letter directory.
+const FileName = "dead-letter.jsonl"
+
+// Record is one dead-lettered envelope.
+type Record struct {
+	At       time.Time          `json:"at"`
+	Reason   string             `json:"reason"`
+	Target   string             `json:"target,omitempty"`
+	Envelope *envelope.Envelope `json:"envelope"`
+}
+
+// Writer appends Records to <dir>/dead-letter.jsonl.
+type Writer struct {
+	path string
+	mu   sync.Mutex
+}
+
+// New returns a Writer for dir.
+func New(dir string) *Writer {
+	return &Writer{path: filepath.Join(dir, FileName)}
+}
+
+// Path returns the JSONL file path.
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Write appends env with the reason it was not delivered to target.
+func (w *Writer) Write(env *envelope.Envelope, target, reason string) error {
+	payload, err := json.Marshal(Record{At: time.Now().UTC(), Reason: reason, Target: target, Envelope: env})
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(payload, '\n'))
+	return err
+}
+
+// Read returns the records in the JSONL file at path, oldest first. A
+// missing file has no records; lines that do not decode are skipped.
+func Read(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.Envelope == nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
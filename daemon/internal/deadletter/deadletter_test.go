@@ -0,0 +1,59 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/norm/relay-daemon/pkg/envelope"
+)
+
+func TestWriteAppendsRecord(t *testing.T) {
+	w := New(t.TempDir())
+	env := envelope.NewEnvelope("cc", "cx", "command", "rm -rf build")
+	if err := w.Write(env, "cx", "unauthorized"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	data, err := os.ReadFile(w.Path())
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var rec Record
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(data))), &rec); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if rec.Reason != "unauthorized" || rec.Target != "cx" || rec.Envelope.MsgID != env.MsgID {
+		t.Fatalf("record = %+v", rec)
+	}
+}
+
+func TestReadSkipsBadLines(t *testing.T) {
+	w := New(t.TempDir())
+	first := envelope.NewEnvelope("oc", "ghost", "chat", "one")
+	second := envelope.NewEnvelope("oc", "cc", "chat", "two")
+	if err := w.Write(first, "ghost", "unknown_target"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	file, err := os.OpenFile(w.Path(), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("{truncated\n")
+	file.Close()
+	if err := w.Write(second, "cc", "expired"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	records, err := Read(w.Path())
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(records) != 2 || records[0].Envelope.MsgID != first.MsgID || records[1].Reason != "expired" {
+		t.Fatalf("records = %+v", records)
+	}
+	if records, err := Read(w.Path() + ".missing"); err != nil || records != nil {
+		t.Fatalf("Read(missing) = %v, %v", records, err)
+	}
+}
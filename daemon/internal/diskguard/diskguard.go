@@ -0,0 +1,123 @@
+// Package diskguard watches free space on the daemon's write directories
+// and flips into a degraded mode when any of them runs low, so callers can
+// stop appending instead of failing every write.
+package diskguard
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SpaceChecker returns the bytes available to the daemon at path.
+type SpaceChecker func(path string) (uint64, error)
+
+// StatfsFree is the default SpaceChecker.
+func StatfsFree(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
+
+// Status describes the most recent check.
+type Status struct {
+	Degraded bool
+	Path     string // lowest directory checked
+	Free     uint64 // free bytes at Path
+}
+
+// Guard checks a set of directories against a minimum free threshold.
+type Guard struct {
+	dirs    []string
+	minFree uint64
+	check   SpaceChecker
+
+	mu       sync.Mutex
+	status   Status
+	onChange func(Status)
+}
+
+// New returns a Guard for dirs. A nil check uses StatfsFree; minFree == 0
+// disables the guard.
+func New(minFree uint64, check SpaceChecker, dirs ...string) *Guard {
+	if check == nil {
+		check = StatfsFree
+	}
+	return &Guard{dirs: dirs, minFree: minFree, check: check}
+}
+
+// OnChange registers fn to run whenever the guard enters or leaves
+// degraded mode.
+func (g *Guard) OnChange(fn func(Status)) {
+	g.mu.Lock()
+	g.onChange = fn
+	g.mu.Unlock()
+}
+
+// Degraded reports whether the last check found a directory below the
+// threshold.
+func (g *Guard) Degraded() bool {
+	return g.Status().Degraded
+}
+
+// Status returns the result of the last check.
+func (g *Guard) Status() Status {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.status
+}
+
+// Check measures every directory and updates the degraded state.
+// Directories that cannot be measured are ignored.
+func (g *Guard) Check() Status {
+	next := Status{}
+	if g.minFree > 0 {
+		first := true
+		for _, dir := range g.dirs {
+			if dir == "" {
+				continue
+			}
+			free, err := g.check(dir)
+			if err != nil {
+				continue
+			}
+			if first || free < next.Free {
+				next.Path, next.Free = dir, free
+				first = false
+			}
+		}
+		next.Degraded = !first && next.Free < g.minFree
+	}
+
+	g.mu.Lock()
+	changed := next.Degraded != g.status.Degraded
+	g.status = next
+	fn := g.onChange
+	g.mu.Unlock()
+
+	if changed && fn != nil {
+		fn(next)
+	}
+	return next
+}
+
+// Run checks immediately and then every interval until ctx is done.
+func (g *Guard) Run(ctx context.Context, interval time.Duration) {
+	g.Check()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.Check()
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package diskguard
+
+import "testing"
+
+func TestGuardEntersAndLeavesDegradedMode(t *testing.T) {
+	free := map[string]uint64{"/state": 500, "/logs": 500}
+	g := New(100, func(path string) (uint64, error) { return free[path], nil }, "/state", "/logs")
+
+	var transitions []Status
+	g.OnChange(func(s Status) { transitions = append(transitions, s) })
+
+	if g.Check().Degraded {
+		t.Fatal("guard degraded with ample space")
+	}
+
+	free["/logs"] = 10
+	st := g.Check()
+	if !st.Degraded || st.Path != "/logs" || st.Free != 10 {
+		t.Fatalf("low space status = %+v", st)
+	}
+	g.Check() // still low: no second alert
+	if len(transitions) != 1 || !transitions[0].Degraded {
+		t.Fatalf("transitions = %+v, want one degraded alert", transitions)
+	}
+
+	free["/logs"] = 1000
+	if g.Check().Degraded {
+		t.Fatal("guard still degraded after space recovered")
+	}
+	if len(transitions) != 2 || transitions[1].Degraded {
+		t.Fatalf("transitions = %+v, want recovery", transitions)
+	}
+}
+
+func TestGuardDisabledWithZeroThreshold(t *testing.T) {
+	g := New(0, func(string) (uint64, error) { return 0, nil }, "/state")
+	if g.Check().Degraded {
+		t.Fatal("zero threshold should disable the guard")
+	}
+}
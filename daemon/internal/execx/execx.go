@@ -0,0 +1,133 @@
+// Package execx runs external commands (bd, tmux, git, bws, codex) with a
+// consistent timeout, output capture, and optional retry.
+package execx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds a command when Options.Timeout is zero.
+const DefaultTimeout = 30 * time.Second
+
+// Runner starts a process and returns its stdout and stderr. Tests
+// substitute a fake to exercise timeout and retry without real binaries.
+type Runner func(ctx context.Context, name string, args []string, stdin string) (stdout, stderr []byte, err error)
+
+// OSRunner runs the command with os/exec, killing it when ctx ends.
+func OSRunner(ctx context.Context, name string, args []string, stdin string) ([]byte, []byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// Options tunes a single invocation.
+type Options struct {
+	Timeout time.Duration // per attempt; 0 = DefaultTimeout
+	Stdin   string
+	// Attempts > 1 retries failures. Only set it for idempotent commands.
+	Attempts   int
+	RetryDelay time.Duration
+}
+
+// Result holds captured output.
+type Result struct {
+	Stdout []byte
+	Stderr []byte
+}
+
+// String returns trimmed stdout.
+func (r Result) String() string {
+	return strings.TrimSpace(string(r.Stdout))
+}
+
+// Combined returns trimmed stdout followed by stderr.
+func (r Result) Combined() string {
+	return strings.TrimSpace(string(r.Stdout) + string(r.Stderr))
+}
+
+// Executor runs commands through a Runner.
+type Executor struct {
+	Runner Runner
+}
+
+// Default is the executor used by the package-level helpers.
+var Default = &Executor{Runner: OSRunner}
+
+// sleep is replaced in tests.
+var sleep = time.Sleep
+
+// Run executes name with args under opts. Errors name the command and
+// include its stderr; a timed-out attempt wraps context.DeadlineExceeded.
+// Cancellation of ctx itself stops retrying. A nil Executor uses OSRunner.
+func (e *Executor) Run(ctx context.Context, name string, args []string, opts Options) (Result, error) {
+	runner := Runner(OSRunner)
+	if e != nil && e.Runner != nil {
+		runner = e.Runner
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	attempts := opts.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var res Result
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		res, err = runOnce(ctx, runner, name, args, opts.Stdin, timeout)
+		if err == nil || ctx.Err() != nil || attempt == attempts {
+			break
+		}
+		if opts.RetryDelay > 0 {
+			sleep(opts.RetryDelay)
+		}
+	}
+	if err != nil && attempts > 1 {
+		err = fmt.Errorf("%w (after %d attempts)", err, attempts)
+	}
+	return res, err
+}
+
+func runOnce(ctx context.Context, runner Runner, name string, args []string, stdin string, timeout time.Duration) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout, stderr, err := runner(ctx, name, args, stdin)
+	res := Result{Stdout: stdout, Stderr: stderr}
+	if err == nil {
+		return res, nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return res, fmt.Errorf("%s: timed out after %s: %w", describe(name, args), timeout, context.DeadlineExceeded)
+	}
+	if detail := strings.TrimSpace(string(stderr)); detail != "" {
+		return res, fmt.Errorf("%s: %w: %s", describe(name, args), err, detail)
+	}
+	return res, fmt.Errorf("%s: %w", describe(name, args), err)
+}
+
+func describe(name string, args []string) string {
+	if len(args) == 0 {
+		return name
+	}
+	return name + " " + strings.Join(args, " ")
+}
+
+// RunWithTimeout runs name once through Default with the given timeout.
+func RunWithTimeout(ctx context.Context, name string, args []string, timeout time.Duration) (Result, error) {
+	return Default.Run(ctx, name, args, Options{Timeout: timeout})
+}
@@ -0,0 +1,91 @@
+package execx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunSuccessCapturesOutput(t *testing.T) {
+	var gotStdin string
+	e := &Executor{Runner: func(ctx context.Context, name string, args []string, stdin string) ([]byte, []byte, error) {
+		gotStdin = stdin
+		if name != "git" || strings.Join(args, " ") != "rev-parse --show-toplevel" {
+			t.Fatalf("unexpected command %s %v", name, args)
+		}
+		return []byte("/repo\n"), []byte("warn\n"), nil
+	}}
+
+	res, err := e.Run(context.Background(), "git", []string{"rev-parse", "--show-toplevel"}, Options{Stdin: "in"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.String() != "/repo" || res.Combined() != "/repo\nwarn" || gotStdin != "in" {
+		t.Fatalf("result = %q / %q, stdin %q", res.String(), res.Combined(), gotStdin)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	e := &Executor{Runner: func(ctx context.Context, name string, args []string, stdin string) ([]byte, []byte, error) {
+		<-ctx.Done()
+		return nil, nil, errors.New("signal: killed")
+	}}
+
+	start := time.Now()
+	_, err := e.Run(context.Background(), "tmux", []string{"list-panes"}, Options{Timeout: 20 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+	if !strings.Contains(err.Error(), "tmux list-panes") {
+		t.Fatalf("error does not name the command: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("timeout took %s", elapsed)
+	}
+}
+
+func TestRunRetriesIdempotentCommands(t *testing.T) {
+	origSleep := sleep
+	defer func() { sleep = origSleep }()
+	var slept int
+	sleep = func(time.Duration) { slept++ }
+
+	calls := 0
+	e := &Executor{Runner: func(ctx context.Context, name string, args []string, stdin string) ([]byte, []byte, error) {
+		calls++
+		if calls < 3 {
+			return nil, []byte("busy"), errors.New("exit status 1")
+		}
+		return []byte("ok"), nil, nil
+	}}
+
+	res, err := e.Run(context.Background(), "bd", []string{"show", "x"}, Options{Attempts: 3, RetryDelay: time.Millisecond})
+	if err != nil || res.String() != "ok" {
+		t.Fatalf("Run = %q, %v", res.String(), err)
+	}
+	if calls != 3 || slept != 2 {
+		t.Fatalf("calls = %d, sleeps = %d", calls, slept)
+	}
+
+	calls = -10
+	_, err = e.Run(context.Background(), "bd", []string{"show", "x"}, Options{Attempts: 2})
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts") || !strings.Contains(err.Error(), "busy") {
+		t.Fatalf("exhausted err = %v", err)
+	}
+}
+
+func TestRunWithoutAttemptsDoesNotRetry(t *testing.T) {
+	calls := 0
+	e := &Executor{Runner: func(ctx context.Context, name string, args []string, stdin string) ([]byte, []byte, error) {
+		calls++
+		return nil, nil, errors.New("exit status 1")
+	}}
+	if _, err := e.Run(context.Background(), "tmux", []string{"send-keys"}, Options{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+}
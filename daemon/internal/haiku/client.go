@@ -5,18 +5,40 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"math"
 	"os"
-	"os/exec"
+	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/norm/relay-daemon/internal/execx"
+	"github.com/norm/relay-daemon/internal/redact"
 )
 
-// ModelHaiku3 is the Claude Haiku 3 model ID.
-const ModelHaiku3 = "claude-3-haiku-20240307"
+// Claude Haiku model IDs.
+const (
+	ModelHaiku3  = "claude-3-haiku-20240307"
+	ModelHaiku35 = "claude-3-5-haiku-20241022"
+	ModelHaiku45 = "claude-haiku-4-5-20251001"
+)
+
+// ModelEnv names the environment variable that overrides Config.Model.
+const ModelEnv = "RELAY_HAIKU_MODEL"
+
+var modelIDRe = regexp.MustCompile(`^claude-[a-z0-9]+(?:[-.][a-z0-9]+)*$`)
+
+// ValidateModel rejects strings that cannot be Claude model IDs, such as
+// a bare "haiku", a stray quote or whitespace.
+func ValidateModel(model string) error {
+	if !modelIDRe.MatchString(model) {
+		return fmt.Errorf("invalid model %q (want a Claude model ID like %s)", model, ModelHaiku35)
+	}
+	return nil
+}
 
 // Config holds Haiku client configuration.
 type Config struct {
@@ -26,6 +48,10 @@ type Config struct {
 	// Max tokens for output
 	MaxTokens int
 
+	// MaxInputTokens caps user content, estimated at bytesPerToken bytes
+	// per token; longer input is truncated before sending (0 = no cap)
+	MaxInputTokens int
+
 	// Retry settings
 	MaxRetries     int
 	RetryBaseDelay time.Duration
@@ -35,6 +61,14 @@ type Config struct {
 
 	// BWS secret ID for API key (optional)
 	BWSSecretID string
+
+	// Redactor masks secrets in user content before it is sent. New fills
+	// in redact.Default() when it is nil.
+	Redactor *redact.Redactor
+
+	// DisableRedaction sends user content unredacted; a nil Redactor alone
+	// does not.
+	DisableRedaction bool
 }
 
 // DefaultConfig returns sensible defaults.
@@ -42,8 +76,10 @@ func DefaultConfig() *Config {
 	return &Config{
 		Model:          ModelHaiku3,
 		MaxTokens:      500,
+		MaxInputTokens: 100000,
 		MaxRetries:     3,
 		RetryBaseDelay: time.Second,
+		Redactor:       redact.Default(),
 	}
 }
 
@@ -53,11 +89,28 @@ type Client struct {
 	client anthropic.Client
 }
 
-// New creates a new Haiku client.
+// New creates a new Haiku client. RELAY_HAIKU_MODEL, when set, overrides
+// cfg.Model; the chosen model must pass ValidateModel.
 func New(cfg *Config) (*Client, error) {
 	if cfg == nil {
 		cfg = DefaultConfig()
 	}
+	copied := *cfg
+	cfg = &copied
+	if model := strings.TrimSpace(os.Getenv(ModelEnv)); model != "" {
+		cfg.Model = model
+	}
+	if cfg.Model == "" {
+		cfg.Model = ModelHaiku3
+	}
+	if cfg.DisableRedaction {
+		cfg.Redactor = nil
+	} else if cfg.Redactor == nil {
+		cfg.Redactor = redact.Default()
+	}
+	if err := ValidateModel(cfg.Model); err != nil {
+		return nil, fmt.Errorf("haiku: %w", err)
+	}
 
 	apiKey, err := resolveAPIKey(cfg)
 	if err != nil {
@@ -75,6 +128,46 @@ func New(cfg *Config) (*Client, error) {
 // Summarize sends a prompt to Haiku and returns the response.
 // Includes retry logic with exponential backoff.
 func (c *Client) Summarize(ctx context.Context, systemPrompt, userContent string) (string, error) {
+	userContent = c.prepareInput(userContent)
+	var result string
+	err := c.withRetries(ctx, func() error {
+		var err error
+		result, err = c.doRequest(ctx, systemPrompt, userContent)
+		return err
+	})
+	return result, err
+}
+
+// StopReasonMaxTokens is the stop reason of a response cut off at MaxTokens.
+const StopReasonMaxTokens = string(anthropic.StopReasonMaxTokens)
+
+// SummarizeStream is Summarize over a streaming request. It returns the
+// accumulated text with the response's stop reason, so a caller can tell a
+// summary cut off at MaxTokens (StopReasonMaxTokens) from a complete one
+// and retry with a larger budget. Retries only cover failed requests.
+func (c *Client) SummarizeStream(ctx context.Context, systemPrompt, userContent string) (text, stopReason string, err error) {
+	userContent = c.prepareInput(userContent)
+	err = c.withRetries(ctx, func() error {
+		var err error
+		text, stopReason, err = c.doStream(ctx, systemPrompt, userContent)
+		return err
+	})
+	return text, stopReason, err
+}
+
+// prepareInput redacts secrets and applies the input token budget.
+func (c *Client) prepareInput(userContent string) string {
+	userContent = c.cfg.Redactor.Redact(userContent)
+	if trimmed, ok := truncateInput(userContent, c.cfg.MaxInputTokens); ok {
+		log.Printf("haiku: input truncated from %d to %d bytes (max_input_tokens=%d)", len(userContent), len(trimmed), c.cfg.MaxInputTokens)
+		userContent = trimmed
+	}
+	return userContent
+}
+
+// withRetries runs fn until it succeeds, fails with a non-retryable error,
+// or MaxRetries retries are spent, backing off exponentially in between.
+func (c *Client) withRetries(ctx context.Context, fn func() error) error {
 	var lastErr error
 
 	for attempt := 0; attempt <= c.cfg.MaxRetries; attempt++ {
@@ -83,29 +176,77 @@ func (c *Client) Summarize(ctx context.Context, systemPrompt, userContent string
 			delay := c.cfg.RetryBaseDelay * time.Duration(math.Pow(2, float64(attempt-1)))
 			select {
 			case <-ctx.Done():
-				return "", ctx.Err()
+				return ctx.Err()
 			case <-time.After(delay):
 			}
 		}
 
-		result, err := c.doRequest(ctx, systemPrompt, userContent)
+		err := fn()
 		if err == nil {
-			return result, nil
+			return nil
 		}
 
 		lastErr = err
 
 		// Check if error is retryable
 		if !isRetryable(err) {
-			return "", err
+			return err
 		}
 	}
 
-	return "", fmt.Errorf("haiku: max retries exceeded: %w", lastErr)
+	return fmt.Errorf("haiku: max retries exceeded: %w", lastErr)
+}
+
+// bytesPerToken is the rough input size estimate used for MaxInputTokens.
+const bytesPerToken = 4
+
+// truncatedMarker ends input cut to fit MaxInputTokens.
+const truncatedMarker = "\n[truncated]"
+
+// truncateInput cuts content to the maxTokens budget on a rune boundary,
+// keeping the beginning. ok is false when content already fits.
+func truncateInput(content string, maxTokens int) (string, bool) {
+	budget := maxTokens * bytesPerToken
+	if maxTokens <= 0 || len(content) <= budget {
+		return content, false
+	}
+	cut := budget - len(truncatedMarker)
+	if cut < 0 {
+		cut = 0
+	}
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut] + truncatedMarker, true
 }
 
 // doRequest performs a single API request.
 func (c *Client) doRequest(ctx context.Context, systemPrompt, userContent string) (string, error) {
+	resp, err := c.client.Messages.New(ctx, c.params(systemPrompt, userContent))
+	if err != nil {
+		return "", fmt.Errorf("haiku request: %w", err)
+	}
+	return responseText(resp), nil
+}
+
+// doStream performs a single streaming API request.
+func (c *Client) doStream(ctx context.Context, systemPrompt, userContent string) (string, string, error) {
+	stream := c.client.Messages.NewStreaming(ctx, c.params(systemPrompt, userContent))
+	defer stream.Close()
+
+	var msg anthropic.Message
+	for stream.Next() {
+		if err := msg.Accumulate(stream.Current()); err != nil {
+			return "", "", fmt.Errorf("haiku stream: %w", err)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return "", "", fmt.Errorf("haiku stream: %w", err)
+	}
+	return responseText(&msg), string(msg.StopReason), nil
+}
+
+func (c *Client) params(systemPrompt, userContent string) anthropic.MessageNewParams {
 	model := c.cfg.Model
 	if model == "" {
 		model = ModelHaiku3
@@ -116,7 +257,7 @@ func (c *Client) doRequest(ctx context.Context, systemPrompt, userContent string
 		maxTokens = 500
 	}
 
-	resp, err := c.client.Messages.New(ctx, anthropic.MessageNewParams{
+	return anthropic.MessageNewParams{
 		Model:     anthropic.Model(model),
 		MaxTokens: int64(maxTokens),
 		System: []anthropic.TextBlockParam{
@@ -125,20 +266,18 @@ func (c *Client) doRequest(ctx context.Context, systemPrompt, userContent string
 		Messages: []anthropic.MessageParam{
 			anthropic.NewUserMessage(anthropic.NewTextBlock(userContent)),
 		},
-	})
-	if err != nil {
-		return "", fmt.Errorf("haiku request: %w", err)
 	}
+}
 
-	// Extract text from response
+// responseText concatenates the text blocks of a response.
+func responseText(msg *anthropic.Message) string {
 	var result strings.Builder
-	for _, block := range resp.Content {
+	for _, block := range msg.Content {
 		if block.Type == "text" {
 			result.WriteString(block.Text)
 		}
 	}
-
-	return result.String(), nil
+	return result.String()
 }
 
 // resolveAPIKey gets the API key from config, BWS, or environment.
@@ -167,15 +306,19 @@ func resolveAPIKey(cfg *Config) (string, error) {
 
 // getBWSSecret retrieves a secret from Bitwarden Secrets Manager.
 func getBWSSecret(secretID string) (string, error) {
-	cmd := exec.Command("bws", "secret", "get", secretID, "--output", "json")
-	output, err := cmd.Output()
+	// Reads are idempotent, so a transient bws failure gets one retry.
+	res, err := execx.Default.Run(context.Background(), "bws", []string{"secret", "get", secretID, "--output", "json"}, execx.Options{
+		Timeout:    15 * time.Second,
+		Attempts:   2,
+		RetryDelay: 500 * time.Millisecond,
+	})
 	if err != nil {
 		return "", fmt.Errorf("bws get secret: %w", err)
 	}
 
 	// Parse JSON output - bws returns {"id":"...","value":"..."}
 	// Simple extraction without full JSON parsing
-	value := extractJSONValue(string(output), "value")
+	value := extractJSONValue(string(res.Stdout), "value")
 	if value == "" {
 		return "", errors.New("bws: empty secret value")
 	}
@@ -0,0 +1,79 @@
+package inbox
+
+import (
+	"sort"
+	"time"
+)
+
+// fsnotify reports one append as several Write events. Reads of a file are
+// held until its events go quiet for debounceWindow, but never longer than
+// debounceMaxDelay after the first event, so a file written continuously
+// is still delivered promptly.
+const (
+	debounceWindow   = 50 * time.Millisecond
+	debounceMaxDelay = 250 * time.Millisecond
+)
+
+type pendingRead struct {
+	first time.Time
+	last  time.Time
+}
+
+// debouncer coalesces per-path events. It is used only from the watcher
+// loop and is not safe for concurrent use.
+type debouncer struct {
+	window   time.Duration
+	maxDelay time.Duration
+	pending  map[string]pendingRead
+}
+
+func newDebouncer(window, maxDelay time.Duration) *debouncer {
+	return &debouncer{window: window, maxDelay: maxDelay, pending: make(map[string]pendingRead)}
+}
+
+// add records an event for path at now.
+func (d *debouncer) add(path string, now time.Time) {
+	p, ok := d.pending[path]
+	if !ok {
+		p.first = now
+	}
+	p.last = now
+	d.pending[path] = p
+}
+
+// drop forgets path, e.g. once the file is removed.
+func (d *debouncer) drop(path string) {
+	delete(d.pending, path)
+}
+
+func (d *debouncer) dueAt(p pendingRead) time.Time {
+	quiet := p.last.Add(d.window)
+	if bound := p.first.Add(d.maxDelay); bound.Before(quiet) {
+		return bound
+	}
+	return quiet
+}
+
+// due removes and returns the paths ready to read at now, sorted.
+func (d *debouncer) due(now time.Time) []string {
+	var paths []string
+	for path, p := range d.pending {
+		if !d.dueAt(p).After(now) {
+			paths = append(paths, path)
+			delete(d.pending, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// next returns when the earliest pending path becomes due.
+func (d *debouncer) next() (time.Time, bool) {
+	var at time.Time
+	for _, p := range d.pending {
+		if due := d.dueAt(p); at.IsZero() || due.Before(at) {
+			at = due
+		}
+	}
+	return at, !at.IsZero()
+}
@@ -0,0 +1,118 @@
+package inbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// hashRetention bounds how long a delivered file's hash is remembered.
+const hashRetention = 24 * time.Hour
+
+// hashPruneInterval is how often recording a delivery also drops hashes
+// past hashRetention. Delivered files are removed and new ones get unique
+// names, so without this the map grows for as long as the daemon runs.
+const hashPruneInterval = time.Hour
+
+// DeliveredHash records the content hash of the last delivery from a path.
+type DeliveredHash struct {
+	Hash string    `json:"hash"`
+	At   time.Time `json:"at"`
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// EnableContentHashing switches the watcher from size-based change
+// detection to content hashes: a file is delivered only when its content
+// differs from the last delivery from the same path, so a rewrite with
+// identical content is not re-delivered and a same-size rewrite with new
+// content is. hashes seeds the state (nil starts empty).
+func (w *Watcher) EnableContentHashing(hashes map[string]DeliveredHash) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if hashes == nil {
+		hashes = make(map[string]DeliveredHash)
+	}
+	w.hashes = hashes
+}
+
+// LoadHashes reads the content-hash sidecar written by SaveHashes.
+func LoadHashes(path string) (map[string]DeliveredHash, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]DeliveredHash), nil
+		}
+		return nil, err
+	}
+	hashes := make(map[string]DeliveredHash)
+	if len(data) == 0 {
+		return hashes, nil
+	}
+	if err := json.Unmarshal(data, &hashes); err != nil {
+		return nil, fmt.Errorf("decode inbox hashes: %w", err)
+	}
+	return hashes, nil
+}
+
+// SaveHashes writes the content-hash sidecar, dropping entries older than
+// hashRetention. It is a no-op when hashing is disabled.
+func (w *Watcher) SaveHashes(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.hashes == nil {
+		return nil
+	}
+	w.pruneHashesLocked(time.Now())
+	data, err := json.MarshalIndent(w.hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// recordHashLocked remembers the hash delivered from path, pruning expired
+// entries at most once per hashPruneInterval. w.mu must be held.
+func (w *Watcher) recordHashLocked(path, hash string, now time.Time) {
+	w.hashes[path] = DeliveredHash{Hash: hash, At: now}
+	if now.Sub(w.hashesPruned) >= hashPruneInterval {
+		w.pruneHashesLocked(now)
+	}
+}
+
+// pruneHashesLocked drops hashes older than hashRetention. w.mu must be
+// held.
+func (w *Watcher) pruneHashesLocked(now time.Time) {
+	cutoff := now.Add(-hashRetention)
+	for p, h := range w.hashes {
+		if h.At.Before(cutoff) {
+			delete(w.hashes, p)
+		}
+	}
+	w.hashesPruned = now
+}
+
+// Hashes returns a copy of the delivered-content hashes, or nil when
+// content hashing is disabled.
+func (w *Watcher) Hashes() map[string]DeliveredHash {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.hashes == nil {
+		return nil
+	}
+	out := make(map[string]DeliveredHash, len(w.hashes))
+	for path, h := range w.hashes {
+		out[path] = h
+	}
+	return out
+}
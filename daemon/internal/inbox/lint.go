@@ -0,0 +1,86 @@
+package inbox
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/norm/relay-daemon/pkg/envelope"
+)
+
+// knownHeaders lists the RMF v2 header keys the parser understands.
+var knownHeaders = map[string]struct{}{
+	"to": {}, "from": {}, "project": {}, "project_id": {}, "kind": {},
+	"thread": {}, "thread_id": {}, "msg_id": {}, "ts": {}, "priority": {},
+	"ephemeral": {}, "receipt": {}, "request_receipt": {},
+	"ttl": {}, "expires": {}, "expires_at": {},
+}
+
+// ValidateMessageBytes runs the full parse and validate pipeline over an RMF
+// v2 message without delivering it. Warnings describe values the parser
+// would silently default or ignore; err is set when the watcher would skip
+// the message.
+func ValidateMessageBytes(message []byte) (*envelope.Envelope, []string, error) {
+	var warnings []string
+	headers, body, ok := splitMessage(message)
+	if !ok {
+		return nil, nil, fmt.Errorf("rmf: empty message")
+	}
+
+	present := map[string]bool{}
+	for _, line := range headers {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon == -1 {
+			warnings = append(warnings, fmt.Sprintf("header line %q has no colon (ignored)", line))
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:colon]))
+		if _, known := knownHeaders[key]; !known {
+			warnings = append(warnings, fmt.Sprintf("unknown header %q (ignored)", key))
+			continue
+		}
+		present[key] = true
+	}
+
+	env, err := ParseMessage(message)
+	if err != nil {
+		return nil, warnings, err
+	}
+	if env == nil {
+		return nil, warnings, fmt.Errorf("rmf: empty message")
+	}
+
+	if !present["msg_id"] {
+		warnings = append(warnings, "msg_id generated")
+	}
+	if !present["ts"] {
+		warnings = append(warnings, "ts generated")
+	} else if _, ok := PeekTimestamp(message); !ok {
+		warnings = append(warnings, fmt.Sprintf("ts %q is not RFC3339", env.Timestamp))
+	}
+	if !present["kind"] {
+		warnings = append(warnings, "kind defaulted to chat")
+	}
+	if !present["priority"] {
+		warnings = append(warnings, "priority defaulted to 1")
+	}
+	if strings.TrimSpace(body) == "" {
+		warnings = append(warnings, "empty body")
+	}
+
+	// FROM is always overwritten with the outbox directory name on delivery,
+	// so only validate the remaining fields here.
+	check := *env
+	if check.From == "" {
+		check.From = "outbox"
+	} else {
+		warnings = append(warnings, "from header is replaced by the outbox directory name on delivery")
+	}
+	if err := check.Validate(); err != nil {
+		return env, warnings, err
+	}
+	return env, warnings, nil
+}
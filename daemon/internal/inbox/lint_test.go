@@ -0,0 +1,49 @@
+package inbox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMessageBytesClean(t *testing.T) {
+	msg := "TO: cc\nKIND: chat\nMSG_ID: msg-1\nTS: 2026-01-01T10:00:00Z\nPRIORITY: 1\n---\nhello"
+	env, warnings, err := ValidateMessageBytes([]byte(msg))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %v", warnings)
+	}
+	if env.To != "cc" || env.MsgID != "msg-1" {
+		t.Fatalf("unexpected envelope %+v", env)
+	}
+}
+
+func TestValidateMessageBytesWarnings(t *testing.T) {
+	msg := "TO: cc\nURGENT: yes\n---\nhello"
+	env, warnings, err := ValidateMessageBytes([]byte(msg))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env == nil {
+		t.Fatal("expected envelope")
+	}
+	joined := strings.Join(warnings, "; ")
+	for _, want := range []string{"msg_id generated", "ts generated", "kind defaulted", "priority defaulted", `unknown header "urgent"`} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected warning %q in %q", want, joined)
+		}
+	}
+}
+
+func TestValidateMessageBytesInvalid(t *testing.T) {
+	if _, _, err := ValidateMessageBytes([]byte("KIND: chat\n---\nno recipient")); err == nil {
+		t.Fatal("expected error for missing TO")
+	}
+	if _, _, err := ValidateMessageBytes([]byte("TO: cc\nPRIORITY: high\n---\nbody")); err == nil {
+		t.Fatal("expected error for invalid priority")
+	}
+	if _, _, err := ValidateMessageBytes([]byte("   \n")); err == nil {
+		t.Fatal("expected error for empty message")
+	}
+}
@@ -31,51 +31,10 @@ func ParseMessageWithDefaults(message []byte, defaults Defaults) (*envelope.Enve
 		return nil, nil
 	}
 
-	lines := strings.Split(strings.ReplaceAll(string(message), "\r\n", "\n"), "\n")
-	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
-		lines = lines[1:]
-	}
-	if len(lines) == 0 {
+	headers, body, ok := splitMessage(message)
+	if !ok {
 		return nil, nil
 	}
-	separator := -1
-	for i, line := range lines {
-		if strings.TrimSpace(line) == "---" {
-			separator = i
-			break
-		}
-	}
-
-	var headers []string
-	var bodyLines []string
-	if separator >= 0 {
-		headers = lines[:separator]
-		if separator+1 < len(lines) {
-			bodyLines = lines[separator+1:]
-		}
-	} else {
-		blankIndex := -1
-		for i, line := range lines {
-			if strings.TrimSpace(line) == "" {
-				blankIndex = i
-				break
-			}
-		}
-		if blankIndex >= 0 {
-			headers = lines[:blankIndex]
-			if blankIndex+1 < len(lines) {
-				bodyLines = lines[blankIndex+1:]
-			}
-		} else if strings.Contains(lines[0], ":") {
-			headers = lines[:1]
-			if len(lines) > 1 {
-				bodyLines = lines[1:]
-			}
-		} else {
-			bodyLines = lines
-		}
-	}
-	body := strings.Join(bodyLines, "\n")
 
 	env := envelope.Envelope{
 		Payload: body,
@@ -131,6 +90,33 @@ func ParseMessageWithDefaults(message []byte, defaults Defaults) (*envelope.Enve
 				return nil, fmt.Errorf("rmf: invalid ephemeral %q: %w", value, err)
 			}
 			env.Ephemeral = ephemeral
+		case "receipt", "request_receipt":
+			if value == "" {
+				continue
+			}
+			receipt, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("rmf: invalid receipt %q: %w", value, err)
+			}
+			env.RequestReceipt = receipt
+		case "ttl":
+			if value == "" {
+				continue
+			}
+			ttl, err := parseTTL(value)
+			if err != nil {
+				return nil, err
+			}
+			env.ExpiresAt = time.Now().Add(ttl).UTC().Format(time.RFC3339Nano)
+		case "expires", "expires_at":
+			if value == "" {
+				continue
+			}
+			expires, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, fmt.Errorf("rmf: invalid expires %q: %w", value, err)
+			}
+			env.ExpiresAt = expires.UTC().Format(time.RFC3339Nano)
 		}
 	}
 
@@ -159,6 +145,97 @@ func ParseMessageWithDefaults(message []byte, defaults Defaults) (*envelope.Enve
 	return &env, nil
 }
 
+// parseTTL reads a TTL header: a Go duration ("90s", "5m") or a bare
+// number of seconds. The TTL counts from when the relay reads the message.
+func parseTTL(value string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		secs, atoiErr := strconv.Atoi(value)
+		if atoiErr != nil {
+			return 0, fmt.Errorf("rmf: invalid ttl %q: %w", value, err)
+		}
+		ttl = time.Duration(secs) * time.Second
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("rmf: invalid ttl %q: must be positive", value)
+	}
+	return ttl, nil
+}
+
+// splitMessage separates an RMF v2 message into header lines and body.
+// Returns ok=false when the message contains only blank lines.
+func splitMessage(message []byte) ([]string, string, bool) {
+	lines := strings.Split(strings.ReplaceAll(string(message), "\r\n", "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return nil, "", false
+	}
+	separator := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "---" {
+			separator = i
+			break
+		}
+	}
+
+	var headers []string
+	var bodyLines []string
+	if separator >= 0 {
+		headers = lines[:separator]
+		if separator+1 < len(lines) {
+			bodyLines = lines[separator+1:]
+		}
+	} else {
+		blankIndex := -1
+		for i, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				blankIndex = i
+				break
+			}
+		}
+		if blankIndex >= 0 {
+			headers = lines[:blankIndex]
+			if blankIndex+1 < len(lines) {
+				bodyLines = lines[blankIndex+1:]
+			}
+		} else if strings.Contains(lines[0], ":") {
+			headers = lines[:1]
+			if len(lines) > 1 {
+				bodyLines = lines[1:]
+			}
+		} else {
+			bodyLines = lines
+		}
+	}
+	return headers, strings.Join(bodyLines, "\n"), true
+}
+
+// PeekTimestamp returns the TS header of an RMF v2 message without
+// applying defaults. ok is false when the header is absent or unparseable.
+func PeekTimestamp(message []byte) (time.Time, bool) {
+	headers, _, ok := splitMessage(message)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, line := range headers {
+		colon := strings.Index(line, ":")
+		if colon == -1 {
+			continue
+		}
+		if strings.ToLower(strings.TrimSpace(line[:colon])) != "ts" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, strings.TrimSpace(line[colon+1:]))
+		if err != nil {
+			return time.Time{}, false
+		}
+		return ts, true
+	}
+	return time.Time{}, false
+}
+
 // ParseFile reads a single RMF v2 message from a file.
 func ParseFile(path string) ([]*envelope.Envelope, error) {
 	file, err := os.Open(path)
@@ -189,3 +266,32 @@ func ParseFile(path string) ([]*envelope.Envelope, error) {
 	}
 	return []*envelope.Envelope{env}, nil
 }
+
+// FormatMessage renders env as an RMF v2 message that ParseMessage reads
+// back to the same envelope. FROM is omitted: the watcher takes the sender
+// from the outbox directory the file is written to.
+func FormatMessage(env *envelope.Envelope) []byte {
+	var b strings.Builder
+	header := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", key, value)
+		}
+	}
+	header("TO", env.To)
+	header("KIND", env.Kind)
+	header("MSG_ID", env.MsgID)
+	header("TS", env.Timestamp)
+	header("PRIORITY", strconv.Itoa(env.Priority))
+	header("THREAD", env.ThreadID)
+	header("PROJECT", env.ProjectID)
+	if env.Ephemeral {
+		header("EPHEMERAL", "true")
+	}
+	if env.RequestReceipt {
+		header("RECEIPT", "true")
+	}
+	header("EXPIRES", env.ExpiresAt)
+	b.WriteString("---\n")
+	b.WriteString(env.Payload)
+	return []byte(b.String())
+}
@@ -3,6 +3,9 @@ package inbox
 import (
 	"errors"
 	"testing"
+	"time"
+
+	"github.com/norm/relay-daemon/pkg/envelope"
 )
 
 func TestParseMessageWithDefaultsBasic(t *testing.T) {
@@ -35,7 +38,7 @@ func TestParseMessageWithDefaultsBasic(t *testing.T) {
 }
 
 func TestParseMessageWithHeaders(t *testing.T) {
-	msg := "TO: cc\nFROM: oc\nPRIORITY: 2\nTHREAD: t-1\nKIND: notice\nEPHEMERAL: true\n---\nbody"
+	msg := "TO: cc\nFROM: oc\nPRIORITY: 2\nTHREAD: t-1\nKIND: notice\nEPHEMERAL: true\nRECEIPT: true\n---\nbody"
 	env, err := ParseMessage([]byte(msg))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -61,6 +64,9 @@ func TestParseMessageWithHeaders(t *testing.T) {
 	if env.Ephemeral != true {
 		t.Fatalf("expected ephemeral true, got %v", env.Ephemeral)
 	}
+	if !env.RequestReceipt {
+		t.Fatal("expected receipt requested")
+	}
 	if env.Payload != "body" {
 		t.Fatalf("expected payload body, got %q", env.Payload)
 	}
@@ -121,3 +127,63 @@ func TestParseMessageLeadingBlankLines(t *testing.T) {
 		t.Fatalf("expected payload hello, got %q", env.Payload)
 	}
 }
+
+func TestParseMessageTTL(t *testing.T) {
+	before := time.Now()
+	env, err := ParseMessage([]byte("TO: cc\nTTL: 30s\n---\nping"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expiry, ok := env.Expiry()
+	if !ok || expiry.Before(before.Add(29*time.Second)) || expiry.After(before.Add(31*time.Second)) {
+		t.Fatalf("expires_at = %q, want about 30s from now", env.ExpiresAt)
+	}
+
+	// Sub-second precision is kept, so a short TTL is not cut short.
+	env, err = ParseMessage([]byte("TO: cc\nTTL: 1500ms\n---\nping"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expiry, _ := env.Expiry(); expiry.Before(before.Add(1500 * time.Millisecond)) {
+		t.Fatalf("1500ms ttl: expires_at = %q", env.ExpiresAt)
+	}
+
+	env, err = ParseMessage([]byte("TO: cc\nTTL: 600\n---\nping"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expiry, _ := env.Expiry(); expiry.Before(before.Add(599 * time.Second)) {
+		t.Fatalf("bare seconds ttl: expires_at = %q", env.ExpiresAt)
+	}
+
+	env, err = ParseMessage([]byte("TO: cc\nEXPIRES: 2026-01-02T03:04:05+01:00\n---\nping"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env.ExpiresAt != "2026-01-02T02:04:05Z" {
+		t.Fatalf("expires_at = %q", env.ExpiresAt)
+	}
+
+	for _, bad := range []string{"TTL: soon", "TTL: -5s", "EXPIRES: tomorrow"} {
+		if _, err := ParseMessage([]byte("TO: cc\n" + bad + "\n---\nping")); err == nil {
+			t.Fatalf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestFormatMessageRoundTrip(t *testing.T) {
+	env := envelope.NewEnvelope("oc", "cc", "command", "line one\n\nKIND: not a header\n---\nend")
+	env.Priority = 0
+	env.ThreadID = "atk-1"
+	env.ProjectID = "party"
+	env.RequestReceipt = true
+	env.ExpiresAt = "2026-01-02T03:04:05Z"
+
+	got, err := ParseMessageWithDefaults(FormatMessage(env), Defaults{From: "oc"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if *got != *env {
+		t.Fatalf("round trip:\n got %+v\nwant %+v", *got, *env)
+	}
+}
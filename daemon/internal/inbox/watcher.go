@@ -7,43 +7,115 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/fsnotify/fsnotify"
+	logpkg "github.com/norm/relay-daemon/internal/log"
+	"github.com/norm/relay-daemon/internal/routing"
 	"github.com/norm/relay-daemon/pkg/envelope"
 )
 
 // Watcher monitors inbox files and emits new envelopes.
 type Watcher struct {
-	inboxDir string
-	watcher  *fsnotify.Watcher
-	events   chan *envelope.Envelope
-	mu       sync.Mutex
-	offsets  map[string]int64
-	valid    map[string]struct{}
+	roots   []string // absolute inbox roots, one outbox subdir per agent
+	watcher *fsnotify.Watcher
+	events  chan *envelope.Envelope
+	mu      sync.Mutex
+	offsets map[string]int64
+	valid   map[string]struct{}
+	hashes  map[string]DeliveredHash // nil unless content hashing is enabled
+	// hashesPruned is when expired hashes were last dropped.
+	hashesPruned time.Time
+	// read is readNew; tests wrap it to count reads.
+	read func(path string) error
+	// eventLog, when set, records outbox files that fail to parse;
+	// parseFailures holds the content hash last reported per path so a
+	// file is reported once per version.
+	eventLog      *logpkg.EventLog
+	parseFailures map[string]string
 }
 
 func NewWatcher(inboxDir string) (*Watcher, error) {
+	return NewMultiRootWatcher([]string{inboxDir})
+}
+
+// NewMultiRootWatcher watches several inbox roots, e.g. the outbox trees
+// of more than one pod. Roots are made absolute and de-duplicated, so
+// offsets are keyed by absolute path no matter how a root was spelled.
+func NewMultiRootWatcher(roots []string) (*Watcher, error) {
+	if len(roots) == 0 {
+		return nil, errors.New("no inbox roots")
+	}
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
 
-	return &Watcher{
-		inboxDir: inboxDir,
-		watcher:  watcher,
-		events:   make(chan *envelope.Envelope, 1024),
-		offsets:  make(map[string]int64),
-		valid: map[string]struct{}{
-			"oc":    {},
-			"cc":    {},
-			"cx":    {},
-			"admin": {},
-			"vog":   {},
-		},
-	}, nil
+	w := &Watcher{
+		roots:         uniqueRoots(roots),
+		watcher:       watcher,
+		events:        make(chan *envelope.Envelope, 1024),
+		offsets:       make(map[string]int64),
+		valid:         validAgents(),
+		parseFailures: make(map[string]string),
+	}
+	w.read = w.readNew
+	return w, nil
+}
+
+func uniqueRoots(roots []string) []string {
+	seen := make(map[string]bool, len(roots))
+	out := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if abs, err := filepath.Abs(root); err == nil {
+			root = abs
+		}
+		root = filepath.Clean(root)
+		if seen[root] {
+			continue
+		}
+		seen[root] = true
+		out = append(out, root)
+	}
+	return out
+}
+
+// validAgents is the set of inbox directories read as agent outboxes.
+func validAgents() map[string]struct{} {
+	valid := make(map[string]struct{})
+	for _, role := range routing.KnownRoles() {
+		valid[role] = struct{}{}
+	}
+	return valid
+}
+
+// SetEventLog records parse failures as inbox_parse_error events.
+func (w *Watcher) SetEventLog(l *logpkg.EventLog) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.eventLog = l
+}
+
+// SetValidAgents replaces the set of outbox directories read as agents,
+// e.g. with the pane map's roles. Names are matched case-insensitively;
+// an empty list restores the known roles.
+func (w *Watcher) SetValidAgents(agents []string) {
+	valid := make(map[string]struct{}, len(agents))
+	for _, agent := range agents {
+		if agent = strings.ToLower(strings.TrimSpace(agent)); agent != "" {
+			valid[agent] = struct{}{}
+		}
+	}
+	if len(valid) == 0 {
+		valid = validAgents()
+	}
+	w.mu.Lock()
+	w.valid = valid
+	w.mu.Unlock()
 }
 
 func (w *Watcher) Events() <-chan *envelope.Envelope {
@@ -51,12 +123,13 @@ func (w *Watcher) Events() <-chan *envelope.Envelope {
 }
 
 func (w *Watcher) Start(ctx context.Context) error {
-	if err := w.watcher.Add(w.inboxDir); err != nil {
-		return err
-	}
-
-	if err := w.watchSubdirs(); err != nil {
-		return err
+	for _, root := range w.roots {
+		if err := w.watcher.Add(root); err != nil {
+			return err
+		}
+		if err := w.watchSubdirs(root); err != nil {
+			return err
+		}
 	}
 
 	if err := w.readExisting(); err != nil {
@@ -91,11 +164,29 @@ func (w *Watcher) Start(ctx context.Context) error {
 		windowStart = time.Time{}
 	}
 
+	pending := newDebouncer(debounceWindow, debounceMaxDelay)
+	flush := time.NewTimer(time.Hour)
+	flush.Stop()
+	// rearm points flush at the next pending read.
+	rearm := func() {
+		flush.Stop()
+		if at, ok := pending.next(); ok {
+			flush.Reset(time.Until(at))
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			close(w.events)
 			return nil
+		case <-flush.C:
+			for _, path := range pending.due(time.Now()) {
+				if err := w.read(path); err != nil {
+					log.Printf("watcher read warning for %s: %v", path, err)
+				}
+			}
+			rearm()
 		case <-reconcileTicker.C:
 			if err := w.readExisting(); err != nil {
 				log.Printf("watcher reconciliation warning: %v", err)
@@ -132,16 +223,16 @@ func (w *Watcher) Start(ctx context.Context) error {
 				}
 			}
 			if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
-				if err := w.readNew(event.Name); err != nil {
-					processedOK = false
-					log.Printf("watcher read warning for %s: %v", event.Name, err)
-				}
+				pending.add(event.Name, time.Now())
 			}
 			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				pending.drop(event.Name)
 				w.mu.Lock()
 				delete(w.offsets, event.Name)
+				delete(w.parseFailures, event.Name)
 				w.mu.Unlock()
 			}
+			rearm()
 			if processedOK {
 				resetErrors()
 			}
@@ -172,12 +263,15 @@ func (w *Watcher) SetOffsets(offsets map[string]int64) {
 }
 
 func (w *Watcher) readExisting() error {
-	pattern := filepath.Join(w.inboxDir, "*", "*.msg")
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		return err
+	var files []string
+	for _, root := range w.roots {
+		matches, err := filepath.Glob(filepath.Join(root, "*", "*.msg"))
+		if err != nil {
+			return err
+		}
+		files = append(files, matches...)
 	}
-	for _, path := range files {
+	for _, path := range sortByMessageTime(files) {
 		if err := w.readNew(path); err != nil {
 			return err
 		}
@@ -185,6 +279,39 @@ func (w *Watcher) readExisting() error {
 	return nil
 }
 
+// sortByMessageTime orders pending message files by their envelope TS
+// header, falling back to file mtime, so a backlog is delivered in the
+// order it was written rather than in lexical filename order.
+func sortByMessageTime(files []string) []string {
+	type pending struct {
+		path string
+		at   time.Time
+	}
+	items := make([]pending, 0, len(files))
+	for _, path := range files {
+		item := pending{path: path}
+		if data, err := os.ReadFile(path); err == nil {
+			if ts, ok := PeekTimestamp(data); ok {
+				item.at = ts
+			}
+		}
+		if item.at.IsZero() {
+			if info, err := os.Stat(path); err == nil {
+				item.at = info.ModTime()
+			}
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].at.Before(items[j].at)
+	})
+	sorted := make([]string, len(items))
+	for i, item := range items {
+		sorted[i] = item.path
+	}
+	return sorted
+}
+
 func (w *Watcher) readNew(path string) error {
 	info, err := os.Stat(path)
 	if err != nil {
@@ -203,8 +330,13 @@ func (w *Watcher) readNew(path string) error {
 
 	w.mu.Lock()
 	offset := w.offsets[path]
+	hashing := w.hashes != nil
 	w.mu.Unlock()
 
+	if hashing {
+		// Content hashes decide below; offsets are bookkeeping only.
+		offset = 0
+	}
 	if offset > info.Size() {
 		w.mu.Lock()
 		w.offsets[path] = 0
@@ -233,10 +365,20 @@ func (w *Watcher) readNew(path string) error {
 	if len(data) == 0 {
 		return nil
 	}
+	var hash string
+	if hashing {
+		hash = contentHash(data)
+		w.mu.Lock()
+		prev, seen := w.hashes[path]
+		w.mu.Unlock()
+		if seen && prev.Hash == hash {
+			return nil
+		}
+	}
 
 	env, err := ParseMessageWithDefaults(data, defaults)
 	if err != nil {
-		log.Printf("outbox parse error %s: %v (skipping)", path, err)
+		w.reportParseError(path, agent, data, err)
 		return nil
 	}
 	sent := false
@@ -251,6 +393,9 @@ func (w *Watcher) readNew(path string) error {
 
 	w.mu.Lock()
 	w.offsets[path] = info.Size()
+	if hashing && sent {
+		w.recordHashLocked(path, hash, time.Now())
+	}
 	w.mu.Unlock()
 
 	if sent {
@@ -262,18 +407,60 @@ func (w *Watcher) readNew(path string) error {
 	return nil
 }
 
+// parseErrorSnippetBytes bounds the message excerpt in a parse error event.
+const parseErrorSnippetBytes = 200
+
+// reportParseError logs a malformed outbox file and, with an event log
+// set, records an inbox_parse_error event. Unchanged content that was
+// already reported is skipped, since the file stays put and may be read
+// again on every reconciliation.
+func (w *Watcher) reportParseError(path, agent string, data []byte, parseErr error) {
+	hash := contentHash(data)
+	w.mu.Lock()
+	if w.parseFailures[path] == hash {
+		w.mu.Unlock()
+		return
+	}
+	w.parseFailures[path] = hash
+	events := w.eventLog
+	w.mu.Unlock()
+
+	log.Printf("outbox parse error %s: %v (skipping)", path, parseErr)
+	if events == nil {
+		return
+	}
+	detail := fmt.Sprintf("%s: %v; content: %q", path, parseErr, snippet(data, parseErrorSnippetBytes))
+	if err := events.Log(logpkg.NewEvent(logpkg.EventTypeInboxParseError, agent, "").WithError(detail)); err != nil {
+		log.Printf("event log warning: %v", err)
+	}
+}
+
+// snippet returns at most limit bytes of data, cut on a rune boundary.
+func snippet(data []byte, limit int) string {
+	if len(data) <= limit {
+		return string(data)
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(data[cut]) {
+		cut--
+	}
+	return string(data[:cut]) + "..."
+}
+
 func agentFromPath(path string) string {
 	dir := filepath.Base(filepath.Dir(path))
 	return strings.ToLower(dir)
 }
 
 func (w *Watcher) isValidAgent(agent string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	_, ok := w.valid[agent]
 	return ok
 }
 
-func (w *Watcher) watchSubdirs() error {
-	entries, err := os.ReadDir(w.inboxDir)
+func (w *Watcher) watchSubdirs(root string) error {
+	entries, err := os.ReadDir(root)
 	if err != nil {
 		return err
 	}
@@ -281,7 +468,7 @@ func (w *Watcher) watchSubdirs() error {
 		if !entry.IsDir() {
 			continue
 		}
-		if err := w.watcher.Add(filepath.Join(w.inboxDir, entry.Name())); err != nil {
+		if err := w.watcher.Add(filepath.Join(root, entry.Name())); err != nil {
 			return err
 		}
 	}
@@ -301,3 +488,14 @@ func (w *Watcher) addWatchIfDir(path string) error {
 	}
 	return w.watcher.Add(path)
 }
+
+// Offsets returns a copy of the current offsets map.
+func (w *Watcher) Offsets() map[string]int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make(map[string]int64, len(w.offsets))
+	for path, offset := range w.offsets {
+		out[path] = offset
+	}
+	return out
+}
@@ -0,0 +1,319 @@
+package inbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logpkg "github.com/norm/relay-daemon/internal/log"
+)
+
+func newTestWatcher(t *testing.T) (*Watcher, string) {
+	t.Helper()
+	dir := t.TempDir()
+	w, err := NewWatcher(dir)
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	return w, dir
+}
+
+func writeMsg(t *testing.T, dir, agent, name, content string) string {
+	t.Helper()
+	agentDir := filepath.Join(dir, agent)
+	if err := os.MkdirAll(agentDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(agentDir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadExistingOrdersByTimestamp(t *testing.T) {
+	w, dir := newTestWatcher(t)
+	writeMsg(t, dir, "cc", "a.msg", "TO: oc\nMSG_ID: msg-third\nTS: 2026-01-01T10:05:00Z\n---\nthird")
+	writeMsg(t, dir, "cc", "b.msg", "TO: oc\nMSG_ID: msg-first\nTS: 2026-01-01T10:00:00Z\n---\nfirst")
+	writeMsg(t, dir, "oc", "0.msg", "TO: cc\nMSG_ID: msg-second\nTS: 2026-01-01T10:02:00Z\n---\nsecond")
+
+	if err := w.readExisting(); err != nil {
+		t.Fatalf("readExisting: %v", err)
+	}
+
+	want := []string{"msg-first", "msg-second", "msg-third"}
+	for _, id := range want {
+		select {
+		case env := <-w.Events():
+			if env.MsgID != id {
+				t.Fatalf("expected %s, got %s", id, env.MsgID)
+			}
+		default:
+			t.Fatalf("expected event %s, channel empty", id)
+		}
+	}
+}
+
+func TestPeekTimestamp(t *testing.T) {
+	if _, ok := PeekTimestamp([]byte("TO: oc\n---\nbody")); ok {
+		t.Fatal("expected no timestamp without TS header")
+	}
+	ts, ok := PeekTimestamp([]byte("TO: oc\nTS: 2026-01-01T10:00:00Z\n---\nbody"))
+	if !ok {
+		t.Fatal("expected timestamp")
+	}
+	if ts.Minute() != 0 || ts.Hour() != 10 {
+		t.Fatalf("unexpected timestamp %v", ts)
+	}
+}
+
+func TestContentHashingSkipsIdenticalRewrite(t *testing.T) {
+	w, dir := newTestWatcher(t)
+	w.EnableContentHashing(nil)
+	content := "TO: oc\nMSG_ID: msg-1\n---\nhello"
+
+	path := writeMsg(t, dir, "cc", "note.msg", content)
+	if err := w.readNew(path); err != nil {
+		t.Fatalf("readNew: %v", err)
+	}
+	if env := <-w.Events(); env.MsgID != "msg-1" {
+		t.Fatalf("first delivery = %s", env.MsgID)
+	}
+
+	// Same name, same content: not delivered again.
+	writeMsg(t, dir, "cc", "note.msg", content)
+	if err := w.readNew(path); err != nil {
+		t.Fatalf("readNew: %v", err)
+	}
+	select {
+	case env := <-w.Events():
+		t.Fatalf("identical rewrite re-delivered %s", env.MsgID)
+	default:
+	}
+
+	// Same name and size, new content: delivered fresh.
+	writeMsg(t, dir, "cc", "note.msg", "TO: oc\nMSG_ID: msg-2\n---\nhello")
+	if err := w.readNew(path); err != nil {
+		t.Fatalf("readNew: %v", err)
+	}
+	select {
+	case env := <-w.Events():
+		if env.MsgID != "msg-2" {
+			t.Fatalf("rewrite delivered %s, want msg-2", env.MsgID)
+		}
+	default:
+		t.Fatal("rewrite with new content was not delivered")
+	}
+}
+
+func TestSaveAndLoadHashes(t *testing.T) {
+	w, dir := newTestWatcher(t)
+	w.EnableContentHashing(nil)
+	path := writeMsg(t, dir, "cc", "a.msg", "TO: oc\n---\nhi")
+	if err := w.readNew(path); err != nil {
+		t.Fatalf("readNew: %v", err)
+	}
+	<-w.Events()
+
+	sidecar := filepath.Join(t.TempDir(), "offsets.hashes.json")
+	if err := w.SaveHashes(sidecar); err != nil {
+		t.Fatalf("SaveHashes: %v", err)
+	}
+	hashes, err := LoadHashes(sidecar)
+	if err != nil {
+		t.Fatalf("LoadHashes: %v", err)
+	}
+	if h, ok := hashes[path]; !ok || h.Hash == "" {
+		t.Fatalf("hashes = %v, want entry for %s", hashes, path)
+	}
+}
+
+func TestDeliveryPrunesExpiredHashes(t *testing.T) {
+	w, dir := newTestWatcher(t)
+	stale := filepath.Join(dir, "cc", "old.msg")
+	w.EnableContentHashing(map[string]DeliveredHash{
+		stale: {Hash: "abc", At: time.Now().Add(-hashRetention - time.Minute)},
+	})
+	path := writeMsg(t, dir, "cc", "new.msg", "TO: oc\n---\nhi")
+	if err := w.readNew(path); err != nil {
+		t.Fatalf("readNew: %v", err)
+	}
+	<-w.Events()
+
+	hashes := w.Hashes()
+	if _, ok := hashes[stale]; ok {
+		t.Fatalf("expired hash kept: %v", hashes)
+	}
+	if _, ok := hashes[path]; !ok {
+		t.Fatalf("hashes = %v, want entry for %s", hashes, path)
+	}
+}
+
+func TestMultiRootWatcherReadsEveryRoot(t *testing.T) {
+	podA, podB := t.TempDir(), t.TempDir()
+	// The same root spelled twice is watched once.
+	w, err := NewMultiRootWatcher([]string{podA, podB, podA + "/"})
+	if err != nil {
+		t.Fatalf("NewMultiRootWatcher: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	if len(w.roots) != 2 {
+		t.Fatalf("roots = %v, want 2", w.roots)
+	}
+
+	writeMsg(t, podA, "cc", "a.msg", "TO: oc\nMSG_ID: msg-pod-a\n---\nfrom pod a")
+	writeMsg(t, podB, "cx", "b.msg", "TO: oc\nMSG_ID: msg-pod-b\n---\nfrom pod b")
+	if err := w.readExisting(); err != nil {
+		t.Fatalf("readExisting: %v", err)
+	}
+
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case env := <-w.Events():
+			got[env.MsgID] = true
+		default:
+			t.Fatalf("expected 2 events, got %v", got)
+		}
+	}
+	if !got["msg-pod-a"] || !got["msg-pod-b"] {
+		t.Fatalf("events = %v", got)
+	}
+}
+
+func TestDebouncerCoalescesAndBoundsDelay(t *testing.T) {
+	d := newDebouncer(50*time.Millisecond, 200*time.Millisecond)
+	start := time.Unix(0, 0)
+	for i := 0; i < 10; i++ {
+		d.add("a.msg", start.Add(time.Duration(i)*10*time.Millisecond))
+	}
+	if got := d.due(start.Add(130 * time.Millisecond)); len(got) != 0 {
+		t.Fatalf("due before quiet period = %v", got)
+	}
+	if got := d.due(start.Add(140 * time.Millisecond)); len(got) != 1 || got[0] != "a.msg" {
+		t.Fatalf("due after quiet period = %v", got)
+	}
+
+	// A file that never goes quiet is still read within maxDelay.
+	for i := 0; i < 30; i++ {
+		d.add("b.msg", start.Add(time.Duration(i)*20*time.Millisecond))
+	}
+	if at, ok := d.next(); !ok || !at.Equal(start.Add(200*time.Millisecond)) {
+		t.Fatalf("next = %v, %v", at, ok)
+	}
+}
+
+func TestWatcherCoalescesRapidWrites(t *testing.T) {
+	w, dir := newTestWatcher(t)
+	agentDir := filepath.Join(dir, "cc")
+	if err := os.MkdirAll(agentDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var reads atomic.Int32
+	w.read = func(path string) error {
+		reads.Add(1)
+		return w.readNew(path)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = w.Start(ctx) }()
+	time.Sleep(50 * time.Millisecond) // let Start add its watches
+
+	// One envelope written in ten appends.
+	file, err := os.OpenFile(filepath.Join(agentDir, "burst.msg"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := []string{"TO: oc\n", "MSG_ID: msg-burst\n", "---\n"}
+	for i := 0; i < 7; i++ {
+		parts = append(parts, "line\n")
+	}
+	for _, part := range parts {
+		if _, err := file.WriteString(part); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file.Close()
+
+	select {
+	case env := <-w.Events():
+		if env.MsgID != "msg-burst" || strings.Count(env.Payload, "line") != 7 {
+			t.Fatalf("envelope = %s %q", env.MsgID, env.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("burst was not delivered")
+	}
+	if n := reads.Load(); n > 3 {
+		t.Fatalf("%d reads for 10 writes, want coalescing", n)
+	}
+}
+
+func TestSetValidAgentsAcceptsCustomAgent(t *testing.T) {
+	w, dir := newTestWatcher(t)
+	custom := writeMsg(t, dir, "reviewer", "a.msg", "TO: oc\nMSG_ID: msg-review\n---\nlooks good")
+
+	if err := w.readNew(custom); err != nil {
+		t.Fatalf("readNew: %v", err)
+	}
+	select {
+	case env := <-w.Events():
+		t.Fatalf("unknown agent delivered %s", env.MsgID)
+	default:
+	}
+
+	w.SetValidAgents([]string{"oc", "Reviewer"})
+	if err := w.readNew(custom); err != nil {
+		t.Fatalf("readNew: %v", err)
+	}
+	select {
+	case env := <-w.Events():
+		if env.MsgID != "msg-review" || env.From != "reviewer" {
+			t.Fatalf("envelope = %s from %s", env.MsgID, env.From)
+		}
+	default:
+		t.Fatal("custom agent envelope was not delivered")
+	}
+
+	// The custom list replaces the defaults.
+	if w.isValidAgent("cx") {
+		t.Fatal("cx still valid after SetValidAgents without it")
+	}
+}
+
+func TestParseErrorRecordedAsEvent(t *testing.T) {
+	w, dir := newTestWatcher(t)
+	logDir := t.TempDir()
+	w.SetEventLog(logpkg.NewEventLog(logDir))
+	path := writeMsg(t, dir, "cx", "bad.msg", "no headers here, just "+strings.Repeat("noise ", 100))
+
+	for i := 0; i < 2; i++ {
+		if err := w.readNew(path); err != nil {
+			t.Fatalf("readNew: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(logDir, "events.jsonl"))
+	if err != nil {
+		t.Fatalf("read events: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d events, want 1 for unchanged content:\n%s", len(lines), data)
+	}
+	var event logpkg.Event
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if event.Type != logpkg.EventTypeInboxParseError || event.From != "cx" {
+		t.Fatalf("event = %+v", event)
+	}
+	if !strings.Contains(event.Error, path) || !strings.Contains(event.Error, "no headers here") || len(event.Error) > 600 {
+		t.Fatalf("event error = %q", event.Error)
+	}
+}
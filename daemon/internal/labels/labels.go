@@ -41,6 +41,7 @@ const (
 	KeyStatus       = "status"        // Status: draft, active, completed, abandoned, pending, in_progress, done, blocked
 	KeyThread       = "thread"        // Thread grouping
 	KeyAssignee     = "assignee"      // Assigned agent role
+	KeyBlockedBy    = "blocked_by"    // Tasklet dependency, one label per blocker
 	KeyTrigger      = "trigger"       // Trigger event: milestone_complete
 
 	// Timestamp labels
@@ -165,6 +166,8 @@ func NormalizeKey(key string) string {
 		return KeyTaskletID
 	case "milestonenum", "milestone-num":
 		return KeyMilestoneNum
+	case "blockedby", "blocked-by":
+		return KeyBlockedBy
 	case "chunknum", "chunk-num":
 		return KeyChunkNum
 	case "chunkindex", "chunk-index":
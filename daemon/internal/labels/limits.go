@@ -0,0 +1,100 @@
+package labels
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// canonicalKeys are the RFC-002 keys; anything else counts as a custom
+// label against Limits.MaxCustom.
+var canonicalKeys = map[string]bool{
+	KeyRole: true, KeyRepo: true, KeyChkID: true, KeyWriter: true,
+	KeySource: true, KeyConfidence: true,
+	KeyChunkNum: true, KeyChunkIndex: true, KeyStartOffset: true, KeyEndOffset: true,
+	KeyOverlapStart: true, KeyChunkRange: true, KeySessionLogPath: true,
+	KeyRollupNum: true, KeyChunksIncluded: true, KeyTotalChunks: true,
+	KeyPlanID: true, KeyMilestoneID: true, KeyMilestoneNum: true, KeyTaskletID: true,
+	KeyStatus: true, KeyThread: true, KeyAssignee: true, KeyTrigger: true,
+	KeyCreatedAt: true, KeyTimestamp: true, KeyByteOffset: true,
+}
+
+// Limits bounds the labels written on a single bead.
+type Limits struct {
+	MaxCustom   int // custom (non-canonical) labels kept; 0 = unlimited
+	MaxValueLen int // bytes per value before truncation; 0 = unlimited
+}
+
+// DefaultLimits returns the caps applied to bead writes.
+func DefaultLimits() Limits {
+	return Limits{MaxCustom: 16, MaxValueLen: 128}
+}
+
+// Report lists the labels Enforce changed.
+type Report struct {
+	Invalid   []string // dropped: malformed key
+	Dropped   []string // dropped: over MaxCustom
+	Truncated []string // keys whose values were shortened
+}
+
+// Exceeded reports whether any label was dropped or truncated.
+func (r Report) Exceeded() bool {
+	return len(r.Invalid)+len(r.Dropped)+len(r.Truncated) > 0
+}
+
+func (r Report) String() string {
+	var parts []string
+	if len(r.Invalid) > 0 {
+		parts = append(parts, fmt.Sprintf("invalid=%s", strings.Join(r.Invalid, ",")))
+	}
+	if len(r.Dropped) > 0 {
+		parts = append(parts, fmt.Sprintf("dropped=%s", strings.Join(r.Dropped, ",")))
+	}
+	if len(r.Truncated) > 0 {
+		parts = append(parts, fmt.Sprintf("truncated=%s", strings.Join(r.Truncated, ",")))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Enforce applies lim to the set in place. Labels with keys that fail
+// ValidateKey are dropped, custom labels past MaxCustom are dropped in
+// insertion order, and long values are cut on a rune boundary. Canonical
+// labels are never dropped.
+func (ls *LabelSet) Enforce(lim Limits) Report {
+	var report Report
+	kept := ls.labels[:0]
+	custom := 0
+	for _, label := range ls.labels {
+		key, value, err := Parse(label)
+		if err == nil {
+			err = ValidateKey(key)
+		}
+		if err != nil {
+			report.Invalid = append(report.Invalid, label)
+			continue
+		}
+		if !canonicalKeys[key] {
+			if lim.MaxCustom > 0 && custom >= lim.MaxCustom {
+				report.Dropped = append(report.Dropped, key)
+				continue
+			}
+			custom++
+		}
+		if lim.MaxValueLen > 0 && len(value) > lim.MaxValueLen {
+			value = truncateValue(value, lim.MaxValueLen)
+			report.Truncated = append(report.Truncated, key)
+			label = Format(key, value)
+		}
+		kept = append(kept, label)
+	}
+	ls.labels = kept
+	return report
+}
+
+func truncateValue(value string, max int) string {
+	cut := max
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut]
+}
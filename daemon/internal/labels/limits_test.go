@@ -0,0 +1,46 @@
+package labels
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEnforceLimits(t *testing.T) {
+	ls := NewLabelSet().Add(KeyRole, "cc").Add(KeyRepo, strings.Repeat("r", 40))
+	for i := 0; i < 10; i++ {
+		ls.Add(fmt.Sprintf("custom_%d", i), "v")
+	}
+	ls.Add("Bad-Key", "x").Add("note", "héllo wörld")
+
+	report := ls.Enforce(Limits{MaxCustom: 3, MaxValueLen: 8})
+	if !report.Exceeded() {
+		t.Fatal("expected limits to be exceeded")
+	}
+
+	want := []string{"role:cc", "repo:rrrrrrrr", "custom_0:v", "custom_1:v", "custom_2:v"}
+	got := ls.Strings()
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("labels = %v, want %v", got, want)
+	}
+	if len(report.Dropped) != 8 || report.Dropped[0] != "custom_3" || report.Dropped[7] != "note" {
+		t.Fatalf("dropped = %v", report.Dropped)
+	}
+	if len(report.Invalid) != 1 || report.Invalid[0] != "Bad-Key:x" {
+		t.Fatalf("invalid = %v", report.Invalid)
+	}
+	if len(report.Truncated) != 1 || report.Truncated[0] != KeyRepo {
+		t.Fatalf("truncated = %v", report.Truncated)
+	}
+}
+
+func TestEnforceTruncatesOnRuneBoundary(t *testing.T) {
+	ls := NewLabelSet().Add("note", "héllo")
+	ls.Enforce(Limits{MaxValueLen: 2})
+	if got := ls.Strings()[0]; got != "note:h" {
+		t.Fatalf("label = %q, want note:h", got)
+	}
+	if report := NewLabelSet().Add(KeyRole, "cc").Enforce(DefaultLimits()); report.Exceeded() {
+		t.Fatalf("unexpected report: %s", report)
+	}
+}
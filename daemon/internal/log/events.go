@@ -78,6 +78,16 @@ const (
 	EventTypeInject            = "inject"
 	EventTypeBlocked           = "blocked"
 	EventTypePaneTailError     = "pane_tail_error"
+	EventTypeReceipt           = "receipt"
+	EventTypeDiskLow           = "disk_low"
+	EventTypeLogResumed        = "log_resumed"
+	EventTypePaneMapWarning    = "pane_map_warning"
+	EventTypeUnauthorized      = "unauthorized"
+	EventTypeLabelLimit        = "label_cardinality_exceeded"
+	EventTypeInboxParseError   = "inbox_parse_error"
+	EventTypeDuplicate         = "duplicate"
+	EventTypeGaveUp            = "gave_up"
+	EventTypeReplayed          = "replayed"
 )
 
 // GenerateEventID returns an evt- prefixed 8-hex identifier.
@@ -109,16 +119,42 @@ func NewEvent(eventType, from, to string) Event {
 type EventLog struct {
 	path string
 	mu   sync.Mutex
+
+	// While suspended (e.g. disk nearly full) events are counted, not written.
+	suspended bool
+	dropped   int
 }
 
 func NewEventLog(logDir string) *EventLog {
 	return &EventLog{path: filepath.Join(logDir, "events.jsonl")}
 }
 
+// SetSuspended stops or resumes appending. On resume a log_resumed event
+// records how many events were dropped in between.
+func (l *EventLog) SetSuspended(suspended bool) {
+	l.mu.Lock()
+	wasSuspended := l.suspended
+	dropped := l.dropped
+	l.suspended = suspended
+	if suspended {
+		l.dropped = 0
+	}
+	l.mu.Unlock()
+
+	if wasSuspended && !suspended {
+		_ = l.Log(NewEvent(EventTypeLogResumed, "relay", "").WithCount(dropped))
+	}
+}
+
 func (l *EventLog) Log(event Event) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	if l.suspended {
+		l.dropped++
+		return nil
+	}
+
 	// Apply RFC-002 defaults
 	if event.Version == 0 {
 		event.Version = EventVersion
@@ -87,3 +87,31 @@ func TestEventLogSchemaFields(t *testing.T) {
 		t.Fatalf("expected evt- prefixed event_id, got %v", got["event_id"])
 	}
 }
+
+func TestEventLogSuspended(t *testing.T) {
+	dir := t.TempDir()
+	logger := NewEventLog(dir)
+
+	logger.SetSuspended(true)
+	for i := 0; i < 3; i++ {
+		if err := logger.Log(NewEvent(EventTypeInject, "oc", "cc")); err != nil {
+			t.Fatalf("log while suspended: %v", err)
+		}
+	}
+	if _, err := os.Stat(dir + "/events.jsonl"); !os.IsNotExist(err) {
+		t.Fatalf("events written while suspended (stat err %v)", err)
+	}
+
+	logger.SetSuspended(false)
+	payload, err := os.ReadFile(dir + "/events.jsonl")
+	if err != nil {
+		t.Fatalf("read events.jsonl: %v", err)
+	}
+	var got Event
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(payload))), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.Type != EventTypeLogResumed || got.Count != 3 {
+		t.Fatalf("resume event = %+v, want log_resumed with count 3", got)
+	}
+}
@@ -0,0 +1,95 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// EventFilter selects events by field. Empty fields and a zero Since match
+// everything.
+type EventFilter struct {
+	Type  string
+	From  string
+	To    string
+	ChkID string
+	Since time.Time // events at or after this time
+}
+
+// Match reports whether evt passes every set field of f.
+func (f EventFilter) Match(evt Event) bool {
+	switch {
+	case f.Type != "" && evt.Type != f.Type,
+		f.From != "" && evt.From != f.From,
+		f.To != "" && evt.To != f.To,
+		f.ChkID != "" && evt.ChkID != f.ChkID,
+		!f.Since.IsZero() && evt.TimestampMs < f.Since.UnixMilli():
+		return false
+	}
+	return true
+}
+
+// EventReader streams events from a JSONL event log one line at a time.
+// Lines that are not valid events are skipped. Iterate it like a
+// bufio.Scanner:
+//
+//	r.Filter(EventFilter{Type: EventTypeInject})
+//	for r.Next() {
+//		evt := r.Event()
+//	}
+//	err := r.Err()
+type EventReader struct {
+	file    *os.File
+	scanner *bufio.Scanner
+	filter  EventFilter
+	event   Event
+}
+
+// OpenEventLog opens the event log at path for reading.
+func OpenEventLog(path string) (*EventReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	return &EventReader{file: file, scanner: scanner}, nil
+}
+
+// Filter restricts the events Next yields to those matching f.
+func (r *EventReader) Filter(f EventFilter) *EventReader {
+	r.filter = f
+	return r
+}
+
+// Next advances to the next matching event, returning false at the end of
+// the log or on a read error.
+func (r *EventReader) Next() bool {
+	for r.scanner.Scan() {
+		var evt Event
+		if err := json.Unmarshal(r.scanner.Bytes(), &evt); err != nil {
+			continue
+		}
+		if r.filter.Match(evt) {
+			r.event = evt
+			return true
+		}
+	}
+	return false
+}
+
+// Event returns the event found by the last call to Next.
+func (r *EventReader) Event() Event {
+	return r.event
+}
+
+// Err returns the first read error, if any.
+func (r *EventReader) Err() error {
+	return r.scanner.Err()
+}
+
+// Close closes the underlying file.
+func (r *EventReader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,88 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFixtureLog writes a small event log through EventLog plus one
+// malformed line, and returns its path.
+func writeFixtureLog(t *testing.T, base time.Time) string {
+	t.Helper()
+	dir := t.TempDir()
+	logger := NewEventLog(dir)
+	events := []Event{
+		NewEvent(EventTypeEnqueue, "oc", "cc").WithMsgID("msg-1"),
+		NewEvent(EventTypeInject, "oc", "cc").WithMsgID("msg-1"),
+		NewEvent(EventTypeMessageRouted, "admin", "cc").WithChkID("chk-a"),
+		NewEvent(EventTypeTimeout, "admin", "cc").WithChkID("chk-a"),
+		NewEvent(EventTypeMessageRouted, "admin", "cx").WithChkID("chk-b"),
+		NewEvent(EventTypeInject, "cc", "cx").WithMsgID("msg-2"),
+	}
+	for n, evt := range events {
+		evt.TimestampMs = base.Add(time.Duration(n) * time.Minute).UnixMilli()
+		if err := logger.Log(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "events.jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{truncated\n")
+	f.Close()
+	return path
+}
+
+func readFiltered(t *testing.T, path string, filter EventFilter) []string {
+	t.Helper()
+	r, err := OpenEventLog(path)
+	if err != nil {
+		t.Fatalf("OpenEventLog: %v", err)
+	}
+	defer r.Close()
+	var got []string
+	for r.Filter(filter); r.Next(); {
+		evt := r.Event()
+		got = append(got, evt.Type+":"+evt.To)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return got
+}
+
+func TestEventReaderFilter(t *testing.T) {
+	base := time.Now().Add(-time.Hour)
+	path := writeFixtureLog(t, base)
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   string
+	}{
+		{"all", EventFilter{}, "enqueue:cc inject:cc message_routed:cc timeout:cc message_routed:cx inject:cx"},
+		{"type", EventFilter{Type: EventTypeInject}, "inject:cc inject:cx"},
+		{"chk_id", EventFilter{ChkID: "chk-a"}, "message_routed:cc timeout:cc"},
+		{"from and to", EventFilter{From: "admin", To: "cx"}, "message_routed:cx"},
+		{"since", EventFilter{Since: base.Add(4 * time.Minute)}, "message_routed:cx inject:cx"},
+		{"no match", EventFilter{Type: EventTypeInject, ChkID: "chk-a"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(readFiltered(t, path, tt.filter), " "); got != tt.want {
+				t.Fatalf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenEventLogMissing(t *testing.T) {
+	if _, err := OpenEventLog(filepath.Join(t.TempDir(), "events.jsonl")); !os.IsNotExist(err) {
+		t.Fatalf("err = %v, want not-exist", err)
+	}
+}
@@ -67,6 +67,27 @@ type State struct {
 	IdentityVerified *bool   `json:"identity_verified,omitempty"`
 }
 
+// DefaultPromptPrefixes mark a ready shell-style prompt: the Claude, Codex
+// and Claude permission-bar glyphs, an inquirer question, and the usual
+// POSIX, zsh and generic shell prompts.
+var DefaultPromptPrefixes = []string{"❯", "›", "⏵", "?", "$", "%", ">"}
+
+// HasPromptPrefix reports whether the last non-empty line of capturedText
+// starts with one of prefixes. A nil or empty list uses
+// DefaultPromptPrefixes.
+func HasPromptPrefix(capturedText string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		prefixes = DefaultPromptPrefixes
+	}
+	last := lastNonEmptyLine(capturedText)
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(last, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // ParsePaneState parses pane capture text into normalized pane state.
 func ParsePaneState(target, capturedText string) State {
 	role := strings.ToLower(strings.TrimSpace(target))
@@ -0,0 +1,134 @@
+// Package panehistory keeps an append-only audit trail of pane map changes
+// so routing changes can be reconstructed during incident review.
+package panehistory
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileName is the JSONL file written under the state directory.
+const FileName = "pane-map-history.jsonl"
+
+// Sources of a pane map change.
+const (
+	SourceBootstrap = "bootstrap"      // loaded at daemon startup
+	SourceDefaults  = "defaults"       // startup fell back to built-in panes
+	SourceReload    = "reload"         // panes.json edited on disk
+	SourceRegister  = "register-panes" // panes.json re-registered (new registered_at)
+)
+
+// Change is one role whose pane differs between two maps. Old or New is
+// empty when the role was added or removed.
+type Change struct {
+	Role string `json:"role"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// Entry is one recorded pane map change.
+type Entry struct {
+	At      time.Time `json:"at"`
+	Source  string    `json:"source"`
+	Changes []Change  `json:"changes"`
+}
+
+// Diff returns the per-role changes from old to new, sorted by role.
+func Diff(old, new map[string]string) []Change {
+	roles := make(map[string]struct{}, len(old)+len(new))
+	for role := range old {
+		roles[role] = struct{}{}
+	}
+	for role := range new {
+		roles[role] = struct{}{}
+	}
+	var changes []Change
+	for role := range roles {
+		if old[role] != new[role] {
+			changes = append(changes, Change{Role: role, Old: old[role], New: new[role]})
+		}
+	}
+	sort.Slice(changes, func(a, b int) bool { return changes[a].Role < changes[b].Role })
+	return changes
+}
+
+// Recorder appends Entries to <dir>/pane-map-history.jsonl.
+type Recorder struct {
+	path string
+	mu   sync.Mutex
+	now  func() time.Time
+}
+
+// New returns a Recorder for dir.
+func New(dir string) *Recorder {
+	return &Recorder{path: filepath.Join(dir, FileName), now: time.Now}
+}
+
+// Path returns the JSONL file path.
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Record appends the diff from old to new. Nothing is written when the maps
+// are identical; the returned bool reports whether an entry was recorded.
+func (r *Recorder) Record(source string, old, new map[string]string) (Entry, bool, error) {
+	changes := Diff(old, new)
+	if len(changes) == 0 {
+		return Entry{}, false, nil
+	}
+	entry := Entry{At: r.now().UTC(), Source: source, Changes: changes}
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return Entry{}, false, err
+	}
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(payload, '\n')); err != nil {
+		return Entry{}, false, err
+	}
+	return entry, true, nil
+}
+
+// Recent returns up to n of the latest entries, oldest first. Unreadable
+// lines are skipped; a missing file yields no entries.
+func (r *Recorder) Recent(n int) ([]Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	file, err := os.Open(r.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+		if n > 0 && len(entries) > n {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,48 @@
+package panehistory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecordTwoUpdates(t *testing.T) {
+	r := New(t.TempDir())
+
+	boot := map[string]string{"oc": "%0", "cc": "%1", "cx": "%2"}
+	if _, ok, err := r.Record(SourceBootstrap, nil, boot); err != nil || !ok {
+		t.Fatalf("bootstrap record = %v, %v", ok, err)
+	}
+
+	moved := map[string]string{"oc": "%0", "cc": "%5", "vog": "%4"}
+	if _, ok, err := r.Record(SourceReload, boot, moved); err != nil || !ok {
+		t.Fatalf("reload record = %v, %v", ok, err)
+	}
+
+	if _, ok, err := r.Record(SourceReload, moved, moved); err != nil || ok {
+		t.Fatalf("unchanged map recorded: %v, %v", ok, err)
+	}
+
+	entries, err := r.Recent(10)
+	if err != nil {
+		t.Fatalf("Recent: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Source != SourceBootstrap || len(entries[0].Changes) != 3 {
+		t.Fatalf("bootstrap entry = %+v", entries[0])
+	}
+	want := []Change{
+		{Role: "cc", Old: "%1", New: "%5"},
+		{Role: "cx", Old: "%2"},
+		{Role: "vog", New: "%4"},
+	}
+	if entries[1].Source != SourceReload || !reflect.DeepEqual(entries[1].Changes, want) {
+		t.Fatalf("reload entry = %+v, want changes %+v", entries[1], want)
+	}
+
+	last, err := r.Recent(1)
+	if err != nil || len(last) != 1 || last[0].Source != SourceReload {
+		t.Fatalf("Recent(1) = %+v, %v", last, err)
+	}
+}
@@ -0,0 +1,106 @@
+// Package redact masks secret-shaped substrings before content leaves the
+// daemon (Haiku summarization input, bead bodies).
+package redact
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Placeholder replaces every redacted match.
+const Placeholder = "[REDACTED]"
+
+// DefaultPatterns cover common credential shapes.
+var DefaultPatterns = []string{
+	`AKIA[0-9A-Z]{16}`,                       // AWS access key ID
+	`(?i)aws_secret_access_key\s*[:=]\s*\S+`, // AWS secret key assignment
+	`sk-ant-[A-Za-z0-9_\-]{20,}`,             // Anthropic API key
+	`sk-[A-Za-z0-9_\-]{20,}`,                 // OpenAI-style API key
+	`gh[pousr]_[A-Za-z0-9]{36,}`,             // GitHub token
+	`xox[abprs]-[A-Za-z0-9\-]{10,}`,          // Slack token
+	`(?i)bearer\s+[A-Za-z0-9\-._~+/]{16,}=*`, // HTTP bearer token
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`, // PEM private key
+}
+
+// Redactor replaces matches of its patterns with Placeholder.
+// A nil Redactor is valid and leaves content unchanged.
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// New compiles the given patterns.
+func New(patterns []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redact: invalid pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Default returns a Redactor using DefaultPatterns.
+func Default() *Redactor {
+	r, err := New(DefaultPatterns)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// FromEnv builds a Redactor from the environment. RELAY_REDACT=false disables
+// redaction entirely; RELAY_REDACT_PATTERNS_FILE names a file of extra
+// patterns, one regex per line (blank lines and # comments ignored).
+func FromEnv() (*Redactor, error) {
+	switch strings.ToLower(os.Getenv("RELAY_REDACT")) {
+	case "0", "false", "no", "off":
+		return nil, nil
+	}
+	patterns := append([]string{}, DefaultPatterns...)
+	if path := os.Getenv("RELAY_REDACT_PATTERNS_FILE"); path != "" {
+		extra, err := readPatternFile(path)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, extra...)
+	}
+	return New(patterns)
+}
+
+// Redact returns s with every pattern match replaced by Placeholder.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Placeholder)
+	}
+	return s
+}
+
+func readPatternFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("redact: %w", err)
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("redact: %w", err)
+	}
+	return patterns, nil
+}
@@ -0,0 +1,149 @@
+// Package replay finds messages the relay dropped or never delivered and
+// re-creates them in the inbox so they are sent again.
+//
+// The event log only records message IDs, so payloads come from the
+// dead-letter file, where the relay keeps every envelope it drops. A
+// message whose envelope was not dead-lettered, such as one still queued
+// when the daemon stopped, is reported but cannot be replayed.
+package replay
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/norm/relay-daemon/internal/deadletter"
+	"github.com/norm/relay-daemon/internal/inbox"
+	logpkg "github.com/norm/relay-daemon/internal/log"
+	"github.com/norm/relay-daemon/internal/tmux"
+	"github.com/norm/relay-daemon/pkg/envelope"
+)
+
+// Candidate is a message whose last recorded outcome was not a delivery.
+type Candidate struct {
+	MsgID  string
+	Target string
+	// Event is the last event recorded for the message: drop, gave_up or
+	// blocked.
+	Event string
+	// Envelope is the dead-lettered envelope, or nil when the payload was
+	// not retained.
+	Envelope *envelope.Envelope
+	// Expired reports that the message was dropped once its own expires_at
+	// had passed, so its sender meant it to lapse.
+	Expired bool
+}
+
+// outcomes are the event types that settle or describe a delivery attempt.
+var outcomes = map[string]bool{
+	logpkg.EventTypeInject:   true,
+	"drop":                   true,
+	logpkg.EventTypeGaveUp:   true,
+	logpkg.EventTypeBlocked:  true,
+	logpkg.EventTypeReplayed: true,
+}
+
+// settled reports whether an outcome leaves nothing to replay: the message
+// was delivered, or a copy was already replayed under a new msg_id.
+func settled(eventType string) bool {
+	return eventType == logpkg.EventTypeInject || eventType == logpkg.EventTypeReplayed
+}
+
+// Find scans the event log at eventsPath for messages to a target whose
+// last outcome at or after since was a drop, give-up or block rather than
+// an inject or an earlier replay, in log order, and pairs each with its
+// envelope from the dead-letter file at deadLetterPath.
+func Find(eventsPath, deadLetterPath string, since time.Time) ([]Candidate, error) {
+	events, err := logpkg.OpenEventLog(eventsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer events.Close()
+
+	type key struct{ msgID, target string }
+	last := map[key]string{}
+	var order []key
+	for events.Filter(logpkg.EventFilter{Since: since}); events.Next(); {
+		evt := events.Event()
+		if !outcomes[evt.Type] || evt.MsgID == "" {
+			continue
+		}
+		k := key{evt.MsgID, evt.To}
+		if _, seen := last[k]; !seen {
+			order = append(order, k)
+		}
+		last[k] = evt.Type
+	}
+	if err := events.Err(); err != nil {
+		return nil, err
+	}
+
+	records, err := deadletter.Read(deadLetterPath)
+	if err != nil {
+		return nil, err
+	}
+	deadLettered := map[key]deadletter.Record{}
+	for _, rec := range records {
+		deadLettered[key{rec.Envelope.MsgID, rec.Envelope.To}] = rec
+	}
+
+	var out []Candidate
+	for _, k := range order {
+		if settled(last[k]) {
+			continue
+		}
+		rec, ok := deadLettered[k]
+		c := Candidate{MsgID: k.msgID, Target: k.target, Event: last[k]}
+		if ok {
+			c.Envelope = rec.Envelope
+			c.Expired = expiredOwnTTL(rec)
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// expiredOwnTTL reports whether rec was dropped as expired after its
+// envelope's expires_at, rather than for outliving the queue max age.
+func expiredOwnTTL(rec deadletter.Record) bool {
+	if rec.Reason != tmux.DropExpired {
+		return false
+	}
+	expiry, ok := rec.Envelope.Expiry()
+	return ok && !rec.At.Before(expiry)
+}
+
+// Record logs a replayed event for c, keyed on its original msg_id, so a
+// later Find does not offer the same message again.
+func Record(logger *logpkg.EventLog, c Candidate) error {
+	return logger.Log(logpkg.NewEvent(logpkg.EventTypeReplayed, c.Envelope.From, c.Target).WithMsgID(c.MsgID))
+}
+
+// Write re-creates env as a new message in its sender's outbox under
+// inboxDir and returns the file path. The copy gets a fresh msg_id and
+// timestamp and no expiry, so the injector neither treats it as a
+// duplicate nor drops it again for age; callers decide whether an Expired
+// candidate should be revived at all.
+func Write(inboxDir string, env *envelope.Envelope) (string, error) {
+	if env.From == "" {
+		return "", fmt.Errorf("replay %s: envelope has no sender", env.MsgID)
+	}
+	replayed := *env
+	replayed.MsgID = envelope.GenerateMsgID()
+	replayed.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	replayed.ExpiresAt = ""
+
+	dir := filepath.Join(inboxDir, env.From)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "replay-"+replayed.MsgID+".msg")
+	// The watcher only reads .msg files, so the rename publishes the
+	// message whole.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, inbox.FormatMessage(&replayed), 0o644); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp, path)
+}
@@ -0,0 +1,140 @@
+package replay
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/norm/relay-daemon/internal/deadletter"
+	"github.com/norm/relay-daemon/internal/inbox"
+	logpkg "github.com/norm/relay-daemon/internal/log"
+	"github.com/norm/relay-daemon/internal/tmux"
+	"github.com/norm/relay-daemon/pkg/envelope"
+)
+
+func TestFindAndWrite(t *testing.T) {
+	dir := t.TempDir()
+	var lines []string
+	event := func(typ, to, msgID string, at time.Time) {
+		evt := logpkg.NewEvent(typ, "oc", to).WithMsgID(msgID)
+		evt.TimestampMs = at.UnixMilli()
+		data, err := json.Marshal(evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(data))
+	}
+	now := time.Now()
+	since := now.Add(-time.Hour)
+	event(logpkg.EventTypeBlocked, "cc", "msg-delivered", now)
+	event(logpkg.EventTypeInject, "cc", "msg-delivered", now)
+	event(logpkg.EventTypeBlocked, "cc", "msg-gaveup", now)
+	event(logpkg.EventTypeGaveUp, "cc", "msg-gaveup", now)
+	event("drop", "cx", "msg-nopayload", now)
+	event(logpkg.EventTypeBlocked, "oc", "msg-pending", now)
+	event("drop", "cc", "msg-old", since.Add(-time.Minute))
+	// A broadcast delivered to cx but dropped for cc.
+	event("drop", "cc", "msg-all", now)
+	event(logpkg.EventTypeInject, "cx", "msg-all", now)
+	lines = append(lines, "not json")
+	eventsPath := filepath.Join(dir, "events.jsonl")
+	if err := os.WriteFile(eventsPath, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	deadLetters := deadletter.New(filepath.Join(dir, "dead-letter"))
+	gaveUp := envelope.NewEnvelope("oc", "cc", "command", "run the migration")
+	gaveUp.MsgID = "msg-gaveup"
+	gaveUp.ThreadID = "atk-9"
+	gaveUp.ExpiresAt = now.Add(-time.Minute).UTC().Format(time.RFC3339)
+	broadcast := envelope.NewEnvelope("oc", "cc", "chat", "standup")
+	broadcast.MsgID = "msg-all"
+	for _, env := range []*envelope.Envelope{gaveUp, broadcast} {
+		if err := deadLetters.Write(env, env.To, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	candidates, err := Find(eventsPath, deadLetters.Path(), since)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	var got []string
+	for _, c := range candidates {
+		got = append(got, c.MsgID+"@"+c.Target+":"+c.Event+":"+map[bool]string{true: "recoverable", false: "lost"}[c.Envelope != nil])
+	}
+	want := "msg-gaveup@cc:gave_up:recoverable msg-nopayload@cx:drop:lost msg-pending@oc:blocked:lost msg-all@cc:drop:recoverable"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("candidates = %s\nwant         %s", strings.Join(got, " "), want)
+	}
+
+	inboxDir := filepath.Join(dir, "outbox")
+	path, err := Write(inboxDir, candidates[0].Envelope)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if filepath.Dir(path) != filepath.Join(inboxDir, "oc") || filepath.Ext(path) != ".msg" {
+		t.Fatalf("replayed to %s", path)
+	}
+	envs, err := inbox.ParseFile(path)
+	if err != nil || len(envs) != 1 {
+		t.Fatalf("ParseFile = %v, %v", envs, err)
+	}
+	replayed := envs[0]
+	if replayed.To != "cc" || replayed.Kind != "command" || strings.TrimSpace(replayed.Payload) != "run the migration" || replayed.ThreadID != "atk-9" {
+		t.Fatalf("replayed = %+v", replayed)
+	}
+	if replayed.MsgID == gaveUp.MsgID || replayed.ExpiresAt != "" {
+		t.Fatalf("replay kept msg_id %s or expiry %q", replayed.MsgID, replayed.ExpiresAt)
+	}
+
+	// Once recorded, the original is not offered again.
+	if err := Record(logpkg.NewEventLog(dir), candidates[0]); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	again, err := Find(eventsPath, deadLetters.Path(), since)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	for _, c := range again {
+		if c.MsgID == gaveUp.MsgID {
+			t.Fatalf("replayed message offered again: %+v", c)
+		}
+	}
+	if len(again) != len(candidates)-1 {
+		t.Fatalf("candidates after replay = %d, want %d", len(again), len(candidates)-1)
+	}
+}
+
+func TestFindFlagsMessagesPastTheirOwnExpiry(t *testing.T) {
+	dir := t.TempDir()
+	logger := logpkg.NewEventLog(dir)
+	deadLetters := deadletter.New(filepath.Join(dir, "dead-letter"))
+
+	lapsed := envelope.NewEnvelope("oc", "cc", "chat", "only useful for a minute")
+	lapsed.ExpiresAt = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano)
+	// Dropped for outliving the queue max age; it had no expiry of its own.
+	aged := envelope.NewEnvelope("oc", "cc", "chat", "stuck behind a busy pane")
+	for _, env := range []*envelope.Envelope{lapsed, aged} {
+		if err := logger.Log(logpkg.NewEvent("drop", env.From, env.To).WithMsgID(env.MsgID)); err != nil {
+			t.Fatal(err)
+		}
+		if err := deadLetters.Write(env, env.To, tmux.DropExpired); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	candidates, err := Find(filepath.Join(dir, "events.jsonl"), deadLetters.Path(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("candidates = %+v", candidates)
+	}
+	if !candidates[0].Expired || candidates[1].Expired {
+		t.Fatalf("expired = %v, %v; want only the message with its own expiry", candidates[0].Expired, candidates[1].Expired)
+	}
+}
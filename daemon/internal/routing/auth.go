@@ -0,0 +1,77 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/norm/relay-daemon/pkg/envelope"
+)
+
+// Wildcard matches any sender, recipient, or kind in an AuthMatrix.
+const Wildcard = "*"
+
+// KindSlash is a pseudo-kind required, in addition to the envelope's own
+// kind, for payloads that would be injected as slash commands.
+const KindSlash = "slash"
+
+// AuthMatrix lists the message kinds each sender may deliver to each
+// recipient: from → to → kinds. Keys and kinds may be Wildcard. A nil
+// matrix allows everything.
+type AuthMatrix map[string]map[string][]string
+
+// LoadAuthMatrix reads a JSON AuthMatrix from path. An empty path or a
+// missing file yields nil (permissive).
+func LoadAuthMatrix(path string) (AuthMatrix, error) {
+	if path == "" {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var m AuthMatrix
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("decode auth matrix: %w", err)
+	}
+	return m, nil
+}
+
+// Authorize reports whether env may be delivered to target. The error
+// names the rule that was missing.
+func (m AuthMatrix) Authorize(env *envelope.Envelope, target string) error {
+	if m == nil {
+		return nil
+	}
+	kinds := []string{env.Kind}
+	if strings.HasPrefix(strings.TrimSpace(env.Payload), "/") {
+		kinds = append(kinds, KindSlash)
+	}
+	for _, kind := range kinds {
+		if !m.allows(env.From, target, kind) {
+			return fmt.Errorf("routing: %s may not send %s to %s", env.From, kind, target)
+		}
+	}
+	return nil
+}
+
+func (m AuthMatrix) allows(from, to, kind string) bool {
+	for _, f := range []string{from, Wildcard} {
+		byTo, ok := m[f]
+		if !ok {
+			continue
+		}
+		for _, t := range []string{to, Wildcard} {
+			for _, k := range byTo[t] {
+				if k == kind || k == Wildcard {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
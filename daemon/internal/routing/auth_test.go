@@ -0,0 +1,44 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/norm/relay-daemon/pkg/envelope"
+)
+
+func TestAuthMatrixCommandKind(t *testing.T) {
+	m := AuthMatrix{
+		"admin":  {Wildcard: {Wildcard}},
+		Wildcard: {Wildcard: {"chat", "ack"}},
+	}
+
+	if err := m.Authorize(envelope.NewEnvelope("cc", "cx", "command", "run tests"), "cx"); err == nil {
+		t.Fatal("cc command to cx should be unauthorized")
+	}
+	if err := m.Authorize(envelope.NewEnvelope("admin", "cx", "command", "run tests"), "cx"); err != nil {
+		t.Fatalf("admin command to cx: %v", err)
+	}
+	if err := m.Authorize(envelope.NewEnvelope("cc", "oc", "chat", "done"), "oc"); err != nil {
+		t.Fatalf("cc chat to oc: %v", err)
+	}
+}
+
+func TestAuthMatrixSlashPayload(t *testing.T) {
+	m := AuthMatrix{
+		"relay":  {Wildcard: {Wildcard}},
+		Wildcard: {Wildcard: {"chat"}},
+	}
+	if err := m.Authorize(envelope.NewEnvelope("oc", "cc", "chat", "/compact"), "cc"); err == nil {
+		t.Fatal("slash payload from oc should need the slash kind")
+	}
+	if err := m.Authorize(envelope.NewEnvelope("relay", "cc", "chat", "/compact"), "cc"); err != nil {
+		t.Fatalf("relay slash payload: %v", err)
+	}
+}
+
+func TestNilAuthMatrixIsPermissive(t *testing.T) {
+	var m AuthMatrix
+	if err := m.Authorize(envelope.NewEnvelope("cc", "admin", "command", "/x"), "admin"); err != nil {
+		t.Fatalf("nil matrix rejected: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+// Package routing resolves which pane targets an envelope is delivered to.
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/norm/relay-daemon/internal/config"
+)
+
+// ErrAdminRouteDisabled is returned when admin-destined messages are
+// disabled by the admin policy.
+var ErrAdminRouteDisabled = errors.New("routing: admin delivery disabled by policy")
+
+// broadcastRoles are the core agent roles.
+var broadcastRoles = []string{"oc", "cc", "cx"}
+
+// AuxiliaryRoles are optional agents such as vog that are always known,
+// even before the pane map names them. They receive messages (direct or
+// broadcast) only once it does. Other roles need no entry here: any role
+// in the pane map or RELAY_EXTRA_AGENTS is accepted.
+var AuxiliaryRoles = []string{"vog"}
+
+// KnownRoles returns every role the daemon accepts messages from and to.
+func KnownRoles() []string {
+	roles := append([]string{}, broadcastRoles...)
+	roles = append(roles, "admin")
+	return append(roles, AuxiliaryRoles...)
+}
+
+// UnmappedRoles returns the known roles that have no pane target. A role
+// registered with an empty pane (null in panes.json) counts as unmapped.
+func UnmappedRoles(paneTargets map[string]string) []string {
+	var missing []string
+	for _, role := range KnownRoles() {
+		if paneTargets[role] == "" {
+			missing = append(missing, role)
+		}
+	}
+	return missing
+}
+
+// UnmappedTargetError reports a message addressed to a role with no pane.
+type UnmappedTargetError struct {
+	Role string
+}
+
+func (e *UnmappedTargetError) Error() string {
+	return fmt.Sprintf("routing: %s has no pane target; add %q to the pane map to deliver to it", e.Role, e.Role)
+}
+
+// BroadcastTargets returns the targets of an "all" message: every role the
+// pane map gives a pane, except admin, plus admin when it has a pane and the
+// policy includes it. Known roles come first in KnownRoles order, then any
+// other roles sorted by name.
+func BroadcastTargets(paneTargets map[string]string, admin config.AdminPolicy) []string {
+	var targets []string
+	known := map[string]bool{}
+	for _, role := range KnownRoles() {
+		known[role] = true
+		if role != "admin" && paneTargets[role] != "" {
+			targets = append(targets, role)
+		}
+	}
+	var extra []string
+	for role, pane := range paneTargets {
+		if !known[role] && pane != "" {
+			extra = append(extra, role)
+		}
+	}
+	sort.Strings(extra)
+	targets = append(targets, extra...)
+	if paneTargets["admin"] != "" && admin.Broadcast {
+		targets = append(targets, "admin")
+	}
+	return targets
+}
+
+// Targets resolves the delivery targets for an envelope addressed to "to".
+// A direct recipient without a pane yields *UnmappedTargetError.
+func Targets(to string, paneTargets map[string]string, admin config.AdminPolicy) ([]string, error) {
+	switch to {
+	case "all":
+		return BroadcastTargets(paneTargets, admin), nil
+	case "admin":
+		if !admin.Direct {
+			return nil, ErrAdminRouteDisabled
+		}
+		return []string{"admin"}, nil
+	default:
+		if paneTargets[to] == "" {
+			return nil, &UnmappedTargetError{Role: to}
+		}
+		return []string{to}, nil
+	}
+}
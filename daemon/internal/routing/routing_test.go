@@ -0,0 +1,92 @@
+package routing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/norm/relay-daemon/internal/config"
+)
+
+var panesWithAdmin = map[string]string{"oc": "%0", "cc": "%1", "cx": "%2", "admin": "%3"}
+
+func TestBroadcastTargetsAdminPolicy(t *testing.T) {
+	policy := config.DefaultAdminPolicy()
+	got := BroadcastTargets(panesWithAdmin, policy)
+	if !reflect.DeepEqual(got, []string{"oc", "cc", "cx", "admin"}) {
+		t.Fatalf("default broadcast = %v", got)
+	}
+
+	policy.Broadcast = false
+	got = BroadcastTargets(panesWithAdmin, policy)
+	if !reflect.DeepEqual(got, []string{"oc", "cc", "cx"}) {
+		t.Fatalf("broadcast without admin = %v", got)
+	}
+
+	got = BroadcastTargets(map[string]string{"oc": "%0", "cc": "%1"}, config.DefaultAdminPolicy())
+	if !reflect.DeepEqual(got, []string{"oc", "cc"}) {
+		t.Fatalf("broadcast with unmapped admin = %v", got)
+	}
+
+	got = BroadcastTargets(map[string]string{"oc": "%0", "admin": ""}, config.DefaultAdminPolicy())
+	if !reflect.DeepEqual(got, []string{"oc"}) {
+		t.Fatalf("broadcast with blank admin pane = %v", got)
+	}
+}
+
+func TestBroadcastTargetsFollowPaneMap(t *testing.T) {
+	panes := map[string]string{"oc": "%0", "cc": "%1", "cx": "%2", "qa": "%5", "cx2": "%6", "vog": ""}
+	got, err := Targets("all", panes, config.DefaultAdminPolicy())
+	if err != nil || !reflect.DeepEqual(got, []string{"oc", "cc", "cx", "cx2", "qa"}) {
+		t.Fatalf("Targets(all) = %v, %v", got, err)
+	}
+}
+
+func TestTargetsAdminDirect(t *testing.T) {
+	policy := config.DefaultAdminPolicy()
+	got, err := Targets("admin", panesWithAdmin, policy)
+	if err != nil || !reflect.DeepEqual(got, []string{"admin"}) {
+		t.Fatalf("Targets(admin) = %v, %v", got, err)
+	}
+
+	policy.Direct = false
+	if _, err := Targets("admin", panesWithAdmin, policy); !errors.Is(err, ErrAdminRouteDisabled) {
+		t.Fatalf("expected ErrAdminRouteDisabled, got %v", err)
+	}
+}
+
+func TestTargetsDirectRole(t *testing.T) {
+	got, err := Targets("cc", panesWithAdmin, config.DefaultAdminPolicy())
+	if err != nil || !reflect.DeepEqual(got, []string{"cc"}) {
+		t.Fatalf("Targets(cc) = %v, %v", got, err)
+	}
+}
+
+func TestAuxiliaryRoleRouting(t *testing.T) {
+	// vog can always send: its messages route by recipient.
+	got, err := Targets("cc", panesWithAdmin, config.DefaultAdminPolicy())
+	if err != nil || !reflect.DeepEqual(got, []string{"cc"}) {
+		t.Fatalf("Targets(cc) from vog = %v, %v", got, err)
+	}
+
+	// Unmapped: a clear diagnostic instead of an injector "unknown target".
+	_, err = Targets("vog", panesWithAdmin, config.DefaultAdminPolicy())
+	var unmapped *UnmappedTargetError
+	if !errors.As(err, &unmapped) || unmapped.Role != "vog" {
+		t.Fatalf("Targets(vog) unmapped err = %v", err)
+	}
+	if missing := UnmappedRoles(panesWithAdmin); !reflect.DeepEqual(missing, []string{"vog"}) {
+		t.Fatalf("UnmappedRoles = %v", missing)
+	}
+
+	// Mapped: direct delivery and broadcast membership.
+	panes := map[string]string{"oc": "%0", "cc": "%1", "cx": "%2", "vog": "%4"}
+	got, err = Targets("vog", panes, config.DefaultAdminPolicy())
+	if err != nil || !reflect.DeepEqual(got, []string{"vog"}) {
+		t.Fatalf("Targets(vog) mapped = %v, %v", got, err)
+	}
+	got = BroadcastTargets(panes, config.DefaultAdminPolicy())
+	if !reflect.DeepEqual(got, []string{"oc", "cc", "cx", "vog"}) {
+		t.Fatalf("broadcast with vog = %v", got)
+	}
+}
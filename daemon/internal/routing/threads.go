@@ -0,0 +1,127 @@
+package routing
+
+import (
+	"container/list"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/norm/relay-daemon/pkg/envelope"
+)
+
+// ThreadPrefix addresses an envelope to the recent participants of a
+// thread: To "thread:<id>".
+const ThreadPrefix = "thread:"
+
+// ErrNoThreadMembers is returned for a thread message when no other agent
+// with a pane has recently posted in the thread.
+var ErrNoThreadMembers = errors.New("routing: no recent thread members with a pane")
+
+// Default bounds for ThreadMembers.
+const (
+	DefaultMaxThreads      = 256
+	DefaultThreadMemberTTL = time.Hour
+)
+
+// ThreadMembers remembers which agents recently sent in which thread. It
+// keeps at most maxThreads threads, evicting the least recently active,
+// and forgets a member that has not posted in the thread for ttl.
+type ThreadMembers struct {
+	mu         sync.Mutex
+	maxThreads int
+	ttl        time.Duration
+	order      *list.List // thread IDs, front = most recently active
+	threads    map[string]*threadEntry
+	now        func() time.Time
+}
+
+type threadEntry struct {
+	elem    *list.Element
+	members map[string]time.Time // role → last post
+}
+
+// NewThreadMembers returns an empty tracker. Non-positive bounds use the
+// defaults.
+func NewThreadMembers(maxThreads int, ttl time.Duration) *ThreadMembers {
+	if maxThreads <= 0 {
+		maxThreads = DefaultMaxThreads
+	}
+	if ttl <= 0 {
+		ttl = DefaultThreadMemberTTL
+	}
+	return &ThreadMembers{
+		maxThreads: maxThreads,
+		ttl:        ttl,
+		order:      list.New(),
+		threads:    make(map[string]*threadEntry),
+		now:        time.Now,
+	}
+}
+
+// Observe records env's sender as a participant of env's thread. Envelopes
+// without a thread are ignored.
+func (t *ThreadMembers) Observe(env *envelope.Envelope) {
+	if env == nil || env.ThreadID == "" || env.From == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.threads[env.ThreadID]
+	if ok {
+		t.order.MoveToFront(entry.elem)
+	} else {
+		entry = &threadEntry{elem: t.order.PushFront(env.ThreadID), members: map[string]time.Time{}}
+		t.threads[env.ThreadID] = entry
+		for t.order.Len() > t.maxThreads {
+			oldest := t.order.Back()
+			t.order.Remove(oldest)
+			delete(t.threads, oldest.Value.(string))
+		}
+	}
+	entry.members[env.From] = t.now()
+}
+
+// Members returns the agents that posted in threadID within the TTL,
+// sorted by name.
+func (t *ThreadMembers) Members(threadID string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.threads[threadID]
+	if !ok {
+		return nil
+	}
+	cutoff := t.now().Add(-t.ttl)
+	var members []string
+	for role, seen := range entry.members {
+		if seen.Before(cutoff) {
+			delete(entry.members, role)
+			continue
+		}
+		members = append(members, role)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// ThreadID returns the thread named by a "thread:<id>" address.
+func ThreadID(to string) (string, bool) {
+	id, ok := strings.CutPrefix(to, ThreadPrefix)
+	return id, ok && id != ""
+}
+
+// ThreadTargets resolves a thread message from sender: the thread's recent
+// members that have a pane, other than the sender.
+func ThreadTargets(threadID, from string, members *ThreadMembers, paneTargets map[string]string) ([]string, error) {
+	var targets []string
+	for _, role := range members.Members(threadID) {
+		if role != from && paneTargets[role] != "" {
+			targets = append(targets, role)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, ErrNoThreadMembers
+	}
+	return targets, nil
+}
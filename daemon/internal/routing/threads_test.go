@@ -0,0 +1,68 @@
+package routing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/norm/relay-daemon/pkg/envelope"
+)
+
+func threadPost(from, thread string) *envelope.Envelope {
+	env := envelope.NewEnvelope(from, "oc", "chat", "x")
+	env.ThreadID = thread
+	return env
+}
+
+func TestThreadMembersBounds(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	members := NewThreadMembers(2, time.Minute)
+	members.now = func() time.Time { return now }
+
+	members.Observe(threadPost("cc", "t1"))
+	members.Observe(threadPost("cc", "t2"))
+	members.Observe(threadPost("cx", "t1"))
+	members.Observe(threadPost("", "t1"))
+	members.Observe(threadPost("vog", ""))
+	if got := members.Members("t1"); !reflect.DeepEqual(got, []string{"cc", "cx"}) {
+		t.Fatalf("t1 members = %v", got)
+	}
+
+	// t2 is now the least recently active thread and is evicted.
+	members.Observe(threadPost("cx", "t3"))
+	if got := members.Members("t2"); got != nil {
+		t.Fatalf("evicted t2 members = %v", got)
+	}
+
+	now = now.Add(45 * time.Second)
+	members.Observe(threadPost("cx", "t1"))
+	now = now.Add(30 * time.Second)
+	if got := members.Members("t1"); !reflect.DeepEqual(got, []string{"cx"}) {
+		t.Fatalf("t1 members after cc went quiet = %v", got)
+	}
+}
+
+func TestThreadTargets(t *testing.T) {
+	members := NewThreadMembers(0, 0)
+	members.Observe(threadPost("cc", "t1"))
+	members.Observe(threadPost("vog", "t1"))
+	members.Observe(threadPost("oc", "t1"))
+	panes := map[string]string{"oc": "%0", "cc": "%1", "cx": "%2"}
+
+	id, ok := ThreadID("thread:t1")
+	if !ok || id != "t1" {
+		t.Fatalf("ThreadID = %q, %v", id, ok)
+	}
+	if _, ok := ThreadID("thread:"); ok {
+		t.Fatal("empty thread ID accepted")
+	}
+	// The sender and members without a pane are skipped.
+	got, err := ThreadTargets("t1", "oc", members, panes)
+	if err != nil || !reflect.DeepEqual(got, []string{"cc"}) {
+		t.Fatalf("ThreadTargets = %v, %v", got, err)
+	}
+	if _, err := ThreadTargets("t9", "oc", members, panes); !errors.Is(err, ErrNoThreadMembers) {
+		t.Fatalf("unknown thread err = %v", err)
+	}
+}
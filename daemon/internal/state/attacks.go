@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+
+	"github.com/norm/relay-daemon/internal/clockskew"
 )
 
 // AttackState captures minimal attack status plus events.
@@ -32,6 +34,7 @@ type AttackWatcher struct {
 	mu         sync.RWMutex
 	attacks    map[string]*AttackState
 	paths      map[string]string
+	clock      clockskew.Policy
 }
 
 func NewAttackWatcher(attacksDir string) *AttackWatcher {
@@ -39,9 +42,27 @@ func NewAttackWatcher(attacksDir string) *AttackWatcher {
 		attacksDir: attacksDir,
 		attacks:    make(map[string]*AttackState),
 		paths:      make(map[string]string),
+		clock:      clockskew.DefaultPolicy(),
 	}
 }
 
+// SetClockPolicy sets the skew bounds applied to LastUpdated timestamps.
+func (w *AttackWatcher) SetClockPolicy(p clockskew.Policy) {
+	w.mu.Lock()
+	w.clock = p
+	w.mu.Unlock()
+}
+
+// Age returns how long ago the attack was updated, bounded against clock
+// jumps: a future LastUpdated counts as just now and an implausibly old
+// one is capped.
+func (w *AttackWatcher) Age(attack *AttackState) (time.Duration, clockskew.Bucket) {
+	w.mu.RLock()
+	clock := w.clock
+	w.mu.RUnlock()
+	return clock.Age(time.Now(), attack.LastUpdated)
+}
+
 // Scan refreshes attack state from disk.
 func (w *AttackWatcher) Scan() error {
 	entries, err := os.ReadDir(w.attacksDir)
@@ -114,7 +135,8 @@ func (w *AttackWatcher) IsStale(attack *AttackState, threshold time.Duration) bo
 	if attack == nil {
 		return false
 	}
-	return time.Since(attack.LastUpdated) > threshold
+	age, _ := w.Age(attack)
+	return age > threshold
 }
 
 // AppendEvent appends a state event and writes to disk.
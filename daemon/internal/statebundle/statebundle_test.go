@@ -0,0 +1,109 @@
+package statebundle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/norm/relay-daemon/internal/inbox"
+	"github.com/norm/relay-daemon/internal/tmux"
+	"github.com/norm/relay-daemon/pkg/envelope"
+)
+
+type nopRunner struct{}
+
+func (nopRunner) Run(args ...string) (string, error)    { return "", nil }
+func (nopRunner) SendToPane(pane, message string) error { return nil }
+
+func newComponents(t *testing.T) (*tmux.Injector, *inbox.Watcher) {
+	t.Helper()
+	inj := tmux.NewInjector(nopRunner{}, map[string]string{"cc": "%1", "oc": "%2"})
+	w, err := inbox.NewWatcher(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewWatcher: %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	return inj, w
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	inj, w := newComponents(t)
+	first := envelope.NewEnvelope("oc", "cc", "chat", "first")
+	second := envelope.NewEnvelope("oc", "cc", "chat", "second")
+	third := envelope.NewEnvelope("cc", "oc", "chat", "third")
+	for _, env := range []*envelope.Envelope{first, second, third} {
+		if err := inj.Inject(env); err != nil {
+			t.Fatalf("Inject: %v", err)
+		}
+	}
+	w.SetOffsets(map[string]int64{"/inbox/cc/a.msg": 42, "/inbox/oc/b.msg": 7})
+
+	dir := t.TempDir()
+	if err := (&StateBundle{Injector: inj, Watcher: w}).Export(dir); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	freshInj, freshW := newComponents(t)
+	sb := &StateBundle{Injector: freshInj, Watcher: freshW}
+	if err := sb.Import(dir); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(sb.Skipped) != 0 {
+		t.Fatalf("Skipped = %v, want none", sb.Skipped)
+	}
+
+	queues := freshInj.SnapshotQueues()
+	var got []string
+	for _, q := range queues["cc"] {
+		got = append(got, q.Envelope.MsgID)
+	}
+	if len(got) != 2 || got[0] != first.MsgID || got[1] != second.MsgID {
+		t.Fatalf("cc queue = %v, want [%s %s]", got, first.MsgID, second.MsgID)
+	}
+	if len(queues["oc"]) != 1 || queues["oc"][0].Envelope.Payload != "third" {
+		t.Fatalf("oc queue = %+v", queues["oc"])
+	}
+
+	offsets := freshW.Offsets()
+	if offsets["/inbox/cc/a.msg"] != 42 || offsets["/inbox/oc/b.msg"] != 7 || len(offsets) != 2 {
+		t.Fatalf("offsets = %v", offsets)
+	}
+}
+
+func TestImportDropsUnmappedTargets(t *testing.T) {
+	inj, _ := newComponents(t)
+	if err := inj.Inject(envelope.NewEnvelope("oc", "cc", "chat", "hi")); err != nil {
+		t.Fatalf("Inject: %v", err)
+	}
+	dir := t.TempDir()
+	if err := (&StateBundle{Injector: inj}).Export(dir); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	fresh := tmux.NewInjector(nopRunner{}, map[string]string{"oc": "%2"})
+	var dropped []string
+	fresh.SetDropHook(func(env *envelope.Envelope, reason string) {
+		dropped = append(dropped, env.Payload+":"+reason)
+	})
+	sb := &StateBundle{Injector: fresh}
+	if err := sb.Import(dir); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(sb.Skipped) != 1 || sb.Skipped[0] != "cc" {
+		t.Fatalf("Skipped = %v, want [cc]", sb.Skipped)
+	}
+	// The message is handed on to be dead-lettered, not lost.
+	if len(dropped) != 1 || dropped[0] != "hi:"+tmux.DropUnknownTarget {
+		t.Fatalf("dropped = %v", dropped)
+	}
+}
+
+func TestImportRejectsUnknownVersion(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(`{"version": 99}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&StateBundle{}).Import(dir); err == nil {
+		t.Fatal("Import accepted an unknown bundle version")
+	}
+}
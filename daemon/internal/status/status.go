@@ -0,0 +1,114 @@
+// Package status serves a live view of the running daemon over HTTP:
+// /healthz for liveness probes and /status for a JSON snapshot of routing
+// and queue state.
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/norm/relay-daemon/internal/panehistory"
+)
+
+// HeartbeatInterval is how often the daemon's main loop should call Beat.
+// /healthz fails once three intervals pass without one.
+const HeartbeatInterval = 5 * time.Second
+
+// shutdownTimeout bounds how long Run waits for in-flight requests.
+const shutdownTimeout = 5 * time.Second
+
+// Snapshot is the /status response body.
+type Snapshot struct {
+	At            time.Time            `json:"at"`
+	StartedAt     time.Time            `json:"started_at"`
+	PaneTargets   map[string]string    `json:"pane_targets"`
+	QueueDepths   map[string]int       `json:"queue_depths"`
+	LastInject    map[string]time.Time `json:"last_inject"`
+	UnmappedRoles []string             `json:"unmapped_roles,omitempty"`
+	PaneWarnings  []string             `json:"pane_warnings,omitempty"`
+	PaneHistory   []panehistory.Entry  `json:"pane_history,omitempty"`
+}
+
+// Server answers /healthz and /status. snapshot is called per request.
+type Server struct {
+	addr     string
+	snapshot func() Snapshot
+	lastBeat atomic.Int64 // unix nanoseconds
+	now      func() time.Time
+}
+
+// New returns a Server listening on addr once Run is called.
+func New(addr string, snapshot func() Snapshot) *Server {
+	return &Server{addr: addr, snapshot: snapshot, now: time.Now}
+}
+
+// Beat records that the main loop is alive.
+func (s *Server) Beat() {
+	s.lastBeat.Store(s.now().UnixNano())
+}
+
+// Alive reports whether Beat was called within three heartbeat intervals.
+func (s *Server) Alive() bool {
+	last := s.lastBeat.Load()
+	if last == 0 {
+		return false
+	}
+	return s.now().Sub(time.Unix(0, last)) <= 3*HeartbeatInterval
+}
+
+// Handler returns the HTTP routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/status", s.handleStatus)
+	return mux
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if !s.Alive() {
+		http.Error(w, "main loop stalled", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	snap := s.snapshot()
+	snap.At = s.now().UTC()
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(snap)
+}
+
+// Run serves until ctx is canceled, then shuts down gracefully.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Serve(ln) }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthzFollowsHeartbeat(t *testing.T) {
+	s := New("", func() Snapshot { return Snapshot{} })
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	h := s.Handler()
+
+	get := func() int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		return rec.Code
+	}
+
+	if code := get(); code != http.StatusServiceUnavailable {
+		t.Fatalf("healthz before first beat = %d, want 503", code)
+	}
+	s.Beat()
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("healthz after beat = %d, want 200", code)
+	}
+	now = now.Add(3*HeartbeatInterval + time.Second)
+	if code := get(); code != http.StatusServiceUnavailable {
+		t.Fatalf("healthz after stalled loop = %d, want 503", code)
+	}
+}
+
+func TestStatusSnapshot(t *testing.T) {
+	s := New("", func() Snapshot {
+		return Snapshot{
+			PaneTargets: map[string]string{"cc": "%1"},
+			QueueDepths: map[string]int{"cc": 3},
+		}
+	})
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code = %d", rec.Code)
+	}
+	var got Snapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.PaneTargets["cc"] != "%1" || got.QueueDepths["cc"] != 3 || got.At.IsZero() {
+		t.Fatalf("snapshot = %+v", got)
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	s := New("127.0.0.1:0", func() Snapshot { return Snapshot{} })
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/norm/relay-daemon/internal/clockskew"
 	logpkg "github.com/norm/relay-daemon/internal/log"
 	"github.com/norm/relay-daemon/internal/state"
 	tmuxpkg "github.com/norm/relay-daemon/internal/tmux"
@@ -79,8 +80,11 @@ func (n *Nagger) Check() error {
 			continue
 		}
 
-		minutes := int(now.Sub(attack.LastUpdated).Minutes())
-		message := fmt.Sprintf("[RELAY] Attack %s appears stalled. Last update %d min ago.", attack.AttackID, minutes)
+		age, bucket := n.attacks.Age(attack)
+		message := fmt.Sprintf("[RELAY] Attack %s appears stalled. Last update %d min ago.", attack.AttackID, int(age.Minutes()))
+		if bucket == clockskew.Implausible {
+			message = fmt.Sprintf("[RELAY] Attack %s appears stalled. Last update time is implausibly old; the clock may have changed.", attack.AttackID)
+		}
 		env := envelope.NewEnvelope("relay", "oc", "nag", message)
 		env.Priority = 0
 		env.ThreadID = attack.AttackID
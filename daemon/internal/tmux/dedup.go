@@ -0,0 +1,68 @@
+package tmux
+
+import (
+	"container/list"
+	"sync"
+)
+
+// defaultDedupWindow is how many recent deliveries Inject remembers when
+// no window is configured.
+const defaultDedupWindow = 1024
+
+// recentIDs is a bounded LRU set of recently delivered message keys.
+type recentIDs struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // front = most recent
+	index map[string]*list.Element
+}
+
+func newRecentIDs(size int) *recentIDs {
+	return &recentIDs{size: size, order: list.New(), index: make(map[string]*list.Element)}
+}
+
+// has reports whether key is remembered.
+func (r *recentIDs) has(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.index[key]
+	return ok
+}
+
+// add remembers key as the most recent, evicting the oldest keys beyond
+// the window. A window of size 0 remembers nothing.
+func (r *recentIDs) add(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size <= 0 {
+		return
+	}
+	if elem, ok := r.index[key]; ok {
+		r.order.MoveToFront(elem)
+		return
+	}
+	r.index[key] = r.order.PushFront(key)
+	for r.order.Len() > r.size {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.index, oldest.Value.(string))
+	}
+}
+
+// dedupKey keys a message by target too, so one broadcast still reaches
+// every pane.
+func dedupKey(target, msgID string) string {
+	return target + "\x00" + msgID
+}
+
+// resize changes the window, evicting the oldest keys if it shrank.
+func (r *recentIDs) resize(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.size = size
+	for r.order.Len() > 0 && r.order.Len() > size {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.index, oldest.Value.(string))
+	}
+}
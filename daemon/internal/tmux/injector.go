@@ -2,52 +2,122 @@ package tmux
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
+	"github.com/norm/relay-daemon/internal/capability"
 	logpkg "github.com/norm/relay-daemon/internal/log"
 	"github.com/norm/relay-daemon/internal/pane"
 	"github.com/norm/relay-daemon/pkg/envelope"
 )
 
+// Runner is the subset of tmux the injector drives. *Tmux implements it;
+// tests substitute a fake.
+type Runner interface {
+	Run(args ...string) (string, error)
+	SendToPane(pane, message string) error
+}
+
+// DeliveryHook is called after a message has been pasted into its pane.
+type DeliveryHook func(env *envelope.Envelope, deliveredAt time.Time)
+
+// DropHook is called when a queued message is dropped undelivered. reason
+// is DropExpired, DropGaveUp or DropUnknownTarget.
+type DropHook func(env *envelope.Envelope, reason string)
+
+// Reasons passed to a DropHook.
+const (
+	DropExpired = "expired" // its expiry or the queue max age passed
+	DropGaveUp  = "gave_up" // it used up its delivery attempts
+	// A restored message whose target is no longer in the pane map.
+	DropUnknownTarget = "unknown_target"
+)
+
+// defaultMaxSendAttempts is how many failed pastes or pane queries a
+// message gets before the queue gives up on it.
+const defaultMaxSendAttempts = 10
+
 // Injector maps envelopes to tmux targets and handles prompt-aware queuing.
 type Injector struct {
-	tmux         *Tmux
+	tmux         Runner
 	targets      map[string]string
 	promptGating string
+	gateAdmin    bool
 	queueMaxAge  time.Duration
+	minInterval  time.Duration
 	logger       *logpkg.EventLog
+	onDelivered  DeliveryHook
+	onDropped    DropHook
+	maxAttempts  int
+
+	readinessLines map[string]int
+	promptPrefixes []string
+	promptRegex    *regexp.Regexp
+	capabilities   *capability.Registry
+	recent         *recentIDs
 
 	mu        sync.RWMutex
 	queues    map[string]*paneQueue
 	startOnce sync.Once
+	running   sync.WaitGroup
+	runCtx    context.Context // set by Start; queues created later run under it
 }
 
 type queuedMessage struct {
 	env      *envelope.Envelope
 	enqueued time.Time
 	backoff  time.Duration
+	sent     int // parts already pasted, for payloads split by MaxInputChars
+	attempts int // failed pastes and pane queries so far
 }
 
 type paneQueue struct {
 	target string
 	paneID string
 
-	mu     sync.Mutex
-	items  []*queuedMessage
-	notify chan struct{}
+	mu         sync.Mutex
+	items      []*queuedMessage
+	current    *queuedMessage // dequeued and being delivered
+	notify     chan struct{}
+	lastInject time.Time
+	started    bool // a run goroutine owns the queue; guarded by Injector.mu
 }
 
-func NewInjector(tmux *Tmux, targets map[string]string) *Injector {
+func NewInjector(tmux Runner, targets map[string]string) *Injector {
 	return &Injector{
 		tmux:         tmux,
 		targets:      targets,
 		promptGating: "all",
 		queueMaxAge:  5 * time.Minute,
+		maxAttempts:  defaultMaxSendAttempts,
 		queues:       make(map[string]*paneQueue),
+		capabilities: capability.DefaultRegistry(),
+		recent:       newRecentIDs(defaultDedupWindow),
+	}
+}
+
+// SetCapabilities replaces the per-role capability registry.
+func (i *Injector) SetCapabilities(reg *capability.Registry) {
+	if reg == nil {
+		reg = capability.DefaultRegistry()
 	}
+	i.mu.Lock()
+	i.capabilities = reg
+	i.mu.Unlock()
+}
+
+func (i *Injector) capabilitiesFor(target string) capability.Capabilities {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.capabilities.Get(target)
 }
 
 func (i *Injector) SetLogger(logger *logpkg.EventLog) {
@@ -61,6 +131,85 @@ func (i *Injector) SetPromptGating(mode string) {
 	i.promptGating = strings.ToLower(mode)
 }
 
+// SetPromptPrefixes sets the prompt prefixes that mark panes with the
+// shell prompt style as ready. An empty list restores
+// pane.DefaultPromptPrefixes.
+func (i *Injector) SetPromptPrefixes(prefixes []string) {
+	i.promptPrefixes = prefixes
+}
+
+// SetPromptRegex sets a regular expression that marks panes with the shell
+// prompt style as ready when it matches the captured pane tail (trimmed of
+// surrounding whitespace). It takes precedence over the prompt prefixes;
+// an empty expr clears it.
+func (i *Injector) SetPromptRegex(expr string) error {
+	if expr == "" {
+		i.promptRegex = nil
+		return nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("prompt regex: %w", err)
+	}
+	i.promptRegex = re
+	return nil
+}
+
+// SetGateAdmin controls whether prompt gating applies to the admin pane.
+func (i *Injector) SetGateAdmin(gate bool) {
+	i.gateAdmin = gate
+}
+
+// defaultReadinessLines is how many trailing pane lines the readiness
+// check captures when no per-target depth is set.
+const defaultReadinessLines = 40
+
+// SetReadinessLines sets how many trailing lines IsPaneReady captures for
+// target. n <= 0 restores the default.
+func (i *Injector) SetReadinessLines(target string, n int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if n <= 0 {
+		delete(i.readinessLines, target)
+		return
+	}
+	if i.readinessLines == nil {
+		i.readinessLines = make(map[string]int)
+	}
+	i.readinessLines[target] = n
+}
+
+func (i *Injector) readinessDepth(target string) int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if n, ok := i.readinessLines[target]; ok {
+		return n
+	}
+	return defaultReadinessLines
+}
+
+// SetDeliveryHook registers fn to run after each successful delivery.
+// Dropped or still-queued messages never reach the hook.
+func (i *Injector) SetDeliveryHook(fn DeliveryHook) {
+	i.onDelivered = fn
+}
+
+// SetDropHook registers fn to run for each message dropped undelivered,
+// so it can be kept for replay.
+func (i *Injector) SetDropHook(fn DropHook) {
+	i.onDropped = fn
+}
+
+// SetMaxSendAttempts sets how many failed pastes or pane queries a message
+// gets before it is dropped so the messages behind it can proceed. n <= 0 retries
+// forever.
+func (i *Injector) SetMaxSendAttempts(n int) {
+	if n < 0 {
+		n = 0
+	}
+	i.maxAttempts = n
+}
+
 func (i *Injector) SetQueueMaxAge(maxAge time.Duration) {
 	if maxAge <= 0 {
 		return
@@ -68,6 +217,34 @@ func (i *Injector) SetQueueMaxAge(maxAge time.Duration) {
 	i.queueMaxAge = maxAge
 }
 
+// SetMinInjectInterval sets the minimum time between successful injections
+// into the same pane. Each target is paced independently; d <= 0 disables
+// pacing.
+func (i *Injector) SetMinInjectInterval(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	i.minInterval = d
+}
+
+// SetDedupWindow sets how many recent deliveries Inject remembers to drop
+// a repeat of the same MsgID to the same target. n <= 0 disables the
+// check; a MsgID still queued for the target is rejected regardless.
+func (i *Injector) SetDedupWindow(n int) {
+	i.recent.resize(n)
+}
+
+// Targets returns a copy of the current target→paneID mapping.
+func (i *Injector) Targets() map[string]string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	out := make(map[string]string, len(i.targets))
+	for name, paneID := range i.targets {
+		out[name] = paneID
+	}
+	return out
+}
+
 // UpdateTargets replaces the target→paneID mapping and updates any existing
 // paneQueue paneIDs. This must be called after a pane map refresh so the
 // injector uses the current pane layout.
@@ -82,48 +259,121 @@ func (i *Injector) UpdateTargets(targets map[string]string) {
 			pq.mu.Unlock()
 		}
 	}
+	// A role added after Start needs its queue running too.
+	for name, pane := range targets {
+		i.startQueueLocked(i.queueLocked(name, pane))
+	}
+}
+
+// QueueDepths returns the number of pending messages per target. A message
+// mid-delivery is not counted.
+func (i *Injector) QueueDepths() map[string]int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	out := make(map[string]int, len(i.queues))
+	for target, pq := range i.queues {
+		pq.mu.Lock()
+		out[target] = len(pq.items)
+		pq.mu.Unlock()
+	}
+	return out
+}
+
+// LastInjected returns the time of the latest successful injection per
+// target. Targets that have not received a message are omitted.
+func (i *Injector) LastInjected() map[string]time.Time {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	out := make(map[string]time.Time)
+	for target, pq := range i.queues {
+		pq.mu.Lock()
+		if !pq.lastInject.IsZero() {
+			out[target] = pq.lastInject
+		}
+		pq.mu.Unlock()
+	}
+	return out
 }
 
 func (i *Injector) Start(ctx context.Context) {
 	i.startOnce.Do(func() {
 		i.mu.Lock()
 		defer i.mu.Unlock()
+		i.runCtx = ctx
 		for target, pane := range i.targets {
-			if pq, ok := i.queues[target]; ok {
-				go pq.run(ctx, i)
-			} else {
-				pq := newPaneQueue(target, pane)
-				i.queues[target] = pq
-				go pq.run(ctx, i)
-			}
+			i.startQueueLocked(i.queueLocked(target, pane))
 		}
 	})
 }
 
+// startQueueLocked starts pq's run goroutine unless Start has not run yet,
+// its context has ended, or pq already has one. i.mu must be held.
+func (i *Injector) startQueueLocked(pq *paneQueue) {
+	if i.runCtx == nil || i.runCtx.Err() != nil || pq.started {
+		return
+	}
+	pq.started = true
+	i.running.Add(1)
+	go func() {
+		defer i.running.Done()
+		pq.run(i.runCtx, i)
+	}()
+}
+
+// Wait blocks until the queue goroutines the injector started have returned,
+// which they do once its context is canceled and any paste in progress
+// has finished. Queues are stable afterwards, so a snapshot taken then
+// holds every undelivered message.
+func (i *Injector) Wait() {
+	i.running.Wait()
+}
+
+// Inject errors that retrying cannot fix; the relay dead-letters them.
+var (
+	ErrInvalidEnvelope = errors.New("inject: invalid envelope")
+	ErrUnknownTarget   = errors.New("inject: unknown target")
+)
+
 func (i *Injector) Inject(env *envelope.Envelope) error {
 	if env == nil {
 		return fmt.Errorf("inject: nil envelope")
 	}
 	if err := env.Validate(); err != nil {
-		return fmt.Errorf("inject: invalid envelope: %w", err)
+		return fmt.Errorf("%w: %w", ErrInvalidEnvelope, err)
 	}
 	i.mu.RLock()
 	target, ok := i.targets[env.To]
 	i.mu.RUnlock()
 	if !ok {
-		return fmt.Errorf("inject: unknown target %q", env.To)
+		return fmt.Errorf("%w %q", ErrUnknownTarget, env.To)
 	}
 
+	// A MsgID recently delivered to this target, or still waiting in its
+	// queue, is a duplicate. One that expired or was given up on may be
+	// sent again.
 	item := &queuedMessage{env: env, enqueued: time.Now()}
 	pq := i.getQueue(env.To, target)
-	pq.enqueue(item)
+	if i.recent.has(dedupKey(env.To, env.MsgID)) || !pq.enqueueUnique(item) {
+		i.logEvent(logpkg.EventTypeDuplicate, env.From, env.To, env.MsgID, "")
+		return nil
+	}
 	i.logEvent(logpkg.EventTypeEnqueue, env.From, env.To, env.MsgID, "")
 	return nil
 }
 
+// getQueue returns target's queue, creating it, and starting it once the
+// injector runs, if needed.
 func (i *Injector) getQueue(target, paneID string) *paneQueue {
 	i.mu.Lock()
 	defer i.mu.Unlock()
+	pq := i.queueLocked(target, paneID)
+	i.startQueueLocked(pq)
+	return pq
+}
+
+// queueLocked returns target's queue, creating it if needed. i.mu must be
+// held.
+func (i *Injector) queueLocked(target, paneID string) *paneQueue {
 	if pq, ok := i.queues[target]; ok {
 		return pq
 	}
@@ -146,29 +396,83 @@ func (pq *paneQueue) getPaneID() string {
 	return pq.paneID
 }
 
+// enqueue inserts item in priority order: lower Priority values (0 =
+// urgent) go first and ties keep enqueue-time order. A message already
+// partly pasted stays at the head so its remaining parts are not split.
 func (pq *paneQueue) enqueue(item *queuedMessage) {
 	pq.mu.Lock()
-	pq.items = append(pq.items, item)
+	pq.insertLocked(item)
+	pq.mu.Unlock()
+	pq.signal()
+}
+
+// enqueueUnique enqueues item unless a message with the same MsgID is
+// already queued or being delivered, and reports whether it did.
+func (pq *paneQueue) enqueueUnique(item *queuedMessage) bool {
+	pq.mu.Lock()
+	held := pq.current != nil && pq.current.env.MsgID == item.env.MsgID
+	for _, queued := range pq.items {
+		held = held || queued.env.MsgID == item.env.MsgID
+	}
+	if !held {
+		pq.insertLocked(item)
+	}
 	pq.mu.Unlock()
+	if !held {
+		pq.signal()
+	}
+	return !held
+}
+
+func (pq *paneQueue) insertLocked(item *queuedMessage) {
+	pos := 0
+	for pos < len(pq.items) && pq.items[pos].sent > 0 {
+		pos++
+	}
+	for pos < len(pq.items) && !item.before(pq.items[pos]) {
+		pos++
+	}
+	pq.items = slices.Insert(pq.items, pos, item)
+}
+
+func (pq *paneQueue) signal() {
 	select {
 	case pq.notify <- struct{}{}:
 	default:
 	}
 }
 
+// before reports whether m should be delivered ahead of other.
+func (m *queuedMessage) before(other *queuedMessage) bool {
+	if m.env.Priority != other.env.Priority {
+		return m.env.Priority < other.env.Priority
+	}
+	return m.enqueued.Before(other.enqueued)
+}
+
+// dequeue pops the head item, which stays current until it is requeued or
+// the next dequeue.
 func (pq *paneQueue) dequeue() *queuedMessage {
 	pq.mu.Lock()
 	defer pq.mu.Unlock()
+	pq.current = nil
 	if len(pq.items) == 0 {
 		return nil
 	}
-	item := pq.items[0]
+	pq.current = pq.items[0]
 	pq.items = pq.items[1:]
-	return item
+	return pq.current
+}
+
+func (pq *paneQueue) markInjected() {
+	pq.mu.Lock()
+	pq.lastInject = time.Now()
+	pq.mu.Unlock()
 }
 
 func (pq *paneQueue) requeueFront(item *queuedMessage) {
 	pq.mu.Lock()
+	pq.current = nil
 	pq.items = append([]*queuedMessage{item}, pq.items...)
 	pq.mu.Unlock()
 }
@@ -191,8 +495,8 @@ func (pq *paneQueue) run(ctx context.Context, injector *Injector) {
 			}
 		}
 
-		if injector.queueMaxAge > 0 && time.Since(item.enqueued) > injector.queueMaxAge {
-			injector.logEvent("drop", item.env.From, pq.target, item.env.MsgID, truncateForLog(item.env.Payload))
+		if injector.expired(item, time.Now()) {
+			injector.dropExpired(item.env, pq.target)
 			continue
 		}
 
@@ -201,36 +505,50 @@ func (pq *paneQueue) run(ctx context.Context, injector *Injector) {
 
 		injector.logEvent(logpkg.EventTypeDequeue, item.env.From, pq.target, item.env.MsgID, "")
 
+		caps := injector.capabilitiesFor(pq.target)
+
 		// Slash commands are injected bare so Claude Code parses them as skill invocations
-		if strings.HasPrefix(strings.TrimSpace(item.env.Payload), "/") {
+		if caps.SupportsSlashCommands && strings.HasPrefix(strings.TrimSpace(item.env.Payload), "/") {
 			if err := injector.tmux.SendToPane(paneID, strings.TrimSpace(item.env.Payload)); err != nil {
-				injector.logEvent(logpkg.EventTypeBlocked, item.env.From, pq.target, item.env.MsgID, truncateForLog(err.Error()))
-				item.backoff = nextBackoff(item.backoff)
-				pq.requeueFront(item)
-				if !sleepOrDone(ctx, item.backoff) {
+				if !pq.sendFailed(ctx, injector, item, err) {
 					return
 				}
 				continue
 			}
-			injector.logEvent(logpkg.EventTypeInject, item.env.From, pq.target, item.env.MsgID, "")
+			injector.logInjected(item, pq.target)
+			pq.markInjected()
+			injector.delivered(item.env)
+			if !injector.pace(ctx) {
+				return
+			}
 			continue
 		}
 
-		ready, tail, err := injector.IsPaneReady(paneID, pq.target)
-		if err != nil || !ready {
+		readiness := injector.CheckReadiness(paneID, pq.target)
+		if !readiness.Ready() {
 			// CX suggestion detected — dismiss and inject immediately
-			if pq.target == "cx" && pane.CodexFooterVisible(tail) {
+			if caps.PromptStyle == capability.PromptCodex && pane.CodexFooterVisible(readiness.Tail) {
 				_, _ = injector.tmux.Run("send-keys", "-t", paneID, " ")
 				time.Sleep(200 * time.Millisecond)
 				_, _ = injector.tmux.Run("send-keys", "-t", paneID, "BSpace")
 				time.Sleep(200 * time.Millisecond)
 				// Fall through to inject below instead of requeueing
 			} else {
-				if tail == "" && err != nil {
-					tail = err.Error()
+				detail := readiness.Tail
+				if detail == "" && readiness.Err != nil {
+					detail = readiness.Err.Error()
+				}
+				// A busy pane is waited out; a pane that is gone or that
+				// tmux keeps failing to query uses up attempts like a
+				// failed paste.
+				if readiness.Reason == ReasonCaptureError || readiness.Reason == ReasonPaneMissing {
+					if !pq.attemptFailed(ctx, injector, item, readiness.Reason, detail) {
+						return
+					}
+					continue
 				}
-				injector.logEvent(logpkg.EventTypeBlocked, item.env.From, pq.target, item.env.MsgID, truncateForLog(tail))
-				item.backoff = nextBackoff(item.backoff)
+				injector.logBlocked(item.env, pq.target, readiness.Reason, detail)
+				item.backoff = nextBackoffFor(readiness.Reason, item.backoff)
 				pq.requeueFront(item)
 				if !sleepOrDone(ctx, item.backoff) {
 					return
@@ -239,29 +557,183 @@ func (pq *paneQueue) run(ctx context.Context, injector *Injector) {
 			}
 		}
 
-		// Wrap payload in relay-message XML tags for agent protocol.
-		// Escape payload to prevent XML injection (& -> &amp;, < -> &lt;).
-		safePayload := xmlEscapePayload(item.env.Payload)
-		tagged := fmt.Sprintf("<relay-message from=%q to=%q kind=%q>\n[Relay from %s. Not from the human user.]\n\n%s\n</relay-message>",
-			item.env.From, item.env.To, item.env.Kind, item.env.From, safePayload)
-
-		if err := injector.tmux.SendToPane(paneID, tagged); err != nil {
-			injector.logEvent(logpkg.EventTypeBlocked, item.env.From, pq.target, item.env.MsgID, truncateForLog(err.Error()))
-			item.backoff = nextBackoff(item.backoff)
-			pq.requeueFront(item)
-			if !sleepOrDone(ctx, item.backoff) {
+		parts := wrapMessage(item.env, caps)
+		var sendErr error
+		for item.sent < len(parts) {
+			if sendErr = injector.tmux.SendToPane(paneID, parts[item.sent]); sendErr != nil {
+				break
+			}
+			item.sent++
+		}
+		if sendErr != nil {
+			if !pq.sendFailed(ctx, injector, item, sendErr) {
 				return
 			}
 			continue
 		}
 
-		injector.logEvent(logpkg.EventTypeInject, item.env.From, pq.target, item.env.MsgID, "")
+		injector.logInjected(item, pq.target)
+		pq.markInjected()
+		injector.delivered(item.env)
+		if !injector.pace(ctx) {
+			return
+		}
+	}
+}
+
+// sendFailed handles a failed paste of item; see attemptFailed.
+func (pq *paneQueue) sendFailed(ctx context.Context, injector *Injector, item *queuedMessage, err error) bool {
+	return pq.attemptFailed(ctx, injector, item, ReasonSendError, err.Error())
+}
+
+// attemptFailed handles a delivery attempt of item that failed for reason.
+// The item goes back to the head of the queue after a backoff until it has
+// used its attempts; then it is dropped with a gave_up event so the queue
+// advances. It returns false when ctx ended while waiting.
+func (pq *paneQueue) attemptFailed(ctx context.Context, injector *Injector, item *queuedMessage, reason ReadyReason, detail string) bool {
+	injector.logBlocked(item.env, pq.target, reason, detail)
+	item.attempts++
+	if injector.maxAttempts > 0 && item.attempts >= injector.maxAttempts {
+		if detail == "" {
+			detail = string(reason)
+		}
+		injector.logEvent(logpkg.EventTypeGaveUp, item.env.From, pq.target, item.env.MsgID,
+			fmt.Sprintf("after %d attempts: %s", item.attempts, detail))
+		injector.dropped(item.env, DropGaveUp)
+		return true
+	}
+	item.backoff = nextBackoffFor(reason, item.backoff)
+	pq.requeueFront(item)
+	return sleepOrDone(ctx, item.backoff)
+}
+
+// dropExpired logs a drop event for an expired message and hands it to
+// the drop hook.
+func (i *Injector) dropExpired(env *envelope.Envelope, target string) {
+	i.logEvent("drop", env.From, target, env.MsgID, truncateForLog(env.Payload))
+	i.dropped(env, DropExpired)
+}
+
+func (i *Injector) dropped(env *envelope.Envelope, reason string) {
+	if i.onDropped != nil {
+		i.onDropped(env, reason)
+	}
+}
+
+// expired reports whether item should be dropped undelivered at now. An
+// envelope's own expiry takes precedence over queueMaxAge, in either
+// direction.
+func (i *Injector) expired(item *queuedMessage, now time.Time) bool {
+	if expiry, ok := item.env.Expiry(); ok {
+		return now.After(expiry)
+	}
+	return i.queueMaxAge > 0 && now.Sub(item.enqueued) > i.queueMaxAge
+}
+
+// pace waits out the minimum inject interval after a delivery. Every
+// target runs its own queue goroutine, so only this pane is held back;
+// messages that wait are still checked for expiry on dequeue.
+func (i *Injector) pace(ctx context.Context) bool {
+	if i.minInterval <= 0 {
+		return true
+	}
+	return sleepOrDone(ctx, i.minInterval)
+}
+
+// wrapMessage renders the pane text for env, split into parts when the
+// target limits input length. The limit counts runes of each rendered
+// part, wrapper and part header included; one too small to hold the
+// wrapper leaves the message whole.
+func wrapMessage(env *envelope.Envelope, caps capability.Capabilities) []string {
+	whole := wrapPayload(env, env.Payload, caps.WrapTemplate)
+	max := caps.MaxInputChars
+	if max <= 0 || utf8.RuneCountInString(whole) <= max {
+		return []string{whole}
+	}
+	overhead := utf8.RuneCountInString(wrapPayload(env, "", caps.WrapTemplate))
+	// The "[part n/total]" header widens with the digits of total, so
+	// retry with wider headers until the split needs no more.
+	for digits := 1; ; digits++ {
+		header := len("[part /]\n") + 2*digits
+		chunks := splitPayload(env.Payload, max-overhead-header)
+		if chunks == nil {
+			return []string{whole}
+		}
+		if len(strconv.Itoa(len(chunks))) > digits {
+			continue
+		}
+		parts := make([]string, 0, len(chunks))
+		for n, chunk := range chunks {
+			chunk = fmt.Sprintf("[part %d/%d]\n%s", n+1, len(chunks), chunk)
+			parts = append(parts, wrapPayload(env, chunk, caps.WrapTemplate))
+		}
+		return parts
+	}
+}
+
+// wrapPayload wraps one payload chunk using the target's template, or the
+// <relay-message> XML tags of the agent protocol by default.
+// The payload is escaped to prevent XML injection (& -> &amp;, < -> &lt;).
+func wrapPayload(env *envelope.Envelope, payload, wrapTemplate string) string {
+	safePayload := xmlEscapePayload(payload)
+	if wrapTemplate != "" {
+		if tmpl, err := template.New("wrap").Parse(wrapTemplate); err == nil {
+			var b strings.Builder
+			data := struct{ From, To, Kind, MsgID, Payload string }{env.From, env.To, env.Kind, env.MsgID, safePayload}
+			if err := tmpl.Execute(&b, data); err == nil {
+				return b.String()
+			}
+		}
+	}
+	return fmt.Sprintf("<relay-message from=%q to=%q kind=%q>\n[Relay from %s. Not from the human user.]\n\n%s\n</relay-message>",
+		env.From, env.To, env.Kind, env.From, safePayload)
+}
+
+// splitPayload cuts payload into chunks whose escaped form is at most max
+// runes, or returns nil when max cannot hold a single rune.
+func splitPayload(payload string, max int) []string {
+	if max < len("&#47;") {
+		return nil
+	}
+	var chunks []string
+	var chunk []rune
+	size := 0
+	prev := rune(0)
+	for _, r := range payload {
+		// Escaped width, assuming every "</" is a closing tag.
+		width := 1
+		switch {
+		case r == '&':
+			width = len("&amp;")
+		case r == '<':
+			width = len("&lt;")
+		case r == '/' && prev == '<':
+			width = len("&#47;")
+		}
+		if size+width > max {
+			chunks = append(chunks, string(chunk))
+			chunk, size = nil, 0
+		}
+		chunk = append(chunk, r)
+		size += width
+		prev = r
+	}
+	return append(chunks, string(chunk))
+}
+
+func (i *Injector) delivered(env *envelope.Envelope) {
+	i.recent.add(dedupKey(env.To, env.MsgID))
+	if i.onDelivered != nil {
+		i.onDelivered(env, time.Now())
 	}
 }
 
 func (i *Injector) shouldGate(target string) bool {
-	// Admin pane runs Claude, not a shell — never gate admin commands
-	if target == "admin" {
+	// Admin pane runs Claude, not a shell — not gated unless policy says so
+	if target == "admin" && !i.gateAdmin {
+		return false
+	}
+	if i.capabilitiesFor(target).PromptStyle == capability.PromptNone {
 		return false
 	}
 	switch i.promptGating {
@@ -274,26 +746,112 @@ func (i *Injector) shouldGate(target string) bool {
 	}
 }
 
-// IsPaneReady checks copy-mode and prompt readiness for a pane.
-func (i *Injector) IsPaneReady(paneID, target string) (bool, string, error) {
+// ReadyReason explains the outcome of a readiness check.
+type ReadyReason string
+
+const (
+	ReasonReady        ReadyReason = "ready"
+	ReasonCopyMode     ReadyReason = "copy_mode"     // pane is in tmux copy-mode
+	ReasonStreaming    ReadyReason = "streaming"     // no input prompt visible yet
+	ReasonPaneMissing  ReadyReason = "pane_missing"  // tmux cannot find the pane, e.g. mid-recycle
+	ReasonCaptureError ReadyReason = "capture_error" // tmux query failed
+	ReasonSendError    ReadyReason = "send_error"    // paste into the pane failed
+)
+
+// Readiness is the result of CheckReadiness.
+type Readiness struct {
+	Reason ReadyReason
+	Tail   string // captured pane text, when the capture ran
+	Err    error  // set for ReasonCaptureError
+}
+
+// Ready reports whether the pane can accept input.
+func (r Readiness) Ready() bool {
+	return r.Reason == ReasonReady
+}
+
+// CheckReadiness checks copy-mode and prompt readiness for a pane and
+// reports why it is not ready.
+func (i *Injector) CheckReadiness(paneID, target string) Readiness {
 	if !i.shouldGate(target) {
-		return true, "", nil
+		return Readiness{Reason: ReasonReady}
 	}
 
-	mode, err := i.tmux.Run("display-message", "-t", paneID, "-p", "#{pane_mode}")
+	mode, err := i.paneMode(paneID)
+	if err != nil {
+		if isPaneMissing(err) {
+			return Readiness{Reason: ReasonPaneMissing}
+		}
+		return Readiness{Reason: ReasonCaptureError, Err: err}
+	}
+	if strings.Contains(mode, "copy") {
+		return Readiness{Reason: ReasonCopyMode}
+	}
+
+	out, err := i.tmux.Run("capture-pane", "-t", paneID, "-p", "-S", fmt.Sprintf("-%d", i.readinessDepth(target)))
 	if err != nil {
-		return false, "", err
+		return Readiness{Reason: ReasonCaptureError, Err: err}
 	}
-	if strings.Contains(strings.ToLower(mode), "copy") {
-		return false, "", nil
+	tail := strings.TrimSpace(out)
+	if !i.promptVisible(target, out) {
+		return Readiness{Reason: ReasonStreaming, Tail: tail}
 	}
+	return Readiness{Reason: ReasonReady, Tail: tail}
+}
+
+// promptVisible applies target's prompt style to a pane capture.
+func (i *Injector) promptVisible(target, captured string) bool {
+	switch i.capabilitiesFor(target).PromptStyle {
+	case capability.PromptShell:
+		if i.promptRegex != nil {
+			return i.promptRegex.MatchString(strings.TrimSpace(captured))
+		}
+		return pane.HasPromptPrefix(captured, i.promptPrefixes)
+	case capability.PromptCodex:
+		// ParsePaneState keys its prompt detection on role: cx is Codex,
+		// any other role is Claude.
+		return pane.ParsePaneState("cx", captured).Ready
+	default:
+		return pane.ParsePaneState("cc", captured).Ready
+	}
+}
+
+// paneModeRetryDelay spaces the retry of a failed pane_mode query.
+const paneModeRetryDelay = 100 * time.Millisecond
 
-	out, err := i.tmux.Run("capture-pane", "-t", paneID, "-p", "-S", "-40")
+// paneModeRe matches a tmux pane_mode value: empty, or a name such as
+// copy-mode or view-mode.
+var paneModeRe = regexp.MustCompile(`^[a-z-]*$`)
+
+// paneMode queries the tmux mode of paneID. A failed query is retried once,
+// since it fails transiently while a pane is being recycled. Output that
+// is not a mode name is an error.
+func (i *Injector) paneMode(paneID string) (string, error) {
+	mode, err := i.tmux.Run("display-message", "-t", paneID, "-p", "#{pane_mode}")
+	if err != nil {
+		time.Sleep(paneModeRetryDelay)
+		mode, err = i.tmux.Run("display-message", "-t", paneID, "-p", "#{pane_mode}")
+	}
 	if err != nil {
-		return false, "", err
+		return "", err
+	}
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if !paneModeRe.MatchString(mode) {
+		return "", fmt.Errorf("tmux: malformed pane_mode output %q", truncateForLog(mode))
 	}
-	parsed := pane.ParsePaneState(target, out)
-	return parsed.Ready, strings.TrimSpace(out), nil
+	return mode, nil
+}
+
+// isPaneMissing reports whether err is tmux failing to find the pane.
+func isPaneMissing(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "can't find pane") || strings.Contains(msg, "no such pane")
+}
+
+// IsPaneReady checks copy-mode and prompt readiness for a pane.
+func (i *Injector) IsPaneReady(paneID, target string) (bool, string, error) {
+	r := i.CheckReadiness(paneID, target)
+	return r.Ready(), r.Tail, r.Err
 }
 
 func nextBackoff(current time.Duration) time.Duration {
@@ -310,6 +868,26 @@ func nextBackoff(current time.Duration) time.Duration {
 	return 5 * time.Second
 }
 
+// Copy-mode usually means someone is scrolling the pane and can last
+// minutes, so it backs off from a higher floor to a higher cap than a
+// prompt that is only briefly not ready.
+const (
+	copyModeBackoffMin = 2 * time.Second
+	copyModeBackoffMax = 30 * time.Second
+)
+
+// nextBackoffFor returns the wait before rechecking a pane that was not
+// ready for reason. Leaving copy-mode drops back to the normal cap.
+func nextBackoffFor(reason ReadyReason, current time.Duration) time.Duration {
+	if reason != ReasonCopyMode {
+		return nextBackoff(current)
+	}
+	if current < copyModeBackoffMin {
+		return copyModeBackoffMin
+	}
+	return min(current*2, copyModeBackoffMax)
+}
+
 func sleepOrDone(ctx context.Context, d time.Duration) bool {
 	timer := time.NewTimer(d)
 	defer timer.Stop()
@@ -321,11 +899,20 @@ func sleepOrDone(ctx context.Context, d time.Duration) bool {
 	}
 }
 
+// closingTagRe finds a relay-message closing tag in a payload, however it
+// is cased or spaced.
+var closingTagRe = regexp.MustCompile(`(?i)</(\s*relay-message)`)
+
 // xmlEscapePayload escapes & and < in payload to prevent breaking the
 // enclosing <relay-message> XML tags. We only escape these two characters
 // to keep the payload readable for agents while preventing XML injection.
+// A quoted closing tag also has its slash encoded: an agent that reads
+// "&lt;/relay-message>" as the text it stands for could still take it as
+// the end of the wrapper. Quotes are left alone, since the payload is
+// never inside an attribute and the attributes are Go-quoted.
 func xmlEscapePayload(s string) string {
 	s = strings.ReplaceAll(s, "&", "&amp;")
+	s = closingTagRe.ReplaceAllString(s, "&lt;&#47;$1")
 	s = strings.ReplaceAll(s, "<", "&lt;")
 	return s
 }
@@ -339,6 +926,29 @@ func truncateForLog(text string) string {
 	return trimmed[len(trimmed)-max:]
 }
 
+// logInjected records an inject event whose latency is the time the
+// message spent queued, from enqueue to the final successful send.
+func (i *Injector) logInjected(item *queuedMessage, target string) {
+	if i.logger == nil {
+		return
+	}
+	latency := float64(time.Since(item.enqueued).Microseconds()) / 1000
+	evt := logpkg.NewEvent(logpkg.EventTypeInject, item.env.From, target).WithMsgID(item.env.MsgID).WithLatency(latency)
+	_ = i.logger.Log(evt)
+}
+
+// logBlocked records a blocked event with the reason in its status field.
+func (i *Injector) logBlocked(env *envelope.Envelope, target string, reason ReadyReason, detail string) {
+	if i.logger == nil {
+		return
+	}
+	evt := logpkg.NewEvent(logpkg.EventTypeBlocked, env.From, target).WithMsgID(env.MsgID).WithStatus(string(reason))
+	if detail = truncateForLog(detail); detail != "" {
+		evt = evt.WithError(detail)
+	}
+	_ = i.logger.Log(evt)
+}
+
 func (i *Injector) logEvent(eventType, from, to, msgID, errText string) {
 	if i.logger == nil {
 		return
@@ -0,0 +1,53 @@
+package tmux
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PaneID is a validated tmux pane target: either a global pane id ("%3")
+// or a session:window.pane address ("relay:1.2").
+type PaneID struct {
+	Raw     string
+	Index   int    // N of a "%N" id; -1 for session:window.pane targets
+	Session string // set for session:window.pane targets
+	Window  string // window index or name
+	Pane    int    // pane index within the window
+}
+
+// String returns the target as passed to tmux -t.
+func (p PaneID) String() string {
+	return p.Raw
+}
+
+// ParsePaneID validates s as a tmux pane target.
+func ParsePaneID(s string) (PaneID, error) {
+	raw := strings.TrimSpace(s)
+	invalid := func() (PaneID, error) {
+		return PaneID{}, fmt.Errorf("tmux: invalid pane id %q: want %%N or session:window.pane", s)
+	}
+
+	if rest, ok := strings.CutPrefix(raw, "%"); ok {
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 || rest != strconv.Itoa(n) {
+			return invalid()
+		}
+		return PaneID{Raw: raw, Index: n, Pane: -1}, nil
+	}
+
+	session, windowPane, ok := strings.Cut(raw, ":")
+	if !ok || session == "" {
+		return invalid()
+	}
+	dot := strings.LastIndex(windowPane, ".")
+	if dot <= 0 {
+		return invalid()
+	}
+	window, paneStr := windowPane[:dot], windowPane[dot+1:]
+	pane, err := strconv.Atoi(paneStr)
+	if err != nil || pane < 0 || strings.ContainsAny(window, ":") {
+		return invalid()
+	}
+	return PaneID{Raw: raw, Index: -1, Session: session, Window: window, Pane: pane}, nil
+}
@@ -0,0 +1,24 @@
+package tmux
+
+import "testing"
+
+func TestParsePaneID(t *testing.T) {
+	p, err := ParsePaneID("%0")
+	if err != nil || p.Index != 0 || p.Session != "" {
+		t.Fatalf("ParsePaneID(%%0) = %+v, %v", p, err)
+	}
+
+	p, err = ParsePaneID("sess:1.2")
+	if err != nil || p.Session != "sess" || p.Window != "1" || p.Pane != 2 || p.Index != -1 {
+		t.Fatalf("ParsePaneID(sess:1.2) = %+v, %v", p, err)
+	}
+	if p.String() != "sess:1.2" {
+		t.Fatalf("String() = %q", p.String())
+	}
+
+	for _, bad := range []string{"", "2", "%", "%x", "%-1", "window.0", "sess:1", ":1.2", "sess:1.x", "sess:.2"} {
+		if _, err := ParsePaneID(bad); err == nil {
+			t.Errorf("ParsePaneID(%q) succeeded, want error", bad)
+		}
+	}
+}
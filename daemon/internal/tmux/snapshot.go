@@ -0,0 +1,129 @@
+package tmux
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/norm/relay-daemon/pkg/envelope"
+)
+
+// QueuedEnvelope is a pending message as captured by SnapshotQueues.
+type QueuedEnvelope struct {
+	Envelope *envelope.Envelope `json:"envelope"`
+	Enqueued time.Time          `json:"enqueued"`
+	Sent     int                `json:"sent,omitempty"`
+}
+
+// SnapshotQueues returns a copy of every non-empty per-target queue in
+// delivery order. A message mid-delivery is not included.
+func (i *Injector) SnapshotQueues() map[string][]QueuedEnvelope {
+	i.mu.RLock()
+	queues := make([]*paneQueue, 0, len(i.queues))
+	for _, pq := range i.queues {
+		queues = append(queues, pq)
+	}
+	i.mu.RUnlock()
+
+	out := make(map[string][]QueuedEnvelope)
+	for _, pq := range queues {
+		pq.mu.Lock()
+		for _, item := range pq.items {
+			out[pq.target] = append(out[pq.target], QueuedEnvelope{
+				Envelope: item.env,
+				Enqueued: item.enqueued,
+				Sent:     item.sent,
+			})
+		}
+		pq.mu.Unlock()
+	}
+	return out
+}
+
+// RestoreQueues appends snapshotted messages to the queues of known
+// targets, keeping their original enqueue times so queue max-age still
+// applies. Messages already expired are dropped, and a message already
+// queued or recently delivered to the same target is not queued again.
+// Targets missing from the current pane map are returned sorted; their
+// messages go to the drop hook as DropUnknownTarget so they can be
+// replayed once the role is mapped again.
+func (i *Injector) RestoreQueues(queues map[string][]QueuedEnvelope) []string {
+	var skipped []string
+	now := time.Now()
+	for target, items := range queues {
+		i.mu.RLock()
+		paneID, ok := i.targets[target]
+		i.mu.RUnlock()
+		if !ok {
+			skipped = append(skipped, target)
+			for _, item := range items {
+				if item.Envelope != nil {
+					i.logEvent("drop", item.Envelope.From, target, item.Envelope.MsgID, "unknown target")
+					i.dropped(item.Envelope, DropUnknownTarget)
+				}
+			}
+			continue
+		}
+		pq := i.getQueue(target, paneID)
+		for _, item := range items {
+			if item.Envelope == nil {
+				continue
+			}
+			msg := &queuedMessage{env: item.Envelope, enqueued: item.Enqueued, sent: item.Sent}
+			if i.expired(msg, now) {
+				i.dropExpired(msg.env, target)
+				continue
+			}
+			if i.recent.has(dedupKey(target, msg.env.MsgID)) {
+				continue
+			}
+			pq.enqueueUnique(msg)
+		}
+	}
+	sort.Strings(skipped)
+	return skipped
+}
+
+// QueueFileName is the file under the state directory that holds queues
+// saved at shutdown.
+const QueueFileName = "queues.json"
+
+// SaveQueues writes the undelivered queue contents to path, replacing it
+// atomically. Like SnapshotQueues it misses a message mid-delivery.
+func (i *Injector) SaveQueues(path string) error {
+	data, err := json.MarshalIndent(i.SnapshotQueues(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadQueues restores queues written by SaveQueues through RestoreQueues
+// and removes the file, so the messages are restored once. A missing file
+// restores nothing. Load belongs before Start, and after SetDropHook so
+// messages for unmapped targets are kept.
+func (i *Injector) LoadQueues(path string) (skipped []string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var queues map[string][]QueuedEnvelope
+	if err := json.Unmarshal(data, &queues); err != nil {
+		return nil, fmt.Errorf("decode saved queues: %w", err)
+	}
+	skipped = i.RestoreQueues(queues)
+	return skipped, os.Remove(path)
+}
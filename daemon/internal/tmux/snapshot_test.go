@@ -0,0 +1,83 @@
+package tmux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/norm/relay-daemon/pkg/envelope"
+)
+
+func TestSaveLoadQueuesRoundTrip(t *testing.T) {
+	targets := map[string]string{"oc": "%0", "cc": "%1"}
+	inj := NewInjector(&fakeRunner{}, targets)
+	first := envelope.NewEnvelope("oc", "cc", "chat", "first")
+	stale := envelope.NewEnvelope("oc", "cc", "event", "status ping")
+	stale.ExpiresAt = time.Now().Add(-time.Second).UTC().Format(time.RFC3339)
+	second := envelope.NewEnvelope("oc", "cc", "chat", "second")
+	reply := envelope.NewEnvelope("cc", "oc", "chat", "reply")
+	for _, env := range []*envelope.Envelope{first, stale, second, reply} {
+		if err := inj.Inject(env); err != nil {
+			t.Fatalf("inject: %v", err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "state", QueueFileName)
+	if err := inj.SaveQueues(path); err != nil {
+		t.Fatalf("SaveQueues: %v", err)
+	}
+
+	restarted := NewInjector(&fakeRunner{}, targets)
+	skipped, err := restarted.LoadQueues(path)
+	if err != nil || len(skipped) != 0 {
+		t.Fatalf("LoadQueues = %v, %v", skipped, err)
+	}
+	queues := restarted.SnapshotQueues()
+	var cc []string
+	for _, q := range queues["cc"] {
+		cc = append(cc, q.Envelope.Payload)
+	}
+	if len(cc) != 2 || cc[0] != "first" || cc[1] != "second" {
+		t.Fatalf("cc queue = %v, want first and second without the expired ping", cc)
+	}
+	if len(queues["oc"]) != 1 || queues["oc"][0].Envelope.MsgID != reply.MsgID {
+		t.Fatalf("oc queue = %+v", queues["oc"])
+	}
+
+	// The file is consumed, so a second restart restores nothing twice.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("saved queues not removed after load: %v", err)
+	}
+	if _, err := restarted.LoadQueues(path); err != nil {
+		t.Fatalf("LoadQueues without a file: %v", err)
+	}
+	if n := len(restarted.SnapshotQueues()["cc"]); n != 2 {
+		t.Fatalf("cc queue has %d messages after a second load", n)
+	}
+}
+
+func TestLoadQueuesDropsUnmappedTargetsToHook(t *testing.T) {
+	inj := NewInjector(&fakeRunner{}, map[string]string{"oc": "%0", "vog": "%4"})
+	orphan := envelope.NewEnvelope("oc", "vog", "chat", "for a role that left")
+	if err := inj.Inject(orphan); err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), QueueFileName)
+	if err := inj.SaveQueues(path); err != nil {
+		t.Fatalf("SaveQueues: %v", err)
+	}
+
+	restarted := NewInjector(&fakeRunner{}, map[string]string{"oc": "%0"})
+	var dropped []string
+	restarted.SetDropHook(func(env *envelope.Envelope, reason string) {
+		dropped = append(dropped, env.MsgID+":"+reason)
+	})
+	skipped, err := restarted.LoadQueues(path)
+	if err != nil || len(skipped) != 1 || skipped[0] != "vog" {
+		t.Fatalf("LoadQueues = %v, %v", skipped, err)
+	}
+	if len(dropped) != 1 || dropped[0] != orphan.MsgID+":"+DropUnknownTarget {
+		t.Fatalf("dropped = %v, want the vog message as %s", dropped, DropUnknownTarget)
+	}
+}
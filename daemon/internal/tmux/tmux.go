@@ -1,15 +1,21 @@
 package tmux
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/norm/relay-daemon/internal/execx"
 )
 
+// commandTimeout bounds a single tmux invocation so a wedged server can't
+// stall delivery.
+const commandTimeout = 10 * time.Second
+
 // vimModeEnabled checks if RELAY_VIM_MODE is set to true.
 // When enabled, sends Escape before Enter to exit vim INSERT mode.
 // Default: false (no Escape sent).
@@ -18,10 +24,17 @@ func vimModeEnabled() bool {
 }
 
 // Tmux provides helpers for interacting with tmux.
-type Tmux struct{}
+type Tmux struct {
+	exec *execx.Executor
+}
 
 func New() *Tmux {
-	return &Tmux{}
+	return &Tmux{exec: execx.Default}
+}
+
+// NewWithExecutor returns a Tmux that runs commands through e.
+func NewWithExecutor(e *execx.Executor) *Tmux {
+	return &Tmux{exec: e}
 }
 
 var paneSendLocks sync.Map
@@ -108,23 +121,15 @@ func (t *Tmux) Run(args ...string) (string, error) {
 
 // run executes a tmux command and returns trimmed output.
 func (t *Tmux) run(args ...string) (string, error) {
-	cmd := exec.Command("tmux", args...)
-	out, err := cmd.CombinedOutput()
-	output := strings.TrimSpace(string(out))
-	if err != nil {
-		return output, fmt.Errorf("tmux %v: %w", args, err)
-	}
-	return output, nil
+	res, err := t.exec.Run(context.Background(), "tmux", args, execx.Options{Timeout: commandTimeout})
+	return res.Combined(), err
 }
 
 // loadBuffer writes content to a tmux buffer via stdin.
 func (t *Tmux) loadBuffer(bufferName, content string) error {
-	cmd := exec.Command("tmux", "load-buffer", "-b", bufferName, "-")
-	cmd.Stdin = strings.NewReader(content)
-	out, err := cmd.CombinedOutput()
-	output := strings.TrimSpace(string(out))
-	if err != nil {
-		return fmt.Errorf("tmux [load-buffer -b %s -]: %w (%s)", bufferName, err, output)
-	}
-	return nil
+	_, err := t.exec.Run(context.Background(), "tmux", []string{"load-buffer", "-b", bufferName, "-"}, execx.Options{
+		Timeout: commandTimeout,
+		Stdin:   content,
+	})
+	return err
 }
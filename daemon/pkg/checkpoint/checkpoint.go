@@ -0,0 +1,154 @@
+// Package checkpoint defines the shared contract for checkpoint requests
+// sent by the relay and the checkpoint_content responses agents send back.
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/norm/relay-daemon/internal/labels"
+)
+
+// RequestPrefix marks a checkpoint request payload.
+const RequestPrefix = "[CHECKPOINT_REQUEST]"
+
+// Envelope kinds used by the checkpoint protocol.
+const (
+	KindRequest = "checkpoint_request"
+	KindContent = "checkpoint_content"
+)
+
+// Request is a parsed "[CHECKPOINT_REQUEST] chk_id=X ..." payload.
+type Request struct {
+	ChkID  string
+	Role   string
+	Fields map[string]string // all key=value pairs, including chk_id and role
+}
+
+// Content is the structured body of a checkpoint_content message.
+type Content struct {
+	ChkID   string            `json:"chk_id"`
+	Role    string            `json:"role"`
+	Title   string            `json:"title,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Content string            `json:"content"`
+}
+
+// FormatRequest renders a checkpoint request payload for a role.
+func FormatRequest(chkID, role string) string {
+	out := RequestPrefix + " chk_id=" + chkID
+	if role != "" {
+		out += " role=" + role
+	}
+	return out
+}
+
+// ParseRequest parses a checkpoint request payload. The prefix may be
+// preceded by whitespace; unknown key=value pairs are kept in Fields.
+func ParseRequest(payload string) (*Request, error) {
+	trimmed := strings.TrimSpace(payload)
+	if !strings.HasPrefix(trimmed, RequestPrefix) {
+		return nil, fmt.Errorf("checkpoint: missing %s prefix", RequestPrefix)
+	}
+	rest := strings.TrimPrefix(trimmed, RequestPrefix)
+	if idx := strings.IndexByte(rest, '\n'); idx >= 0 {
+		rest = rest[:idx]
+	}
+
+	req := &Request{Fields: map[string]string{}}
+	for _, field := range strings.Fields(rest) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			continue
+		}
+		req.Fields[labels.NormalizeKey(key)] = value
+	}
+	req.ChkID = req.Fields[labels.KeyChkID]
+	req.Role = req.Fields[labels.KeyRole]
+	if req.ChkID == "" {
+		return nil, errors.New("checkpoint: request missing chk_id")
+	}
+	return req, nil
+}
+
+// BuildContent renders the checkpoint_content payload an agent sends in
+// response to a request. Label keys are normalized and validated so the
+// resulting bead can be found by drift and restore queries.
+func BuildContent(chkID, role, content, title string, extra map[string]string) (string, error) {
+	c := Content{
+		ChkID:   strings.TrimSpace(chkID),
+		Role:    strings.TrimSpace(role),
+		Title:   strings.TrimSpace(title),
+		Content: strings.TrimSpace(content),
+	}
+	if len(extra) > 0 {
+		c.Labels = make(map[string]string, len(extra))
+		for key, value := range extra {
+			c.Labels[labels.NormalizeKey(key)] = value
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ParseContent decodes and validates a checkpoint_content payload.
+func ParseContent(payload string) (*Content, error) {
+	var c Content
+	if err := json.Unmarshal([]byte(strings.TrimSpace(payload)), &c); err != nil {
+		return nil, fmt.Errorf("checkpoint: decode content: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks the required fields and label format.
+func (c *Content) Validate() error {
+	if c.ChkID == "" {
+		return errors.New("checkpoint: missing chk_id")
+	}
+	if err := labels.Validate(labels.KeyRole, c.Role); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if c.Content == "" {
+		return errors.New("checkpoint: empty content")
+	}
+	keys := make([]string, 0, len(c.Labels))
+	for key := range c.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := labels.Validate(key, c.Labels[key]); err != nil {
+			return fmt.Errorf("checkpoint: %w", err)
+		}
+	}
+	if v, ok := c.Labels[labels.KeyChkID]; ok && v != c.ChkID {
+		return fmt.Errorf("checkpoint: label chk_id %q does not match %q", v, c.ChkID)
+	}
+	if v, ok := c.Labels[labels.KeyRole]; ok && v != c.Role {
+		return fmt.Errorf("checkpoint: label role %q does not match %q", v, c.Role)
+	}
+	return nil
+}
+
+// ResponseTo builds the checkpoint_content payload answering req.
+func (req *Request) ResponseTo(role, content, title string, extra map[string]string) (string, error) {
+	if req.Role != "" && role != "" && req.Role != role {
+		return "", fmt.Errorf("checkpoint: request for role %q answered by %q", req.Role, role)
+	}
+	if role == "" {
+		role = req.Role
+	}
+	return BuildContent(req.ChkID, role, content, title, extra)
+}
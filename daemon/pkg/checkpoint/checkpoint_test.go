@@ -0,0 +1,73 @@
+package checkpoint
+
+import "testing"
+
+func TestParseRequest(t *testing.T) {
+	req, err := ParseRequest("  [CHECKPOINT_REQUEST] chk_id=chk-123 role=cc reason=timer\nplease respond")
+	if err != nil {
+		t.Fatalf("ParseRequest: %v", err)
+	}
+	if req.ChkID != "chk-123" || req.Role != "cc" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if req.Fields["reason"] != "timer" {
+		t.Fatalf("expected reason field, got %v", req.Fields)
+	}
+}
+
+func TestParseRequestErrors(t *testing.T) {
+	for _, payload := range []string{"", "chk_id=abc", "[CHECKPOINT_REQUEST] role=cc"} {
+		if _, err := ParseRequest(payload); err == nil {
+			t.Fatalf("expected error for %q", payload)
+		}
+	}
+}
+
+func TestRequestContentRoundTrip(t *testing.T) {
+	req, err := ParseRequest(FormatRequest("chk-abc", "cx"))
+	if err != nil {
+		t.Fatalf("ParseRequest: %v", err)
+	}
+	payload, err := req.ResponseTo("cx", "## Current Goal\nship it", "cx checkpoint", map[string]string{"chk-id": "chk-abc", "source": "agent"})
+	if err != nil {
+		t.Fatalf("ResponseTo: %v", err)
+	}
+	content, err := ParseContent(payload)
+	if err != nil {
+		t.Fatalf("ParseContent: %v", err)
+	}
+	if content.ChkID != "chk-abc" || content.Role != "cx" {
+		t.Fatalf("unexpected content %+v", content)
+	}
+	if content.Labels["chk_id"] != "chk-abc" {
+		t.Fatalf("expected normalized chk_id label, got %v", content.Labels)
+	}
+	if content.Title != "cx checkpoint" || content.Content != "## Current Goal\nship it" {
+		t.Fatalf("unexpected body %+v", content)
+	}
+}
+
+func TestBuildContentValidation(t *testing.T) {
+	if _, err := BuildContent("", "cc", "body", "", nil); err == nil {
+		t.Fatal("expected error for missing chk_id")
+	}
+	if _, err := BuildContent("chk-1", "bogus", "body", "", nil); err == nil {
+		t.Fatal("expected error for invalid role")
+	}
+	if _, err := BuildContent("chk-1", "cc", "  ", "", nil); err == nil {
+		t.Fatal("expected error for empty content")
+	}
+	if _, err := BuildContent("chk-1", "cc", "body", "", map[string]string{"chk_id": "chk-2"}); err == nil {
+		t.Fatal("expected error for mismatched chk_id label")
+	}
+	if _, err := BuildContent("chk-1", "cc", "body", "", map[string]string{"Bad Key": "x"}); err == nil {
+		t.Fatal("expected error for invalid label key")
+	}
+}
+
+func TestResponseToRoleMismatch(t *testing.T) {
+	req := &Request{ChkID: "chk-1", Role: "cc"}
+	if _, err := req.ResponseTo("cx", "body", "", nil); err == nil {
+		t.Fatal("expected role mismatch error")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -19,8 +20,18 @@ type Envelope struct {
 	ThreadID  string `json:"thread_id"`   // "atk-x1y2z3"
 	Payload   string `json:"payload"`     // The actual message
 	Ephemeral bool   `json:"ephemeral"`   // Don't sync to S3 if true
+
+	RequestReceipt bool `json:"request_receipt"` // Send a receipt to From once delivered
+
+	// ExpiresAt (RFC3339, fractional seconds allowed) is when an
+	// undelivered message is dropped. When empty the injector's global
+	// queue age limit applies instead.
+	ExpiresAt string `json:"expires_at,omitempty"`
 }
 
+// KindReceipt marks delivery receipts generated by the relay.
+const KindReceipt = "receipt"
+
 // NewEnvelope creates a new envelope with a generated message ID and timestamp.
 func NewEnvelope(from, to, kind, payload string) *Envelope {
 	return &Envelope{
@@ -34,6 +45,22 @@ func NewEnvelope(from, to, kind, payload string) *Envelope {
 	}
 }
 
+// NewReceipt builds the receipt for a delivered envelope, addressed back to
+// its sender. It returns nil when no receipt was requested, and never
+// produces a receipt for a receipt so acknowledgments cannot loop.
+func NewReceipt(orig *Envelope, deliveredAt time.Time) *Envelope {
+	if orig == nil || !orig.RequestReceipt || orig.Kind == KindReceipt {
+		return nil
+	}
+	ts := deliveredAt.UTC().Format(time.RFC3339)
+	r := NewEnvelope("relay", orig.From, KindReceipt,
+		fmt.Sprintf("delivered msg_id=%s to=%s at=%s", orig.MsgID, orig.To, ts))
+	r.ProjectID = orig.ProjectID
+	r.ThreadID = orig.ThreadID
+	r.Ephemeral = true
+	return r
+}
+
 // GenerateMsgID returns a msg- prefixed 8-hex identifier.
 func GenerateMsgID() string {
 	buf := make([]byte, 4)
@@ -66,5 +93,20 @@ func (e *Envelope) Validate() error {
 	if e.Kind == "" {
 		return errors.New("envelope: missing kind")
 	}
+	if e.ExpiresAt != "" {
+		if _, err := time.Parse(time.RFC3339Nano, e.ExpiresAt); err != nil {
+			return fmt.Errorf("envelope: invalid expires_at %q", e.ExpiresAt)
+		}
+	}
 	return nil
 }
+
+// Expiry returns the parsed ExpiresAt. ok is false when the envelope has
+// no expiry of its own.
+func (e *Envelope) Expiry() (t time.Time, ok bool) {
+	if e.ExpiresAt == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, e.ExpiresAt)
+	return t, err == nil
+}
@@ -24,13 +24,14 @@ CURRENT_VERSION=$(jq -r '.version // 0' "$PANES_FILE" 2>/dev/null || echo "0")
 NEW_VERSION=$((CURRENT_VERSION + 1))
 
 # Enumerate panes and match @role
-OC_PANE="" CC_PANE="" ADMIN_PANE="" CX_PANE=""
+OC_PANE="" CC_PANE="" ADMIN_PANE="" CX_PANE="" VOG_PANE=""
 while read -r PANE_ID ROLE WINDOW_NAME; do
     case "$ROLE" in
         oc)    OC_PANE="$PANE_ID" ;;
         cc)    CC_PANE="$PANE_ID" ;;
         admin) ADMIN_PANE="$PANE_ID" ;;
         cx)    CX_PANE="$PANE_ID" ;;
+        vog)   VOG_PANE="$PANE_ID" ;;
     esac
     # Fallback: check window name for CX
     if [[ -z "$CX_PANE" && "$(echo "$WINDOW_NAME" | tr '[:upper:]' '[:lower:]')" == "cx" ]]; then
@@ -56,6 +57,7 @@ jq -n \
     --arg cc "${CC_PANE:-}" \
     --arg admin "${ADMIN_PANE:-}" \
     --arg cx "${CX_PANE:-}" \
+    --arg vog "${VOG_PANE:-}" \
     --argjson version "$NEW_VERSION" \
     --arg ts "$TIMESTAMP" \
     '{
@@ -63,7 +65,8 @@ jq -n \
             oc: (if $oc == "" then null else $oc end),
             cc: (if $cc == "" then null else $cc end),
             admin: (if $admin == "" then null else $admin end),
-            cx: (if $cx == "" then null else $cx end)
+            cx: (if $cx == "" then null else $cx end),
+            vog: (if $vog == "" then null else $vog end)
         },
         version: $version,
         registered_at: $ts